@@ -93,7 +93,9 @@ func drawSelect(s tcell.Screen, x1, y1, x2, y2 int, sel bool) {
 			}
 			style = style.Reverse(sel)
 			s.SetContent(col, row, mainc, combc, style)
-			col += width - 1
+			if width > 1 {
+				col += width - 1
+			}
 		}
 	}
 }
@@ -0,0 +1,296 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+// StyledRune is a character together with the style to display it in.
+type StyledRune struct {
+	R     rune
+	Style Style
+}
+
+// ParseANSI parses text containing ANSI escape sequences, such as the
+// output of "ls --color" or "git diff", into styled characters that can
+// be displayed with SetContent.  The SGR sequences that set colors and
+// attributes are understood, as are OSC 8 hyperlinks.  Other escape
+// sequences, such as those that move the cursor, are dropped, as are
+// control characters other than newline and tab.  If the text ends
+// part way through an escape sequence, the characters before it are
+// returned along with ErrBadEscape.
+func ParseANSI(s string) ([]StyledRune, error) {
+	res := make([]StyledRune, 0, len(s))
+	style := StyleDefault
+	for i := 0; i < len(s); {
+		r, n := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '\x1b':
+			n = EscapeLength(s[i:])
+			if n == 0 {
+				return res, ErrBadEscape
+			}
+			seq := s[i : i+n]
+			switch {
+			case strings.HasPrefix(seq, "\x1b[") && seq[n-1] == 'm':
+				style = applySGR(style, seq[2:n-1])
+			case strings.HasPrefix(seq, "\x1b]8;"):
+				style = style.URL(oscHyperlink(seq))
+			}
+		case r == '\n' || r == '\t':
+			res = append(res, StyledRune{r, style})
+		case r < ' ' || r == 0x7f:
+		default:
+			res = append(res, StyledRune{r, style})
+		}
+		i += n
+	}
+	return res, nil
+}
+
+// EscapeLength returns the length of the ANSI escape sequence at the
+// start of s, or zero if it is incomplete.  CSI sequences end with a
+// final byte, and OSC sequences with ST or BEL.  Other sequences are a
+// final byte, after any intermediate bytes, as in ESC ( B, which is part
+// of the sequence that many terminals use to reset attributes.  This is
+// how ParseANSI and StringWidth find the end of escape sequences.
+func EscapeLength(s string) int {
+	if len(s) < 2 {
+		return 0
+	}
+	switch s[1] {
+	case '[':
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return 0
+	case ']':
+		for i := 2; i < len(s); i++ {
+			switch {
+			case s[i] == '\a':
+				return i + 1
+			case s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\':
+				return i + 2
+			}
+		}
+		return 0
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x2f {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// oscHyperlink returns the URL of an OSC 8 sequence, which takes the form
+// OSC 8 ; params ; url ST.  An empty URL ends the link.
+func oscHyperlink(seq string) string {
+	seq = strings.TrimSuffix(strings.TrimSuffix(seq, "\a"), "\x1b\\")
+	parts := strings.SplitN(seq, ";", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
+
+// applySGR returns style changed by the parameters of an SGR sequence.
+// Parameters are separated by semicolons, and some have sub-parameters
+// separated by colons, as in "4:3" for a curly underline.  Unknown
+// parameters are ignored.
+func applySGR(style Style, params string) Style {
+	ps := strings.Split(params, ";")
+	for i := 0; i < len(ps); i++ {
+		sub := strings.Split(ps[i], ":")
+		p, _ := strconv.Atoi(sub[0])
+		switch {
+		case p == 0:
+			style = StyleDefault.URL(style.url)
+		case p == 1:
+			style = style.Bold(true)
+		case p == 2:
+			style = style.Dim(true)
+		case p == 3:
+			style = style.Italic(true)
+		case p == 4:
+			style = applyUnderline(style, sub)
+		case p == 5 || p == 6:
+			style = style.Blink(true)
+		case p == 7:
+			style = style.Reverse(true)
+		case p == 8:
+			style = style.Invisible(true)
+		case p == 9:
+			style = style.StrikeThrough(true)
+		case p == 21:
+			// double underline, which we cannot show
+			style = style.Underline(true)
+		case p == 22:
+			style = style.Bold(false).Dim(false)
+		case p == 23:
+			style = style.Italic(false)
+		case p == 24:
+			style = style.Underline(false)
+		case p == 25:
+			style = style.Blink(false)
+		case p == 27:
+			style = style.Reverse(false)
+		case p == 28:
+			style = style.Invisible(false)
+		case p == 29:
+			style = style.StrikeThrough(false)
+		case p >= 30 && p <= 37:
+			style = style.Foreground(PaletteColor(p - 30))
+		case p == 39:
+			style = style.Foreground(ColorDefault)
+		case p >= 40 && p <= 47:
+			style = style.Background(PaletteColor(p - 40))
+		case p == 49:
+			style = style.Background(ColorDefault)
+		case p == 53:
+			style = style.Overline(true)
+		case p == 55:
+			style = style.Overline(false)
+		case p == 59:
+			style = style.UnderlineColor(ColorDefault)
+		case p >= 90 && p <= 97:
+			style = style.Foreground(PaletteColor(p - 90 + 8))
+		case p >= 100 && p <= 107:
+			style = style.Background(PaletteColor(p - 100 + 8))
+		case p == 38 || p == 48 || p == 58:
+			var c Color
+			if len(sub) > 1 {
+				c = sgrColor(sub[1:])
+			} else {
+				var used int
+				c, used = sgrColorParams(ps[i+1:])
+				i += used
+			}
+			switch p {
+			case 38:
+				style = style.Foreground(c)
+			case 48:
+				style = style.Background(c)
+			default:
+				style = style.UnderlineColor(c)
+			}
+		}
+	}
+	return style
+}
+
+// applyUnderline handles SGR 4, which may have a sub-parameter giving
+// the kind of underline.
+func applyUnderline(style Style, sub []string) Style {
+	if len(sub) < 2 {
+		return style.Underline(true)
+	}
+	switch sub[1] {
+	case "0":
+		return style.Underline(false)
+	case "3":
+		return style.Undercurl(true)
+	case "4":
+		return style.Underdotted(true)
+	case "5":
+		return style.Underdashed(true)
+	}
+	return style.Underline(true)
+}
+
+// sgrColor parses the sub-parameters of an extended color, which are
+// 5:n for a palette color, or 2:r:g:b, where the standard form also has
+// an (empty) color space before r.
+func sgrColor(sub []string) Color {
+	switch {
+	case len(sub) == 2 && sub[0] == "5":
+		n, _ := strconv.Atoi(sub[1])
+		return PaletteColor(n & 0xff)
+	case len(sub) >= 4 && sub[0] == "2":
+		rgb := sub[len(sub)-3:]
+		r, _ := strconv.Atoi(rgb[0])
+		g, _ := strconv.Atoi(rgb[1])
+		b, _ := strconv.Atoi(rgb[2])
+		return NewRGBColor(int32(r), int32(g), int32(b))
+	}
+	return ColorDefault
+}
+
+// sgrColorParams is like sgrColor, but for the older form in which the
+// parts of the color are separate parameters, as in 38;5;n.  It returns
+// how many of the parameters were used.
+func sgrColorParams(ps []string) (Color, int) {
+	switch {
+	case len(ps) >= 2 && ps[0] == "5":
+		return sgrColor(ps[:2]), 2
+	case len(ps) >= 4 && ps[0] == "2":
+		return sgrColor(ps[:4]), 4
+	}
+	return ColorDefault, len(ps)
+}
+
+// ansiTabStop is the distance between tab stops for DrawANSI.
+const ansiTabStop = 8
+
+// drawANSI does the work of Screen.DrawANSI, using only SetContent, so
+// that it works for any Screen.  Like drawText, it draws a grapheme
+// cluster, such as a flag, in each cell, in the style of its first rune.
+func drawANSI(scr Screen, x, y int, s string, base Style) (int, error) {
+	rs, err := ParseANSI(s)
+	text := make([]rune, len(rs))
+	for i := range rs {
+		text[i] = rs[i].R
+	}
+	n := 0
+	cx := x
+	i := 0 // the index in rs of the cluster
+	g := uniseg.NewGraphemes(string(text))
+	for g.Next() {
+		cl := g.Runes()
+		style := base.Merge(rs[i].Style)
+		i += len(cl)
+		switch cl[len(cl)-1] {
+		case '\n':
+			cx = x
+			y++
+			continue
+		case '\t':
+			for {
+				scr.SetContent(cx, y, ' ', nil, style)
+				cx++
+				if (cx-x)%ansiTabStop == 0 {
+					break
+				}
+			}
+			n++
+			continue
+		}
+		scr.SetContent(cx, y, cl[0], cl[1:], style)
+		w := cellWidth(cl[0], cl[1:])
+		if w < 1 {
+			w = 1
+		}
+		cx += w
+		n++
+	}
+	return n, err
+}
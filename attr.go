@@ -28,6 +28,8 @@ const (
 	AttrDim
 	AttrItalic
 	AttrStrikeThrough
+	AttrOverline
+	AttrInvisible
 	AttrInvalid              // Mark the style or attributes invalid
 	AttrNone    AttrMask = 0 // Just normal text.
 )
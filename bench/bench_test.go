@@ -0,0 +1,220 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// sizes are the terminal sizes that each benchmark is run for.
+var sizes = []struct {
+	Width, Height int
+}{
+	{80, 24},
+	{220, 50},
+	{500, 100},
+}
+
+// runSizes runs f as a sub-benchmark for each size.
+func runSizes(b *testing.B, f func(b *testing.B, w, h int)) {
+	for _, sz := range sizes {
+		w, h := sz.Width, sz.Height
+		b.Run(fmt.Sprintf("%dx%d", w, h), func(b *testing.B) {
+			f(b, w, h)
+		})
+	}
+}
+
+// pipeDriver is a TermDriver for an XTerm of a fixed size, which uses
+// pipes in place of a terminal.  Output is discarded.
+type pipeDriver struct {
+	w, h int
+}
+
+func (d *pipeDriver) Init(chan os.Signal) (*os.File, *os.File, error) {
+	inR, _, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, outR)
+	}()
+	return inR, outW, nil
+}
+
+func (d *pipeDriver) WinSize() (int, int, error) { return d.w, d.h, nil }
+func (d *pipeDriver) GetTerm() string            { return "xterm-256color" }
+func (d *pipeDriver) Engage()                    {}
+func (d *pipeDriver) Disengage()                 {}
+
+// mkScreen returns an initialized terminal screen of the given size.
+func mkScreen(b *testing.B, w, h int) tcell.Screen {
+	s, err := tcell.NewTerminfoScreenWithDriver(&pipeDriver{w: w, h: h})
+	if err != nil {
+		b.Fatalf("Failed to make screen: %v", err)
+	}
+	if err = s.Init(); err != nil {
+		b.Fatalf("Failed to initialize screen: %v", err)
+	}
+	s.Show()
+	return s
+}
+
+// cellStyle returns a style for a cell that changes with n, so that
+// successive frames differ.
+func cellStyle(x, y, n int) tcell.Style {
+	return tcell.StyleDefault.
+		Foreground(tcell.PaletteColor((x + n) % 256)).
+		Background(tcell.PaletteColor((y + n) % 16)).
+		Bold((x+y+n)%7 == 0)
+}
+
+// BenchmarkFullRedraw changes every cell of the screen, and shows it.
+func BenchmarkFullRedraw(b *testing.B) {
+	runSizes(b, func(b *testing.B, w, h int) {
+		s := mkScreen(b, w, h)
+		defer s.Fini()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					r := rune('!' + (x+y+i)%94)
+					s.SetContent(x, y, r, nil, cellStyle(x, y, i))
+				}
+			}
+			s.Show()
+		}
+	})
+}
+
+// BenchmarkIncrementalRedraw changes a few cells in the middle of the
+// screen, as when a clock or a status line is updated, and shows it.
+func BenchmarkIncrementalRedraw(b *testing.B) {
+	runSizes(b, func(b *testing.B, w, h int) {
+		s := mkScreen(b, w, h)
+		defer s.Fini()
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				s.SetContent(x, y, 'x', nil, cellStyle(x, y, 0))
+			}
+		}
+		s.Show()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			s.DrawText(w/2-5, h/2, cellStyle(0, 0, i), fmt.Sprintf("%10d", i))
+			s.Show()
+		}
+	})
+}
+
+// BenchmarkInputParsing parses a mix of keys, including special keys
+// and pasted text, and mouse movement over every row of the screen.
+func BenchmarkInputParsing(b *testing.B) {
+	runSizes(b, func(b *testing.B, w, h int) {
+		s := tcell.NewSimulationScreen("")
+		if err := s.Init(); err != nil {
+			b.Fatalf("Failed to initialize screen: %v", err)
+		}
+		defer s.Fini()
+		s.SetSize(w, h)
+		s.EnableMouse()
+		s.EnablePaste()
+		go func() {
+			for s.PollEvent() != nil {
+			}
+		}()
+
+		var input []byte
+		for y := 0; y < h; y++ {
+			input = append(input, fmt.Sprintf("\x1b[<35;%d;%dM", (y*7)%w+1, y+1)...)
+			input = append(input, "hello\x1b[A\x1b[1;5C\x1bOP\x7f\r"...)
+		}
+		input = append(input, "\x1b[200~pasted text\x1b[201~"...)
+		b.SetBytes(int64(len(input)))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			s.InjectKeyBytes(input)
+		}
+	})
+}
+
+// BenchmarkColorDownsampling finds the closest of the 256 palette colors
+// to an RGB color for every cell of the screen, as is done to show RGB
+// colors on terminals without direct color.  The colors change with
+// each run, as the results are normally cached.
+func BenchmarkColorDownsampling(b *testing.B) {
+	palette := make([]tcell.Color, 256)
+	for i := range palette {
+		palette[i] = tcell.PaletteColor(i)
+	}
+	runSizes(b, func(b *testing.B, w, h int) {
+		for i := 0; i < b.N; i++ {
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					c := tcell.NewRGBColor(int32(x+i)%256, int32(y*5)%256, int32(x*y+i)%256)
+					_ = tcell.FindColor(c, palette)
+				}
+			}
+		}
+	})
+}
+
+// mixedStyles are the styles used for words by BenchmarkStyledOutput,
+// which are much like those used for highlighting source code.
+var mixedStyles = []tcell.Style{
+	tcell.StyleDefault,
+	tcell.StyleDefault.Foreground(tcell.ColorBlue).Bold(true),
+	tcell.StyleDefault.Foreground(tcell.ColorGreen),
+	tcell.StyleDefault.Foreground(tcell.ColorGray).Italic(true),
+	tcell.StyleDefault.Bold(true),
+	tcell.StyleDefault.Foreground(tcell.ColorRed).Bold(true).Underline(true),
+	tcell.StyleDefault.Foreground(tcell.ColorYellow).Background(tcell.ColorNavy),
+	tcell.StyleDefault.Bold(true).Italic(true),
+}
+
+// BenchmarkStyledOutput renders a screen of words in mixed styles, and
+// reports the number of bytes sent to the terminal for it.
+func BenchmarkStyledOutput(b *testing.B) {
+	runSizes(b, func(b *testing.B, w, h int) {
+		s := tcell.NewSimulationScreen("UTF-8")
+		if err := s.Init(); err != nil {
+			b.Fatalf("Failed to initialize screen: %v", err)
+		}
+		defer s.Fini()
+		s.SetSize(w, h)
+		for y := 0; y < h; y++ {
+			for x, n := 0, y; x < w; n++ {
+				style := mixedStyles[(n*5+y)%len(mixedStyles)]
+				x += s.DrawText(x, y, style, fmt.Sprintf("w%d ", n%97))
+			}
+		}
+		var out []byte
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			out = s.RenderANSI()
+		}
+		b.ReportMetric(float64(len(out)), "bytes/frame")
+	})
+}
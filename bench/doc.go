@@ -0,0 +1,25 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bench has benchmarks of tcell, for comparing its performance
+// before and after changes.  There is no code to use here; run them with:
+//
+//	go test -bench . ./bench
+//
+// Each benchmark is run for several sizes of terminal, from 80x24 up, as
+// sub-benchmarks named by the size, so a single size can be chosen with,
+// for example, -bench /220x50.  Screens write their output to a pipe
+// that is read and discarded, so the cost of the system calls to write
+// it is included, as it would be for a real terminal.
+package bench
@@ -0,0 +1,201 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+// BoxStyle selects the box drawing characters used by DrawBox.
+type BoxStyle int
+
+const (
+	// BoxSingle uses thin single lines, as with RuneHLine and RuneVLine.
+	// These are the only ones that terminals without Unicode can draw
+	// properly; the others fall back to plain ASCII there.
+	BoxSingle BoxStyle = iota
+
+	// BoxDouble uses double lines.
+	BoxDouble
+
+	// BoxRounded uses thin single lines with rounded corners.
+	BoxRounded
+
+	// BoxThick uses heavy lines.
+	BoxThick
+)
+
+// boxRunes are the characters for one style of box.
+type boxRunes struct {
+	h, v                   rune // horizontal and vertical lines
+	ul, ur, ll, lr         rune // corners
+	ttee, btee, ltee, rtee rune // where one line meets another
+	plus                   rune // where lines cross
+}
+
+var boxStyles = map[BoxStyle]boxRunes{
+	BoxSingle: {
+		RuneHLine, RuneVLine,
+		RuneULCorner, RuneURCorner, RuneLLCorner, RuneLRCorner,
+		RuneTTee, RuneBTee, RuneLTee, RuneRTee, RunePlus,
+	},
+	BoxDouble: {
+		'═', '║',
+		'╔', '╗', '╚', '╝',
+		'╦', '╩', '╠', '╣', '╬',
+	},
+	BoxRounded: {
+		RuneHLine, RuneVLine,
+		'╭', '╮', '╰', '╯',
+		RuneTTee, RuneBTee, RuneLTee, RuneRTee, RunePlus,
+	},
+	BoxThick: {
+		'━', '┃',
+		'┏', '┓', '┗', '┛',
+		'┳', '┻', '┣', '┫', '╋',
+	},
+}
+
+// boxArms say which directions a box drawing character has lines in.
+type boxArms uint8
+
+const (
+	armUp boxArms = 1 << iota
+	armDown
+	armLeft
+	armRight
+)
+
+// forArms returns the character with the given arms, or the crossing if
+// there is none, as when fewer than two arms are given.
+func (br boxRunes) forArms(arms boxArms) rune {
+	switch arms {
+	case armLeft | armRight:
+		return br.h
+	case armUp | armDown:
+		return br.v
+	case armDown | armRight:
+		return br.ul
+	case armDown | armLeft:
+		return br.ur
+	case armUp | armRight:
+		return br.ll
+	case armUp | armLeft:
+		return br.lr
+	case armLeft | armRight | armDown:
+		return br.ttee
+	case armLeft | armRight | armUp:
+		return br.btee
+	case armUp | armDown | armRight:
+		return br.ltee
+	case armUp | armDown | armLeft:
+		return br.rtee
+	}
+	return br.plus
+}
+
+// runeArms maps each box drawing character that we use to its arms.
+var runeArms = func() map[rune]boxArms {
+	m := make(map[rune]boxArms)
+	all := []boxArms{
+		armLeft | armRight, armUp | armDown,
+		armDown | armRight, armDown | armLeft, armUp | armRight, armUp | armLeft,
+		armLeft | armRight | armDown, armLeft | armRight | armUp,
+		armUp | armDown | armRight, armUp | armDown | armLeft,
+		armUp | armDown | armLeft | armRight,
+	}
+	for _, br := range boxStyles {
+		for _, arms := range all {
+			m[br.forArms(arms)] = arms
+		}
+	}
+	return m
+}()
+
+// boxRunesFor returns the characters for the given style of box, using
+// BoxSingle for unknown styles.
+func boxRunesFor(border BoxStyle) boxRunes {
+	if br, ok := boxStyles[border]; ok {
+		return br
+	}
+	return boxStyles[BoxSingle]
+}
+
+// drawBox does the work of Screen.DrawBox, using only SetContent, so
+// that it works for any Screen.
+func drawBox(s Screen, x, y, w, h int, style Style, border BoxStyle) {
+	if w < 1 || h < 1 {
+		return
+	}
+	switch {
+	case h == 1:
+		// too small for corners, so just a line
+		drawHLine(s, x, y, w, style, border)
+		return
+	case w == 1:
+		drawVLine(s, x, y, h, style, border)
+		return
+	}
+	br := boxRunesFor(border)
+	x2, y2 := x+w-1, y+h-1
+	for col := x + 1; col < x2; col++ {
+		s.SetContent(col, y, br.h, nil, style)
+		s.SetContent(col, y2, br.h, nil, style)
+	}
+	for row := y + 1; row < y2; row++ {
+		s.SetContent(x, row, br.v, nil, style)
+		s.SetContent(x2, row, br.v, nil, style)
+	}
+	s.SetContent(x, y, br.ul, nil, style)
+	s.SetContent(x2, y, br.ur, nil, style)
+	s.SetContent(x, y2, br.ll, nil, style)
+	s.SetContent(x2, y2, br.lr, nil, style)
+}
+
+// drawHLine does the work of Screen.DrawHLine.
+func drawHLine(s Screen, x, y, w int, style Style, border BoxStyle) {
+	br := boxRunesFor(border)
+	for col := x; col < x+w; col++ {
+		s.SetContent(col, y, br.h, nil, style)
+	}
+}
+
+// drawVLine does the work of Screen.DrawVLine.
+func drawVLine(s Screen, x, y, h int, style Style, border BoxStyle) {
+	br := boxRunesFor(border)
+	for row := y; row < y+h; row++ {
+		s.SetContent(x, row, br.v, nil, style)
+	}
+}
+
+// setCrossing does the work of Screen.SetCrossing.  It looks at the
+// cells around the given one to see which of them have lines leading
+// to it.
+func setCrossing(s Screen, x, y int, style Style, border BoxStyle) {
+	neighbor := func(dx, dy int, toward boxArms) boxArms {
+		r, _, _, _ := s.GetContent(x+dx, y+dy)
+		return runeArms[r] & toward
+	}
+	var arms boxArms
+	if neighbor(0, -1, armDown) != 0 {
+		arms |= armUp
+	}
+	if neighbor(0, 1, armUp) != 0 {
+		arms |= armDown
+	}
+	if neighbor(-1, 0, armRight) != 0 {
+		arms |= armLeft
+	}
+	if neighbor(1, 0, armLeft) != 0 {
+		arms |= armRight
+	}
+	s.SetContent(x, y, boxRunesFor(border).forArms(arms), nil, style)
+}
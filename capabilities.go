@@ -0,0 +1,177 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"os"
+	"strings"
+)
+
+// TermCapabilities describes the optional features that a Screen has
+// determined its terminal supports.  This is based on the terminal
+// database and the environment, and for some features, on what the
+// terminal says about itself in reply to queries.  As those replies
+// arrive asynchronously, some features may only be reported a short
+// while after the Screen is initialized.
+type TermCapabilities struct {
+	TrueColor      bool // 24-bit direct color
+	Colors256      bool // at least the 256 color palette
+	Mouse          bool // mouse reporting
+	BracketedPaste bool // bracketed paste
+	FocusEvents    bool // focus in and out reporting
+	Hyperlinks     bool // OSC 8 hyperlinks
+	SixelGraphics  bool // Sixel images
+	KittyKeyboard  bool // the kitty keyboard protocol
+	KittyGraphics  bool // the kitty graphics protocol
+
+	// Multiplexer is the terminal multiplexer that the application is
+	// running in: "tmux", "screen" or "zellij", or empty if none.
+	Multiplexer string
+}
+
+// sixelTerminals are values of TERM_PROGRAM for terminal emulators that
+// are known to display Sixel images.
+var sixelTerminals = map[string]bool{
+	"WezTerm":   true,
+	"mintty":    true,
+	"iTerm.app": true,
+}
+
+// hasSixelProgram returns true if TERM_PROGRAM names a terminal emulator
+// that is known to support Sixel images.
+func hasSixelProgram() bool {
+	return sixelTerminals[os.Getenv("TERM_PROGRAM")]
+}
+
+// detectMultiplexer returns the name of the terminal multiplexer that we
+// are running in, if any, based on the environment variables that each
+// of them sets.
+func detectMultiplexer() string {
+	switch {
+	case os.Getenv("TMUX") != "":
+		return "tmux"
+	case os.Getenv("STY") != "":
+		return "screen"
+	case os.Getenv("ZELLIJ") != "":
+		return "zellij"
+	}
+	return ""
+}
+
+// passthrough wraps s so that the multiplexer sends it on to the terminal
+// that it is running in, rather than handling it itself.  This is how we
+// learn about the real terminal, as TERM only describes the multiplexer.
+// It returns the empty string for multiplexers with no way to do this,
+// which includes zellij, so only the multiplexer itself is asked then.
+// Note that tmux only does this if its allow-passthrough option is on.
+func passthrough(mux, s string) string {
+	switch mux {
+	case "tmux":
+		return "\x1bPtmux;" + strings.Replace(s, "\x1b", "\x1b\x1b", -1) + "\x1b\\"
+	case "screen":
+		return "\x1bP" + s + "\x1b\\"
+	}
+	return ""
+}
+
+// envOverrides is the environment variable that can hold capability
+// overrides, as ScreenOptions.CapabilityOverrides does, so that users can
+// work around a broken terminal database entry without changing the
+// application.
+const envOverrides = "TCELL_TERMINFO_OVERRIDES"
+
+// parseOverrides parses capability overrides from the environment.  They
+// are name=value pairs separated by commas, such as "colors=256,bel=".
+// The values may use the escapes of terminfo source, such as \E for
+// escape and ^G for a control character, and \, for a comma.
+func parseOverrides(s string) map[string]string {
+	caps := make(map[string]string)
+	for len(s) > 0 {
+		// find the end of this one, stepping over escaped commas
+		end := 0
+		for end < len(s) && s[end] != ',' {
+			if s[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end > len(s) {
+			end = len(s)
+		}
+		item := s[:end]
+		if end < len(s) {
+			s = s[end+1:]
+		} else {
+			s = ""
+		}
+		name, value := item, ""
+		if i := strings.IndexByte(item, '='); i >= 0 {
+			name, value = item[:i], item[i+1:]
+		}
+		if name = strings.TrimSpace(name); name != "" {
+			caps[name] = unescapeCap(value)
+		}
+	}
+	return caps
+}
+
+// unescapeCap replaces the escapes in a capability given in terminfo
+// source form with the characters that they stand for.
+func unescapeCap(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '^' && i+1 < len(s):
+			i++
+			if s[i] == '?' {
+				b.WriteByte(0x7f)
+			} else {
+				b.WriteByte(s[i] & 0x1f)
+			}
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch c = s[i]; c {
+			case 'E', 'e':
+				b.WriteByte(0x1b)
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 's':
+				b.WriteByte(' ')
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				// up to three octal digits
+				v := c - '0'
+				for n := 1; n < 3 && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '7'; n++ {
+					i++
+					v = v*8 + s[i] - '0'
+				}
+				b.WriteByte(v)
+			default:
+				b.WriteByte(c)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -15,7 +15,7 @@
 package tcell
 
 import (
-	runewidth "github.com/mattn/go-runewidth"
+	"image"
 )
 
 type cell struct {
@@ -26,6 +26,15 @@ type cell struct {
 	lastStyle Style
 	lastComb  []rune
 	width     int
+	cont      bool // the right half of a wide character
+}
+
+// CellUpdate describes the new contents of one cell, for Screen.SetCells.
+type CellUpdate struct {
+	X, Y      int
+	R         rune
+	Combining []rune
+	Style     Style
 }
 
 // CellBuffer represents a two dimensional array of character cells.
@@ -35,40 +44,121 @@ type cell struct {
 //
 // CellBuffer is not thread safe.
 type CellBuffer struct {
-	w     int
-	h     int
-	cells []cell
+	w        int
+	h        int
+	cells    []cell
+	damage   image.Rectangle // bounds of cells changed since takeDamage
+	combFree [][]rune        // pool of unused combining rune storage
+	row      []cell          // scratch row for CopyRegion
 }
 
 // SetContent sets the contents (primary rune, combining runes,
 // and style) for a cell at a given location.
+//
+// A wide character also takes the cell to its right, which is set to
+// be its right half, with the same style.  Any wide character that is
+// partly overwritten, by this or by another character, is replaced with
+// a space, as a terminal would do.  Setting the right half of a wide
+// character to a zero rune does nothing, so that what GetContent returns
+// can always be given back to SetContent.
 func (cb *CellBuffer) SetContent(x int, y int,
 	mainc rune, combc []rune, style Style) {
 
 	if x >= 0 && y >= 0 && x < cb.w && y < cb.h {
 		c := &cb.cells[(y*cb.w)+x]
+		if c.cont && mainc == 0 {
+			return
+		}
+		// what was turned off only matters for Merge, and would make
+		// styles that look the same compare unequal
+		style.off = 0
 
-		c.currComb = append([]rune{}, combc...)
+		// the width depends on the combining runes too, as for flags
+		hadComb := len(c.currComb) != 0
+		cb.setComb(c, combc)
 
-		if c.currMain != mainc {
-			c.width = runewidth.RuneWidth(mainc)
+		if c.currMain != mainc || hadComb || len(combc) != 0 || c.cont {
+			c.width = cellWidth(mainc, combc)
 		}
 		c.currMain = mainc
 		c.currStyle = style
+		c.cont = false
+		cb.addDamage(image.Rect(x, y, x+1, y+1))
+
+		if c.width > 1 && x+1 < cb.w {
+			n := &cb.cells[(y*cb.w)+x+1]
+			cb.setComb(n, nil)
+			n.currMain = ' '
+			n.currStyle = style
+			n.width = 1
+			n.cont = true
+			cb.addDamage(image.Rect(x+1, y, x+2, y+1))
+			cb.fixWide(x+2, y)
+		} else {
+			cb.fixWide(x+1, y)
+		}
+		cb.fixWide(x, y)
+	}
+}
+
+// fixWide makes sure that the cells at (x-1, y) and (x, y) do not hold
+// half of a wide character, which happens when the other half has been
+// overwritten.  Such a half is replaced with a space in the same style.
+func (cb *CellBuffer) fixWide(x, y int) {
+	if x < 0 || y < 0 || x >= cb.w || y >= cb.h {
+		return
+	}
+	c := &cb.cells[(y*cb.w)+x]
+	var p *cell
+	if x > 0 {
+		p = &cb.cells[(y*cb.w)+x-1]
+	}
+	wide := p != nil && p.width > 1 && !p.cont
+	switch {
+	case c.cont && !wide:
+		cb.blank(c)
+		cb.addDamage(image.Rect(x, y, x+1, y+1))
+	case wide && !c.cont:
+		cb.blank(p)
+		cb.addDamage(image.Rect(x-1, y, x, y+1))
 	}
 }
 
+// blank replaces the contents of a cell with a space, keeping its style.
+func (cb *CellBuffer) blank(c *cell) {
+	cb.setComb(c, nil)
+	c.currMain = ' '
+	c.width = 1
+	c.cont = false
+}
+
 // GetContent returns the contents of a character cell, including the
 // primary rune, any combining character runes (which will usually be
 // nil), the style, and the display width in cells.  (The width can be
 // either 1, normally, or 2 for East Asian full-width characters.)
+// For the right half of a wide character, the rune and width are zero,
+// and the style is that of the character.
 func (cb *CellBuffer) GetContent(x, y int) (rune, []rune, Style, int) {
+	mainc, combc, style, width := cb.getContent(x, y)
+	if len(combc) != 0 {
+		combc = append([]rune(nil), combc...)
+	}
+	return mainc, combc, style, width
+}
+
+// getContent is like GetContent, but the combining runes it returns are
+// the storage of the cell itself, which is reused when the cell changes.
+// This is for drawing, which only needs them until the cell is sent.
+func (cb *CellBuffer) getContent(x, y int) (rune, []rune, Style, int) {
 	var mainc rune
 	var combc []rune
 	var style Style
 	var width int
 	if x >= 0 && y >= 0 && x < cb.w && y < cb.h {
 		c := &cb.cells[(y*cb.w)+x]
+		if c.cont {
+			return 0, nil, c.currStyle, 0
+		}
 		mainc, combc, style = c.currMain, c.currComb, c.currStyle
 		if width = c.width; width == 0 || mainc < ' ' {
 			width = 1
@@ -88,6 +178,51 @@ func (cb *CellBuffer) Invalidate() {
 	for i := range cb.cells {
 		cb.cells[i].lastMain = rune(0)
 	}
+	cb.addDamage(image.Rect(0, 0, cb.w, cb.h))
+}
+
+// InvalidateRegion marks the cells in the w by h rectangle whose top left
+// corner is at (x, y) as dirty, so that they are drawn again.  If the
+// rectangle starts in the right half of a wide character, that is
+// included too.  The rest of the cells are left as they are.
+func (cb *CellBuffer) InvalidateRegion(x, y, w, h int) {
+	r := image.Rect(x, y, x+w, y+h).Intersect(image.Rect(0, 0, cb.w, cb.h))
+	if r.Empty() {
+		return
+	}
+	for row := r.Min.Y; row < r.Max.Y; row++ {
+		if lx := r.Min.X - 1; lx >= 0 && cb.cells[row*cb.w+lx].width > 1 {
+			cb.cells[row*cb.w+lx].lastMain = rune(0)
+		}
+		for col := r.Min.X; col < r.Max.X; col++ {
+			cb.cells[row*cb.w+col].lastMain = rune(0)
+		}
+	}
+	if r.Min.X > 0 {
+		r.Min.X--
+	}
+	cb.addDamage(r)
+}
+
+// addDamage records that the cells in r have changed.
+func (cb *CellBuffer) addDamage(r image.Rectangle) {
+	cb.damage = cb.damage.Union(r)
+}
+
+// takeDamage returns the bounds of the cells that have changed since it
+// was last called, and resets them.  Any cell that needs to be redrawn
+// lies within the result, so the rest of the buffer can be skipped.
+// The bounds are widened by a cell to the right, so that what is left of
+// a wide character that was overwritten by a narrow one is included.
+// Note that this does not account for cells marked dirty with SetDirty.
+func (cb *CellBuffer) takeDamage() image.Rectangle {
+	r := cb.damage
+	cb.damage = image.Rectangle{}
+	if r.Empty() {
+		return r
+	}
+	r.Max.X++
+	return r.Intersect(image.Rect(0, 0, cb.w, cb.h))
 }
 
 // Dirty checks if a character at the given location needs an
@@ -131,6 +266,9 @@ func (cb *CellBuffer) SetDirty(x, y int, dirty bool) {
 				c.currMain = ' '
 			}
 			c.lastMain = c.currMain
+			if !sameComb(c.lastComb, c.currComb) {
+				cb.freeComb(c.lastComb)
+			}
 			c.lastComb = c.currComb
 			c.lastStyle = c.currStyle
 		}
@@ -155,23 +293,199 @@ func (cb *CellBuffer) Resize(w, h int) {
 			nc.currComb = oc.currComb
 			nc.currStyle = oc.currStyle
 			nc.width = oc.width
+			nc.cont = oc.cont
 			nc.lastMain = rune(0)
 		}
 	}
 	cb.cells = newc
 	cb.h = h
 	cb.w = w
+	if len(cb.combFree) < w {
+		cb.growCombPool(w)
+	}
+	cb.addDamage(image.Rect(0, 0, w, h))
+}
+
+// combCap is the capacity of the combining rune storage in the pool.
+// Characters with more combining runes than this are rare, and have
+// storage allocated just for them.
+const combCap = 4
+
+// setComb sets the combining runes of a cell to a copy of combc.  The
+// storage is taken from the pool, and any that the cell no longer uses is
+// returned to it, so that redrawing combining characters does not cause
+// allocations.  Each piece of storage belongs to a single cell.
+func (cb *CellBuffer) setComb(c *cell, combc []rune) {
+	if len(combc) == len(c.currComb) {
+		same := true
+		for i := range combc {
+			if combc[i] != c.currComb[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return
+		}
+	}
+	old := c.currComb
+	c.currComb = cb.newComb(combc)
+	if !sameComb(old, c.lastComb) {
+		cb.freeComb(old)
+	}
+}
+
+// newComb returns a copy of combc, using storage from the pool.
+func (cb *CellBuffer) newComb(combc []rune) []rune {
+	if len(combc) == 0 {
+		return nil
+	}
+	if len(combc) > combCap {
+		return append([]rune{}, combc...)
+	}
+	if len(cb.combFree) == 0 {
+		cb.growCombPool(cb.w)
+	}
+	n := len(cb.combFree) - 1
+	s := cb.combFree[n]
+	cb.combFree = cb.combFree[:n]
+	return append(s, combc...)
+}
+
+// freeComb returns storage that no cell uses any more to the pool.
+func (cb *CellBuffer) freeComb(s []rune) {
+	if cap(s) == combCap {
+		cb.combFree = append(cb.combFree, s[:0])
+	}
+}
+
+// growCombPool adds storage for n sets of combining runes to the pool,
+// all carved from a single allocation.
+func (cb *CellBuffer) growCombPool(n int) {
+	if n < 16 {
+		n = 16
+	}
+	slab := make([]rune, n*combCap)
+	for i := 0; i < n; i++ {
+		cb.combFree = append(cb.combFree, slab[i*combCap:i*combCap:(i+1)*combCap])
+	}
+}
+
+// sameComb returns true if a and b share the same storage.
+func sameComb(a, b []rune) bool {
+	return len(a) > 0 && len(b) > 0 && &a[0] == &b[0]
 }
 
 // Fill fills the entire cell buffer array with the specified character
 // and style.  Normally choose ' ' to clear the screen.  This API doesn't
 // support combining characters, or characters with a width larger than one.
 func (cb *CellBuffer) Fill(r rune, style Style) {
+	style.off = 0 // as for SetContent
 	for i := range cb.cells {
 		c := &cb.cells[i]
 		c.currMain = r
-		c.currComb = nil
+		cb.setComb(c, nil)
 		c.currStyle = style
 		c.width = 1
+		c.cont = false
+	}
+	cb.addDamage(image.Rect(0, 0, cb.w, cb.h))
+}
+
+// FillRegion fills the w by h rectangle whose top left corner is at
+// (x, y) with the specified character and style.  The rectangle is
+// clipped to the buffer.  Like Fill, this doesn't support combining
+// characters.
+func (cb *CellBuffer) FillRegion(x, y, w, h int, r rune, style Style) {
+	style.off = 0 // as for SetContent
+	if x < 0 {
+		w += x
+		x = 0
+	}
+	if y < 0 {
+		h += y
+		y = 0
+	}
+	if x+w > cb.w {
+		w = cb.w - x
+	}
+	if y+h > cb.h {
+		h = cb.h - y
+	}
+	width := RuneWidth(r)
+	for row := y; row < y+h; row++ {
+		for col := x; col < x+w; col++ {
+			c := &cb.cells[(row*cb.w)+col]
+			c.currMain = r
+			cb.setComb(c, nil)
+			c.currStyle = style
+			c.width = width
+			c.cont = false
+		}
+		cb.fixWide(x, row)
+		cb.fixWide(x+w, row)
+	}
+	if w > 0 && h > 0 {
+		cb.addDamage(image.Rect(x, y, x+w, y+h))
+	}
+}
+
+// CopyRegion copies the contents of the w by h rectangle whose top left
+// corner is at (srcX, srcY) so that its top left corner is at (dstX, dstY).
+// The rectangles may overlap.  Cells that would come from, or go to,
+// outside of the buffer are skipped.
+func (cb *CellBuffer) CopyRegion(srcX, srcY, dstX, dstY, w, h int) {
+	if w <= 0 || h <= 0 {
+		return
+	}
+	// Clip both rectangles to the buffer, and to each other, so that only
+	// cells that are both read and written are visited.
+	bounds := image.Rect(0, 0, cb.w, cb.h)
+	off := image.Pt(dstX-srcX, dstY-srcY)
+	dst := image.Rect(srcX, srcY, srcX+w, srcY+h).Intersect(bounds)
+	dst = dst.Add(off).Intersect(bounds)
+	if dst.Empty() {
+		return
+	}
+	src := dst.Sub(off)
+	w, h = dst.Dx(), dst.Dy()
+	if cap(cb.row) < w {
+		cb.row = make([]cell, w)
+	}
+	row := cb.row[:w]
+
+	// Rows are copied in the order that reads each source row before
+	// it is overwritten.  Within a row, the source is copied aside first,
+	// and the combining runes of the cells that are overwritten are only
+	// returned to the pool afterwards, as the copy may still refer to them.
+	var freed [][]rune
+	for i := 0; i < h; i++ {
+		y := i
+		if dst.Min.Y > src.Min.Y {
+			y = h - 1 - i
+		}
+		so := (src.Min.Y+y)*cb.w + src.Min.X
+		do := (dst.Min.Y+y)*cb.w + dst.Min.X
+		copy(row, cb.cells[so:so+w])
+		freed = freed[:0]
+		for x := range row {
+			sc := &row[x]
+			c := &cb.cells[do+x]
+			if !sameComb(c.currComb, c.lastComb) {
+				freed = append(freed, c.currComb)
+			}
+			c.currMain = sc.currMain
+			c.currComb = cb.newComb(sc.currComb)
+			c.currStyle = sc.currStyle
+			c.width = sc.width
+			c.cont = sc.cont
+		}
+		for _, s := range freed {
+			cb.freeComb(s)
+		}
+		// wide characters may have been cut in half at the edges
+		cb.fixWide(dst.Min.X, dst.Min.Y+y)
+		cb.fixWide(dst.Max.X, dst.Min.Y+y)
 	}
+	cb.addDamage(dst)
 }
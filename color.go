@@ -1025,6 +1025,35 @@ func (c Color) RGB() (int32, int32, int32) {
 	return (v >> 16) & 0xff, (v >> 8) & 0xff, v & 0xff
 }
 
+// ToRGB is like RGB, but returns the components as bytes.  For named and
+// palette colors these are the components of the standard XTerm color,
+// which the terminal may display differently.  If the color is not set,
+// zeros are returned.
+func (c Color) ToRGB() (uint8, uint8, uint8) {
+	v := c.Hex()
+	if v < 0 {
+		return 0, 0, 0
+	}
+	return uint8(v >> 16), uint8(v >> 8), uint8(v)
+}
+
+// ToHex returns the color in the form "#rrggbb", as used by HTML and
+// accepted by GetColor and ColorFromHex.  If the color is not set, the
+// empty string is returned.
+func (c Color) ToHex() string {
+	v := c.Hex()
+	if v < 0 {
+		return ""
+	}
+	const digits = "0123456789abcdef"
+	b := []byte("#000000")
+	for i := 6; i > 0; i-- {
+		b[i] = digits[v&0xf]
+		v >>= 4
+	}
+	return string(b)
+}
+
 // TrueColor returns the true color (RGB) version of the provided color.
 // This is useful for ensuring color accuracy when using named colors.
 // This will override terminal theme colors.
@@ -1063,7 +1092,35 @@ func GetColor(name string) Color {
 	return ColorDefault
 }
 
+// ColorFromHex returns the RGB color given in the form "#rrggbb", or in
+// the short form "#rgb" (where each digit is repeated).  Unlike GetColor,
+// this does not accept color names, and reports malformed values with
+// ErrBadColor.
+func ColorFromHex(s string) (Color, error) {
+	if len(s) == 4 && s[0] == '#' {
+		s = string([]byte{'#', s[1], s[1], s[2], s[2], s[3], s[3]})
+	}
+	if len(s) != 7 || s[0] != '#' {
+		return ColorDefault, ErrBadColor
+	}
+	v, e := strconv.ParseUint(s[1:], 16, 32)
+	if e != nil {
+		return ColorDefault, ErrBadColor
+	}
+	return NewHexColor(int32(v)), nil
+}
+
 // PaletteColor creates a color based on the palette index.
 func PaletteColor(index int) Color {
 	return Color(index) | ColorValid
-}
\ No newline at end of file
+}
+
+// ColorDepth describes how many different colors a terminal can display.
+// The values are the number of colors, so they can be compared.
+type ColorDepth int
+
+const (
+	ColorDepth8    ColorDepth = 8       // the basic ANSI colors (or fewer)
+	ColorDepth256  ColorDepth = 256     // the XTerm 256 color palette
+	ColorDepthTrue ColorDepth = 1 << 24 // 24-bit RGB direct color
+)
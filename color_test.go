@@ -15,6 +15,7 @@
 package tcell
 
 import (
+	"math"
 	"testing"
 )
 
@@ -110,3 +111,163 @@ func TestColorRGB(t *testing.T) {
 		t.Errorf("RGB wrong (%x, %x, %x)", r, g, b)
 	}
 }
+
+func TestNearestColor(t *testing.T) {
+	var values = []struct {
+		color  Color
+		depth  ColorDepth
+		result Color
+	}{
+		{ColorRed, ColorDepth8, ColorMaroon},
+		{ColorRed, ColorDepth(16), ColorRed},
+		{NewRGBColor(0xff, 0, 0), ColorDepth256, ColorRed},
+		{NewRGBColor(0, 0, 0x80), ColorDepth8, ColorNavy},
+		{NewRGBColor(0x12, 0x34, 0x56), ColorDepthTrue, NewRGBColor(0x12, 0x34, 0x56)},
+		{ColorAliceBlue, ColorDepthTrue, NewRGBColor(0xf0, 0xf8, 0xff)},
+		{ColorDefault, ColorDepth8, ColorDefault},
+	}
+	for _, tc := range values {
+		if c := NearestColor(tc.color, tc.depth); c != tc.result {
+			t.Errorf("%x at depth %d: expected %x, got %x",
+				tc.color, tc.depth, tc.result, c)
+		}
+	}
+}
+
+func TestNearestDistinctColor(t *testing.T) {
+	grey := NewRGBColor(0x30, 0x30, 0x30)
+	var values = []struct {
+		color  Color
+		avoid  Color
+		result Color
+	}{
+		{grey, ColorDefault, ColorBlack},
+		{grey, ColorBlack, ColorTeal},
+		{NewRGBColor(0x08, 0x08, 0x08), ColorBlack, ColorBlack},
+		{ColorBlack, ColorBlack, ColorBlack},
+		{NewRGBColor(0xff, 0, 0), ColorBlack, ColorMaroon},
+	}
+	for _, tc := range values {
+		if c := nearestDistinctColor(tc.color, ColorDepth8, tc.avoid); c != tc.result {
+			t.Errorf("%x avoiding %x: expected %x, got %x",
+				tc.color, tc.avoid, tc.result, c)
+		}
+	}
+}
+
+func TestColorHex(t *testing.T) {
+	for _, v := range []int32{0, 0x123456, 0xff8000, 0xffffff} {
+		c := NewHexColor(v)
+		s := c.ToHex()
+		c2, e := ColorFromHex(s)
+		if e != nil || c2 != c {
+			t.Errorf("%06x: %q did not round trip (%x, %v)", v, s, c2, e)
+		}
+		r, g, b := c.ToRGB()
+		if NewRGBColor(int32(r), int32(g), int32(b)) != c {
+			t.Errorf("%06x: RGB %d,%d,%d did not round trip", v, r, g, b)
+		}
+	}
+	if s := ColorRed.ToHex(); s != "#ff0000" {
+		t.Errorf("Bad hex for red %q", s)
+	}
+	if r, g, b := ColorNavy.ToRGB(); r != 0 || g != 0 || b != 0x80 {
+		t.Errorf("Bad RGB for navy %d,%d,%d", r, g, b)
+	}
+	if s := ColorDefault.ToHex(); s != "" {
+		t.Errorf("Default color should have no hex, got %q", s)
+	}
+	if c, e := ColorFromHex("#f80"); e != nil || c != NewHexColor(0xff8800) {
+		t.Errorf("Bad short hex color %x %v", c, e)
+	}
+	for _, s := range []string{"", "red", "#ff00", "ff0000", "#gg0000", "#+12345"} {
+		if _, e := ColorFromHex(s); e != ErrBadColor {
+			t.Errorf("%q: expected ErrBadColor, got %v", s, e)
+		}
+	}
+}
+
+func TestColorHSL(t *testing.T) {
+	var values = []struct {
+		color   Color
+		h, s, l float64
+	}{
+		{ColorRed, 0, 1, 0.5},
+		{NewHexColor(0x00ff00), 120, 1, 0.5},
+		{NewHexColor(0x8080ff), 240, 1, 0.75},
+		{NewHexColor(0x808080), 0, 0, 128.0 / 255},
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 0.001 }
+	for _, tc := range values {
+		h, s, l := tc.color.ToHSL()
+		if !near(h, tc.h) || !near(s, tc.s) || !near(l, tc.l) {
+			t.Errorf("%x: bad HSL %v %v %v", tc.color, h, s, l)
+		}
+		if c := NewHSLColor(tc.h, tc.s, tc.l); c != tc.color.TrueColor() {
+			t.Errorf("%x: HSL gave %x", tc.color, c)
+		}
+	}
+	if c := NewHSLColor(-240, 2, 0.5); c != NewHexColor(0x00ff00) {
+		t.Errorf("Hue should wrap and saturation be clamped, got %x", c)
+	}
+
+	// every color survives the round trip through HSL and HSV
+	for v := int32(0); v < 1<<24; v += 0x010307 {
+		c := NewHexColor(v)
+		if c2 := NewHSLColor(c.ToHSL()); c2 != c {
+			t.Errorf("%06x: HSL round trip gave %x", v, c2)
+		}
+		if c2 := NewHSVColor(c.ToHSV()); c2 != c {
+			t.Errorf("%06x: HSV round trip gave %x", v, c2)
+		}
+	}
+	if h, s, v := NewHexColor(0x0000ff).ToHSV(); h != 240 || s != 1 || v != 1 {
+		t.Errorf("Bad HSV for blue %v %v %v", h, s, v)
+	}
+}
+
+func TestColorLerp(t *testing.T) {
+	black, white := NewHexColor(0), NewHexColor(0xffffff)
+	var values = []struct {
+		c1, c2 Color
+		t      float64
+		result Color
+	}{
+		{black, white, 0, black},
+		{black, white, 1, white},
+		{black, white, 2, white},
+		// the middle is half the light, which is not half the value
+		{black, white, 0.5, NewHexColor(0xbcbcbc)},
+		{ColorRed, ColorBlue, 0.5, NewHexColor(0xbc00bc)},
+		{ColorDefault, white, 0.5, white},
+	}
+	for _, tc := range values {
+		if c := ColorLerp(tc.c1, tc.c2, tc.t); c != tc.result {
+			t.Errorf("%x to %x at %v: expected %x, got %x",
+				tc.c1, tc.c2, tc.t, tc.result, c)
+		}
+	}
+}
+
+func TestColorBlend(t *testing.T) {
+	grey := NewHexColor(0xbcbcbc) // half the light of white
+	var values = []struct {
+		c1, c2 Color
+		mode   BlendMode
+		result Color
+	}{
+		{ColorRed, ColorBlue, BlendAdd, NewHexColor(0xff00ff)},
+		{grey, grey, BlendAdd, NewHexColor(0xffffff)},
+		{ColorYellow, ColorAqua, BlendMultiply, NewHexColor(0x00ff00)},
+		{grey, grey, BlendMultiply, NewHexColor(0x8a8a8a)},
+		{ColorRed, ColorBlue, BlendScreen, NewHexColor(0xff00ff)},
+		{grey, grey, BlendScreen, NewHexColor(0xe1e1e1)},
+		{ColorRed, ColorDefault, BlendMultiply, ColorRed},
+	}
+	for _, tc := range values {
+		if c := ColorBlend(tc.c1, tc.c2, tc.mode); c != tc.result {
+			t.Errorf("%x with %x by %d: expected %x, got %x",
+				tc.c1, tc.c2, tc.mode, tc.result, c)
+		}
+	}
+}
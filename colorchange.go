@@ -0,0 +1,52 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"time"
+)
+
+// EventColorChange is sent when the terminal reports a new default
+// foreground or background color without being asked, as some do when
+// the user changes the color scheme (for example, switching between dark
+// and light modes).  Applications can use this to restyle themselves.
+type EventColorChange struct {
+	t  time.Time
+	fg Color
+	bg Color
+}
+
+// NewEventColorChange returns a new EventColorChange, for the given
+// foreground and background colors.
+func NewEventColorChange(fg, bg Color) *EventColorChange {
+	return &EventColorChange{t: time.Now(), fg: fg, bg: bg}
+}
+
+// When returns the time when this EventColorChange was created.
+func (ev *EventColorChange) When() time.Time {
+	return ev.t
+}
+
+// Foreground returns the terminal's foreground color, or ColorDefault
+// if it is not known.
+func (ev *EventColorChange) Foreground() Color {
+	return ev.fg
+}
+
+// Background returns the terminal's background color, or ColorDefault
+// if it is not known.
+func (ev *EventColorChange) Background() Color {
+	return ev.bg
+}
@@ -25,21 +25,10 @@ import (
 func FindColor(c Color, palette []Color) Color {
 	match := ColorDefault
 	dist := float64(0)
-	r, g, b := c.RGB()
-	c1 := colorful.Color{
-		R: float64(r) / 255.0,
-		G: float64(g) / 255.0,
-		B: float64(b) / 255.0,
-	}
+	c1 := colorfulColor(c)
 	for _, d := range palette {
-		r, g, b = d.RGB()
-		c2 := colorful.Color{
-			R: float64(r) / 255.0,
-			G: float64(g) / 255.0,
-			B: float64(b) / 255.0,
-		}
 		// CIE94 is more accurate, but really really expensive.
-		nd := c1.DistanceCIE76(c2)
+		nd := c1.DistanceCIE76(colorfulColor(d))
 		if math.IsNaN(nd) {
 			nd = math.Inf(1)
 		}
@@ -50,3 +39,84 @@ func FindColor(c Color, palette []Color) Color {
 	}
 	return match
 }
+
+// colorfulColor converts c for use with the colorful package.
+func colorfulColor(c Color) colorful.Color {
+	r, g, b := c.RGB()
+	return colorful.Color{
+		R: float64(r) / 255.0,
+		G: float64(g) / 255.0,
+		B: float64(b) / 255.0,
+	}
+}
+
+// xtermPalette holds the XTerm 256 color palette.  The first 8 or 16
+// entries are also the palette for terminals with fewer colors.
+var xtermPalette = func() []Color {
+	p := make([]Color, 256)
+	for i := range p {
+		p[i] = PaletteColor(i)
+	}
+	return p
+}()
+
+// NearestColor returns the color that best approximates c on a terminal
+// with the given color depth.  For depths other than ColorDepthTrue this
+// is one of the first depth colors of the XTerm palette (so ColorDepth(16)
+// may also be used, for terminals with 16 colors).  For ColorDepthTrue,
+// named colors outside of the palette are converted to RGB values.
+func NearestColor(c Color, depth ColorDepth) Color {
+	if !c.Valid() {
+		return c
+	}
+	if depth >= ColorDepthTrue {
+		if c&ColorIsRGB == 0 && c&^ColorValid >= 256 {
+			return c.TrueColor()
+		}
+		return c
+	}
+	n := int(depth)
+	if n > len(xtermPalette) {
+		n = len(xtermPalette)
+	}
+	if c&ColorIsRGB == 0 && int(c&^ColorValid) < n {
+		return c
+	}
+	return FindColor(c, xtermPalette[:n])
+}
+
+// distinctDistance is the CIE76 distance below which two colors are
+// taken to be too alike for text in one to be read against the other.
+const distinctDistance = 0.15
+
+// nearestDistinctColor is like NearestColor, but it does not choose a
+// palette color that is hard to tell apart from avoid, unless c is itself
+// close to avoid.  This keeps text readable when its color is downsampled
+// for a terminal whose background color is known (or vice versa).  If
+// avoid is not valid, this is the same as NearestColor.
+func nearestDistinctColor(c Color, depth ColorDepth, avoid Color) Color {
+	if !c.Valid() || !avoid.Valid() || depth >= ColorDepthTrue {
+		return NearestColor(c, depth)
+	}
+	n := int(depth)
+	if n > len(xtermPalette) {
+		n = len(xtermPalette)
+	}
+	if c&ColorIsRGB == 0 && int(c&^ColorValid) < n {
+		return c
+	}
+	a := colorfulColor(avoid)
+	if a.DistanceCIE76(colorfulColor(c)) < distinctDistance {
+		return NearestColor(c, depth)
+	}
+	palette := make([]Color, 0, n)
+	for _, p := range xtermPalette[:n] {
+		if a.DistanceCIE76(colorfulColor(p)) >= distinctDistance {
+			palette = append(palette, p)
+		}
+	}
+	if len(palette) == 0 {
+		return NearestColor(c, depth)
+	}
+	return FindColor(c, palette)
+}
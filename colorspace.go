@@ -0,0 +1,134 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"math"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// fromColorful converts a color from the colorful package to an RGB
+// color, clamping it to the sRGB gamut.
+func fromColorful(c colorful.Color) Color {
+	r, g, b := c.Clamped().RGB255()
+	return NewRGBColor(int32(r), int32(g), int32(b))
+}
+
+// normalizeHue returns h, in degrees, in the range [0, 360).
+func normalizeHue(h float64) float64 {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// ToHSL returns the hue (in degrees, from 0 up to 360), saturation and
+// lightness (both from 0 to 1) of the color.  Named and palette colors
+// are converted using their standard RGB values.  If the color is not
+// set, zeros are returned.
+func (c Color) ToHSL() (float64, float64, float64) {
+	if !c.Valid() {
+		return 0, 0, 0
+	}
+	return colorfulColor(c).Hsl()
+}
+
+// NewHSLColor returns the RGB color with the given hue (in degrees),
+// saturation and lightness (both from 0 to 1).  Values out of range are
+// clamped, except for the hue, which wraps around.
+func NewHSLColor(h, s, l float64) Color {
+	return fromColorful(colorful.Hsl(normalizeHue(h), clampUnit(s), clampUnit(l)))
+}
+
+// ToHSV is like ToHSL, but returns the hue, saturation and value.
+func (c Color) ToHSV() (float64, float64, float64) {
+	if !c.Valid() {
+		return 0, 0, 0
+	}
+	return colorfulColor(c).Hsv()
+}
+
+// NewHSVColor is like NewHSLColor, but takes the hue, saturation and
+// value.
+func NewHSVColor(h, s, v float64) Color {
+	return fromColorful(colorful.Hsv(normalizeHue(h), clampUnit(s), clampUnit(v)))
+}
+
+// clampUnit limits v to the range [0, 1].
+func clampUnit(v float64) float64 {
+	return math.Max(0, math.Min(v, 1))
+}
+
+// ColorLerp returns the color a fraction t of the way from c1 to c2,
+// where t is from 0 to 1.  The colors are mixed in linear light, rather
+// than by their gamma-encoded components, which keeps gradients from
+// looking dark in the middle.  The result is an RGB color.  If either
+// color is not set, the other is returned.
+func ColorLerp(c1, c2 Color, t float64) Color {
+	switch {
+	case !c1.Valid():
+		return c2
+	case !c2.Valid():
+		return c1
+	}
+	t = clampUnit(t)
+	r1, g1, b1 := colorfulColor(c1).LinearRgb()
+	r2, g2, b2 := colorfulColor(c2).LinearRgb()
+	return fromColorful(colorful.LinearRgb(
+		r1+t*(r2-r1), g1+t*(g2-g1), b1+t*(b2-b1)))
+}
+
+// BlendMode selects how ColorBlend combines two colors.
+type BlendMode int
+
+const (
+	// BlendAdd adds the colors together, as for overlapping lights.
+	BlendAdd BlendMode = iota
+
+	// BlendMultiply multiplies the colors, which darkens, as for one
+	// filter placed over another.
+	BlendMultiply
+
+	// BlendScreen inverts the colors, multiplies them and inverts the
+	// result, which lightens.  It is the opposite of BlendMultiply.
+	BlendScreen
+)
+
+// ColorBlend combines two colors with the given blend mode.  Like
+// ColorLerp, this works in linear light and returns an RGB color, and if
+// either color is not set, the other is returned.
+func ColorBlend(c1, c2 Color, mode BlendMode) Color {
+	switch {
+	case !c1.Valid():
+		return c2
+	case !c2.Valid():
+		return c1
+	}
+	r1, g1, b1 := colorfulColor(c1).LinearRgb()
+	r2, g2, b2 := colorfulColor(c2).LinearRgb()
+	var blend func(a, b float64) float64
+	switch mode {
+	case BlendMultiply:
+		blend = func(a, b float64) float64 { return a * b }
+	case BlendScreen:
+		blend = func(a, b float64) float64 { return 1 - (1-a)*(1-b) }
+	default:
+		blend = func(a, b float64) float64 { return a + b }
+	}
+	return fromColorful(colorful.LinearRgb(
+		clampUnit(blend(r1, r2)), clampUnit(blend(g1, g2)), clampUnit(blend(b1, b2))))
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+// ComposeMap is a trie of key sequences that compose a single character,
+// as dead keys and compose keys do.  For example, a dead acute accent
+// followed by e composes é.  In a terminal, dead keys generally arrive as
+// the characters they are labeled with, so sequences are given as the
+// runes of KeyRune events.  The zero value is an empty map.
+type ComposeMap struct {
+	next map[rune]*ComposeMap
+	r    rune
+	done bool
+}
+
+// Add adds a sequence of runes that composes the rune r.  A sequence
+// that is a prefix of a longer one is only composed when the key after it
+// does not continue the longer one.
+func (m *ComposeMap) Add(seq []rune, r rune) {
+	node := m
+	for _, k := range seq {
+		if node.next == nil {
+			node.next = make(map[rune]*ComposeMap)
+		}
+		child, ok := node.next[k]
+		if !ok {
+			child = &ComposeMap{}
+			node.next[k] = child
+		}
+		node = child
+	}
+	if node != m {
+		node.r, node.done = r, true
+	}
+}
+
+// Lookup returns the rune composed by a complete sequence.
+func (m *ComposeMap) Lookup(seq []rune) (rune, bool) {
+	node := m
+	for _, k := range seq {
+		if node = node.next[k]; node == nil {
+			return 0, false
+		}
+	}
+	return node.r, node.done
+}
+
+// composer holds back the key events that might be the start of a
+// compose sequence, until it is known whether they are.
+type composer struct {
+	m       *ComposeMap
+	node    *ComposeMap
+	pending []Event
+}
+
+// setMap starts using m, which may be nil to stop composing.  Any held
+// events are returned, so that they are not lost.
+func (c *composer) setMap(m *ComposeMap) []Event {
+	evs := c.flush()
+	c.m = m
+	return evs
+}
+
+// flush returns the held events, and starts again.  If they are a
+// complete sequence, the composed character is returned instead.
+func (c *composer) flush() []Event {
+	evs := c.pending
+	if c.node != nil && c.node.done {
+		evs = []Event{NewEventKey(KeyRune, c.node.r, ModNone)}
+	}
+	c.pending = nil
+	c.node = nil
+	return evs
+}
+
+// filter returns the events that should be posted in place of ev.  Keys
+// that continue a compose sequence are held back, and replaced by the
+// composed character when it is complete.  If the sequence is broken,
+// the keys that were held back are returned as they were.
+func (c *composer) filter(ev Event) []Event {
+	if c.m == nil {
+		return []Event{ev}
+	}
+	kev, ok := ev.(*EventKey)
+	if !ok || kev.Key() != KeyRune || kev.Modifiers()&^ModShift != 0 {
+		return append(c.flush(), ev)
+	}
+	node := c.node
+	if node == nil {
+		node = c.m
+	}
+	next := node.next[kev.Rune()]
+	if next == nil {
+		if c.node == nil {
+			return []Event{ev}
+		}
+		// This might start a new sequence.
+		return append(c.flush(), c.filter(ev)...)
+	}
+	c.pending = append(c.pending, ev)
+	c.node = next
+	if len(next.next) != 0 {
+		return nil
+	}
+	return c.flush()
+}
@@ -17,12 +17,15 @@
 package tcell
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"image"
 	"os"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 	"unicode/utf16"
 	"unsafe"
 )
@@ -53,6 +56,23 @@ type cScreen struct {
 
 	finiOnce sync.Once
 
+	cursorStyle  CursorStyle
+	softCursor   softCursor
+	batch        batch
+	ticker       ticker
+	opts         ScreenOptions
+	frameTime    time.Duration
+	lastFrame    time.Time // when the last frame was drawn
+	framePending bool      // a Show is waiting for the frame time to pass
+	buttons      ButtonMask
+	scroll       scrollAccum
+	compose      composer
+
+	bellMode BellMode
+	bellTime time.Duration
+	flashes  int
+	flashing bool
+
 	mouseEnabled bool
 	wg           sync.WaitGroup
 	stopQ        chan struct{}
@@ -127,6 +147,7 @@ var (
 	procSetConsoleWindowInfo       = k32.NewProc("SetConsoleWindowInfo")
 	procSetConsoleScreenBufferSize = k32.NewProc("SetConsoleScreenBufferSize")
 	procSetConsoleTextAttribute    = k32.NewProc("SetConsoleTextAttribute")
+	procSetConsoleTitle            = k32.NewProc("SetConsoleTitleW")
 	procMessageBeep                = u32.NewProc("MessageBeep")
 )
 
@@ -145,10 +166,17 @@ const (
 	vtUnderline  = "\x1b[4m"
 	vtBlink      = "\x1b[5m" // Not sure this is processed
 	vtReverse    = "\x1b[7m"
+	vtInvisible  = "\x1b[8m"
 	vtSetFg      = "\x1b[38;5;%dm"
 	vtSetBg      = "\x1b[48;5;%dm"
 	vtSetFgRGB   = "\x1b[38;2;%d;%d;%dm" // RGB
 	vtSetBgRGB   = "\x1b[48;2;%d;%d;%dm" // RGB
+
+	vtCursorStyle = "\x1b[%d q" // DECSCUSR
+	vtHardReset   = "\x1bc"     // RIS
+	vtSoftReset   = "\x1b[!p"   // DECSTR
+	vtFlashOn     = "\x1b[?5h"  // DECSCNM
+	vtFlashOff    = "\x1b[?5l"
 )
 
 // NewConsoleScreen returns a Screen for the Windows console associated
@@ -159,7 +187,7 @@ func NewConsoleScreen() (Screen, error) {
 }
 
 func (s *cScreen) Init() error {
-	s.evch = make(chan Event, 10)
+	s.evch = make(chan Event, s.opts.eventQueueSize())
 	s.quit = make(chan struct{})
 	s.scandone = make(chan struct{})
 
@@ -261,6 +289,12 @@ func (s *cScreen) EnablePaste() {}
 
 func (s *cScreen) DisablePaste() {}
 
+// Nor does it report focus changes to us.
+
+func (s *cScreen) EnableFocus() {}
+
+func (s *cScreen) DisableFocus() {}
+
 func (s *cScreen) Fini() {
 	s.disengage()
 }
@@ -275,6 +309,7 @@ func (s *cScreen) disengage() {
 	s.stopQ = nil
 	procSetEvent.Call(uintptr(s.cancelflag))
 	close(stopQ)
+	s.ticker.stop()
 	s.Unlock()
 
 	s.wg.Wait()
@@ -283,6 +318,9 @@ func (s *cScreen) disengage() {
 	s.setOutMode(s.oomode)
 	s.setBufferSize(int(s.oscreen.size.x), int(s.oscreen.size.y))
 	s.clearScreen(StyleDefault, false)
+	if s.vten {
+		s.emitVtString(fmt.Sprintf(vtCursorStyle, int(CursorStyleDefault)))
+	}
 	s.setCursorPos(0, 0, false)
 	s.setCursorInfo(&s.ocursor)
 	procSetConsoleTextAttribute.Call(
@@ -318,10 +356,15 @@ func (s *cScreen) engage() error {
 	s.hideCursor()
 
 	s.cells.Invalidate()
-	s.hideCursor()
-	s.resize()
-	s.draw()
-	s.doCursor()
+	// in a transaction, this is put off until it ends, like Sync
+	if !s.batch.hold(true) {
+		s.hideCursor()
+		s.resize()
+		s.draw()
+		s.doCursor()
+	}
+
+	s.ticker.start(s.PostEvent)
 
 	s.wg.Add(1)
 	go s.scanInput(s.stopQ)
@@ -341,6 +384,150 @@ func (s *cScreen) PostEvent(ev Event) error {
 	}
 }
 
+func (s *cScreen) ColorDepth() ColorDepth {
+	if s.vten && s.truecolor {
+		return ColorDepthTrue
+	}
+	return ColorDepth8
+}
+
+func (s *cScreen) TermInfo() TermCapabilities {
+	s.Lock()
+	defer s.Unlock()
+	return TermCapabilities{
+		TrueColor: s.truecolor,
+		Colors256: s.vten,
+		Mouse:     true,
+	}
+}
+
+func (s *cScreen) DrawSixel(int, int, image.Image) error {
+	return ErrNoGraphics
+}
+
+func (s *cScreen) DrawImage(int, int, int, int, image.Image, uint32) error {
+	return nil
+}
+
+func (s *cScreen) DeleteImage(uint32) {}
+
+func (s *cScreen) DrawPixelImage(x, y, w, h int, img image.Image, mode PixelMode) {
+	drawPixelImage(s, x, y, w, h, img, mode)
+}
+
+func (s *cScreen) DrawText(x, y int, style Style, text string) int {
+	return drawText(s, x, y, style, text)
+}
+
+func (s *cScreen) DrawBox(x, y, w, h int, style Style, border BoxStyle) {
+	drawBox(s, x, y, w, h, style, border)
+}
+
+func (s *cScreen) DrawHLine(x, y, w int, style Style, border BoxStyle) {
+	drawHLine(s, x, y, w, style, border)
+}
+
+func (s *cScreen) DrawVLine(x, y, h int, style Style, border BoxStyle) {
+	drawVLine(s, x, y, h, style, border)
+}
+
+func (s *cScreen) SetCrossing(x, y int, style Style, border BoxStyle) {
+	setCrossing(s, x, y, style, border)
+}
+
+func (s *cScreen) DrawANSI(x, y int, str string, base Style) (int, error) {
+	return drawANSI(s, x, y, str, base)
+}
+
+// SetResizeDebounce does nothing, as the console reports resizes
+// through the same input queue as everything else.
+func (s *cScreen) SetResizeDebounce(time.Duration) {}
+
+// postInput posts an input event, after accumulating scrolling and
+// composing characters.
+func (s *cScreen) postInput(ev Event) {
+	var evs []Event
+	s.Lock()
+	if ev = s.scroll.filter(ev); ev != nil {
+		evs = s.compose.filter(ev)
+	}
+	s.Unlock()
+	for _, ev := range evs {
+		s.PostEventWait(ev)
+	}
+}
+
+// TermVersion returns nothing, as the console cannot be asked.
+func (s *cScreen) TermVersion() (string, string) {
+	return "", ""
+}
+
+func (s *cScreen) DebugInfo() string {
+	s.Lock()
+	defer s.Unlock()
+	var d debugInfo
+	d.str("driver", "console")
+	d.flag("vt", s.vten)
+	d.num("color_depth", int(s.ColorDepth()))
+	d.num("width", s.w)
+	d.num("height", s.h)
+	d.flag("mouse", s.mouseEnabled)
+	return d.String()
+}
+
+func (s *cScreen) SetComposeMap(m *ComposeMap) {
+	s.Lock()
+	evs := s.compose.setMap(m)
+	s.Unlock()
+	for _, ev := range evs {
+		_ = s.PostEvent(ev)
+	}
+}
+
+// SetEscapeTimeout does nothing, as the console reports whole keys.
+func (s *cScreen) SetEscapeTimeout(time.Duration) {}
+
+func (s *cScreen) SetScrollAccumThreshold(lines int) {
+	s.Lock()
+	s.scroll = scrollAccum{threshold: lines}
+	s.Unlock()
+}
+
+func (s *cScreen) MouseButtonState() ButtonMask {
+	s.Lock()
+	defer s.Unlock()
+	return s.buttons
+}
+
+func (s *cScreen) TParm(string, ...int) error {
+	return ErrNoCapability
+}
+
+func (s *cScreen) SetMaxFPS(fps int) {
+	s.Lock()
+	s.frameTime = frameTime(fps)
+	s.Unlock()
+}
+
+func (s *cScreen) SetTicker(d time.Duration) {
+	s.Lock()
+	s.ticker.d = d
+	if s.stopQ != nil {
+		s.ticker.start(s.PostEvent)
+	} else {
+		s.ticker.stop()
+	}
+	s.Unlock()
+}
+
+func (s *cScreen) setOptions(opts ScreenOptions) {
+	s.opts = opts
+}
+
+func (s *cScreen) PostFunc(f func()) error {
+	return s.PostEvent(NewEventFunc(f))
+}
+
 func (s *cScreen) PollEvent() Event {
 	select {
 	case <-s.stopQ:
@@ -350,6 +537,25 @@ func (s *cScreen) PollEvent() Event {
 	}
 }
 
+func (s *cScreen) PollEventContext(ctx context.Context) (Event, error) {
+	select {
+	case <-s.stopQ:
+		return nil, nil
+	case ev := <-s.evch:
+		return ev, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *cScreen) PollEventFor(d time.Duration) (Event, error) {
+	return pollEventFor(s.evch, s.stopQ, d)
+}
+
+func (s *cScreen) HasPendingEvent() bool {
+	return len(s.evch) > 0
+}
+
 type cursorInfo struct {
 	size    uint32
 	visible uint32
@@ -379,6 +585,7 @@ func (s *cScreen) emitVtString(vs string) {
 
 func (s *cScreen) showCursor() {
 	if s.vten {
+		s.emitVtString(fmt.Sprintf(vtCursorStyle, int(s.cursorStyle)))
 		s.emitVtString(vtShowCursor)
 	} else {
 		s.setCursorInfo(&cursorInfo{size: 100, visible: 1})
@@ -398,6 +605,7 @@ func (s *cScreen) ShowCursor(x, y int) {
 	if !s.fini {
 		s.curx = x
 		s.cury = y
+		s.softCursor.on = false
 	}
 	s.doCursor()
 	s.Unlock()
@@ -418,6 +626,25 @@ func (s *cScreen) HideCursor() {
 	s.ShowCursor(-1, -1)
 }
 
+func (s *cScreen) SetSoftCursor(x, y int, style Style, r rune) {
+	s.Lock()
+	if !s.fini {
+		s.curx, s.cury = -1, -1
+		s.softCursor = softCursor{on: true, x: x, y: y, style: style, r: r}
+		s.doCursor()
+	}
+	s.Unlock()
+}
+
+func (s *cScreen) SetCursorStyle(cs CursorStyle) {
+	s.Lock()
+	if !s.fini {
+		s.cursorStyle = cs
+		s.doCursor()
+	}
+	s.Unlock()
+}
+
 type inputRecord struct {
 	typ  uint16
 	_    uint16
@@ -685,10 +912,10 @@ func (s *cScreen) getConsoleInput() error {
 				for krec.repeat > 0 {
 					// convert shift+tab to backtab
 					if mod2mask(krec.mod) == ModShift && krec.ch == vkTab {
-						s.PostEventWait(NewEventKey(KeyBacktab, 0,
+						s.postInput(NewEventKey(KeyBacktab, 0,
 							ModNone))
 					} else {
-						s.PostEventWait(NewEventKey(KeyRune, rune(krec.ch),
+						s.postInput(NewEventKey(KeyRune, rune(krec.ch),
 							mod2mask(krec.mod)))
 					}
 					krec.repeat--
@@ -701,7 +928,7 @@ func (s *cScreen) getConsoleInput() error {
 				return nil
 			}
 			for krec.repeat > 0 {
-				s.PostEventWait(NewEventKey(key, rune(krec.ch),
+				s.postInput(NewEventKey(key, rune(krec.ch),
 					mod2mask(krec.mod)))
 				krec.repeat--
 			}
@@ -714,9 +941,14 @@ func (s *cScreen) getConsoleInput() error {
 			mrec.mod = getu32(rec.data[8:])
 			mrec.flags = getu32(rec.data[12:])
 			btns := mrec2btns(mrec.btns, mrec.flags)
+			s.Lock()
+			s.buttons = btns & buttonsHeld
+			s.Unlock()
 			// we ignore double click, events are delivered normally
-			s.PostEventWait(NewEventMouse(int(mrec.x), int(mrec.y), btns,
-				mod2mask(mrec.mod)))
+			ev := NewEventMouse(int(mrec.x), int(mrec.y), btns,
+				mod2mask(mrec.mod))
+			ev.motion = mrec.flags&mouseMoved != 0
+			s.postInput(ev)
 
 		case resizeEvent:
 			var rrec resizeRecord
@@ -803,6 +1035,10 @@ func (s *cScreen) mapStyle(style Style) uint16 {
 	if b != ColorDefault && b != ColorReset {
 		ba = mapColor2RGB(b)
 	}
+	if a&AttrInvisible != 0 {
+		// the legacy console cannot conceal text, so hide it instead
+		fa = ba
+	}
 	var attr uint16
 	// We simulate reverse by doing the color swap ourselves.
 	// Apparently windows cannot really do this except in DBCS
@@ -844,6 +1080,16 @@ func (s *cScreen) SetContent(x, y int, mainc rune, combc []rune, style Style) {
 	s.Unlock()
 }
 
+func (s *cScreen) SetCells(cells []CellUpdate) {
+	s.Lock()
+	if !s.fini {
+		for _, c := range cells {
+			s.cells.SetContent(c.X, c.Y, c.R, c.Combining, c.Style)
+		}
+	}
+	s.Unlock()
+}
+
 func (s *cScreen) GetContent(x, y int) (rune, []rune, Style, int) {
 	s.Lock()
 	mainc, combc, style, width := s.cells.GetContent(x, y)
@@ -870,6 +1116,9 @@ func (s *cScreen) sendVtStyle(style Style) {
 	if attrs&AttrReverse != 0 {
 		esc.WriteString(vtReverse)
 	}
+	if attrs&AttrInvisible != 0 {
+		esc.WriteString(vtInvisible)
+	}
 	if fg.IsRGB() {
 		r, g, b := fg.RGB()
 		fmt.Fprintf(esc, vtSetFgRGB, r, g, b)
@@ -903,13 +1152,22 @@ func (s *cScreen) writeString(x, y int, style Style, ch []uint16) {
 }
 
 func (s *cScreen) draw() {
-	// allocate a scratch line bit enough for no combining chars.
-	// if you have combining characters, you may pay for extra allocs.
+	s.drawRect(image.Rect(0, 0, s.w, s.h))
+}
+
+// drawRect draws the cells within r that have changed, and leaves the
+// rest to be drawn another time.
+func (s *cScreen) drawRect(r image.Rectangle) {
 	if s.clear {
 		s.clearScreen(s.style, s.vten)
 		s.clear = false
 		s.cells.Invalidate()
 	}
+	// the soft cursor is drawn as part of the cells
+	defer s.softCursor.apply(&s.cells)()
+
+	// allocate a scratch line bit enough for no combining chars.
+	// if you have combining characters, you may pay for extra allocs.
 	buf := make([]uint16, 0, s.w)
 	wcs := buf[:]
 	lstyle := styleInvalid
@@ -917,9 +1175,9 @@ func (s *cScreen) draw() {
 	lx, ly := -1, -1
 	ra := make([]rune, 1)
 
-	for y := 0; y < s.h; y++ {
-		for x := 0; x < s.w; x++ {
-			mainc, combc, style, width := s.cells.GetContent(x, y)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			mainc, combc, style, width := s.cells.getContent(x, y)
 			dirty := s.cells.Dirty(x, y)
 			if style == StyleDefault {
 				style = s.style
@@ -964,25 +1222,118 @@ func (s *cScreen) draw() {
 
 func (s *cScreen) Show() {
 	s.Lock()
-	if !s.fini {
-		s.hideCursor()
-		s.resize()
-		s.draw()
-		s.doCursor()
+	defer s.Unlock()
+	if s.batch.hold(false) || s.fini {
+		return
 	}
-	s.Unlock()
+	// Too soon after the last frame, so draw this one when the frame
+	// time is up, taking in whatever else is shown before then.
+	if wait := s.frameTime - time.Since(s.lastFrame); wait > 0 {
+		if !s.framePending {
+			s.framePending = true
+			time.AfterFunc(wait, s.showPending)
+		}
+		return
+	}
+	s.showFrame()
+}
+
+// showPending draws a frame that Show put off to keep to the frame rate,
+// unless something else has drawn it already.
+func (s *cScreen) showPending() {
+	s.Lock()
+	defer s.Unlock()
+	if !s.framePending {
+		return
+	}
+	s.framePending = false
+	if s.batch.hold(false) || s.fini {
+		return
+	}
+	s.showFrame()
+}
+
+// showFrame draws the changes to the screen.  It is called with the lock
+// held.
+func (s *cScreen) showFrame() {
+	s.lastFrame = time.Now()
+	s.framePending = false
+	s.hideCursor()
+	s.resize()
+	s.draw()
+	s.doCursor()
 }
 
 func (s *cScreen) Sync() {
 	s.Lock()
+	if s.batch.hold(true) {
+		s.Unlock()
+		return
+	}
 	if !s.fini {
 		s.cells.Invalidate()
-		s.hideCursor()
-		s.resize()
+		s.showFrame()
+	}
+	s.Unlock()
+}
+
+func (s *cScreen) SyncRegion(x, y, w, h int) {
+	s.Lock()
+	defer s.Unlock()
+	s.cells.InvalidateRegion(x, y, w, h)
+	if s.batch.hold(false) || s.fini {
+		return
+	}
+	s.hideCursor()
+	s.resize()
+	if s.clear {
+		// the whole screen has to be drawn anyway
 		s.draw()
-		s.doCursor()
+	} else {
+		// include the left half of a wide character, as
+		// InvalidateRegion does
+		s.drawRect(image.Rect(x-1, y, x+w, y+h).Intersect(image.Rect(0, 0, s.w, s.h)))
+	}
+	s.doCursor()
+}
+
+func (s *cScreen) Transaction(f func()) {
+	transaction(s, &s.batch, f, s.Show, s.Sync)
+}
+
+// HardReset resets the console, if it is using virtual terminal
+// sequences, and then redraws everything.  The legacy console has nothing
+// that can get into a bad state, so for it this is the same as Sync.
+func (s *cScreen) HardReset() error {
+	s.Lock()
+	vten := s.vten && !s.fini && s.stopQ != nil
+	if vten {
+		s.emitVtString(vtHardReset)
+	}
+	s.Unlock()
+	if vten {
+		time.Sleep(100 * time.Millisecond)
+		s.Lock()
+		if !s.fini {
+			s.setOutMode(modeVtOutput | modeNoAutoNL | modeCookedOut)
+			s.clearScreen(s.style, s.vten)
+		}
+		s.Unlock()
+	}
+	s.Sync()
+	return nil
+}
+
+// SoftReset resets the console, if it is using virtual terminal
+// sequences, and then redraws everything.
+func (s *cScreen) SoftReset() error {
+	s.Lock()
+	if s.vten && !s.fini && s.stopQ != nil {
+		s.emitVtString(vtSoftReset)
 	}
 	s.Unlock()
+	s.Sync()
+	return nil
 }
 
 type consoleInfo struct {
@@ -1075,6 +1426,22 @@ func (s *cScreen) Fill(r rune, style Style) {
 	s.Unlock()
 }
 
+func (s *cScreen) CopyRegion(srcX, srcY, dstX, dstY, w, h int) {
+	s.Lock()
+	if !s.fini {
+		s.cells.CopyRegion(srcX, srcY, dstX, dstY, w, h)
+	}
+	s.Unlock()
+}
+
+func (s *cScreen) FillRegion(x, y, w, h int, r rune, style Style) {
+	s.Lock()
+	if !s.fini {
+		s.cells.FillRegion(x, y, w, h, r, style)
+	}
+	s.Unlock()
+}
+
 func (s *cScreen) clearScreen(style Style, vtEnable bool) {
 	if vtEnable {
 		s.sendVtStyle(style)
@@ -1221,6 +1588,15 @@ func (s *cScreen) HasKey(k Key) bool {
 }
 
 func (s *cScreen) Beep() error {
+	s.Lock()
+	mode, d := s.bellMode, s.bellTime
+	s.Unlock()
+	if mode != BellAudio {
+		s.flash(d)
+		if mode == BellVisual {
+			return nil
+		}
+	}
 	// A simple beep. If the sound card is not available, the sound is generated
 	// using the speaker.
 	//
@@ -1233,6 +1609,52 @@ func (s *cScreen) Beep() error {
 	return nil
 }
 
+func (s *cScreen) SetBellMode(mode BellMode) {
+	s.Lock()
+	s.bellMode = mode
+	s.Unlock()
+}
+
+func (s *cScreen) SetVisualBellDuration(d time.Duration) {
+	s.Lock()
+	s.bellTime = d
+	s.Unlock()
+}
+
+func (s *cScreen) Flash(d time.Duration) {
+	s.flash(d)
+}
+
+// flash reverses the screen for a while, as for tScreen.  This is only
+// possible when using virtual terminal sequences.
+func (s *cScreen) flash(d time.Duration) {
+	if d == 0 {
+		d = defaultFlashTime
+	}
+	s.Lock()
+	defer s.Unlock()
+	if !s.vten || s.fini || s.stopQ == nil {
+		return
+	}
+	s.flashes++
+	s.flashing = true
+	n := s.flashes
+	s.emitVtString(vtFlashOn)
+	time.AfterFunc(d, func() {
+		s.Lock()
+		defer s.Unlock()
+		if s.flashing && s.flashes == n {
+			s.emitVtString(vtFlashOff)
+			s.flashing = false
+		}
+	})
+}
+
+// SetTermDriver fails, as the console has no TermDriver.
+func (s *cScreen) SetTermDriver(TermDriver) error {
+	return ErrNoScreen
+}
+
 func (s *cScreen) Suspend() error {
 	s.disengage()
 	return nil
@@ -1241,3 +1663,61 @@ func (s *cScreen) Suspend() error {
 func (s *cScreen) Resume() error {
 	return s.engage()
 }
+
+func (s *cScreen) SetClipboard(string, []byte) error {
+	return ErrNoClipboard
+}
+
+func (s *cScreen) GetClipboard(string) ([]byte, error) {
+	return nil, ErrNoClipboard
+}
+
+func (s *cScreen) QueryForegroundColor() (Color, error) {
+	return ColorDefault, ErrNoCapability
+}
+
+func (s *cScreen) ForegroundColor() Color {
+	return ColorDefault
+}
+
+func (s *cScreen) QueryBackgroundColor() (Color, error) {
+	return ColorDefault, ErrNoCapability
+}
+
+func (s *cScreen) BackgroundColor() Color {
+	return ColorDefault
+}
+
+func (s *cScreen) SetTitle(title string) {
+	if p, err := syscall.UTF16PtrFromString(title); err == nil {
+		procSetConsoleTitle.Call(uintptr(unsafe.Pointer(p)))
+	}
+}
+
+// Windows has no separate icon title.
+
+func (s *cScreen) SetIconTitle(string) {}
+
+// SetColumns uses DECCOLM, which the console only understands when it
+// accepts virtual terminal sequences.
+func (s *cScreen) SetColumns(n int) error {
+	seq, err := deccolm(n)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	if !s.vten || s.fini || s.stopQ == nil {
+		return ErrNoCapability
+	}
+	s.emitVtString(seq)
+	s.resize()
+	s.clear = true
+	s.cells.Invalidate()
+	// in a transaction, the next Show has to be a Sync
+	s.batch.hold(true)
+	if s.w != n {
+		return ErrNoCapability
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// debugInfo builds the text returned by Screen.DebugInfo.  This is laid
+// out like TOML, one "key = value" line for each item, so that it is easy
+// to read, and also easy for tools to pick apart.
+type debugInfo struct {
+	strings.Builder
+}
+
+func (d *debugInfo) str(key, value string) {
+	d.WriteString(key + " = " + strconv.Quote(value) + "\n")
+}
+
+func (d *debugInfo) num(key string, value int) {
+	d.WriteString(key + " = " + strconv.Itoa(value) + "\n")
+}
+
+func (d *debugInfo) flag(key string, value bool) {
+	d.WriteString(key + " = " + strconv.FormatBool(value) + "\n")
+}
+
+// table adds a table of string values, in order of their keys.  It
+// must come after all the plain items.
+func (d *debugInfo) table(name string, values map[string]string) {
+	d.WriteString("\n[" + name + "]\n")
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		d.str(strconv.Quote(k), values[k])
+	}
+}
@@ -14,7 +14,9 @@ var ErrWinSizeUnused = errors.New("driver does not provide WinSize")
 type TermDriver interface {
 	// Init sets up two file TTY/PTY file descriptors, which may be the same
 	// in some cases. It also takes a chan that is used to notify the Screen
-	// refresh the window size.
+	// refresh the window size.  The files may also be pipes, such as
+	// for a driver that relays a terminal over a network connection, in
+	// which case the terminal modes are left to the far end.
 	Init(winch chan os.Signal) (in *os.File, out *os.File, err error)
 
 	// WinSize returns the current window width and height. It can also return
@@ -26,6 +28,46 @@ type TermDriver interface {
 	Disengage()
 }
 
+// TermDriverWinSizeNotifier may be implemented by a TermDriver that cannot
+// raise a real SIGWINCH, such as one serving a pseudoterminal for an SSH
+// session.  NotifyWinSize is called once the driver is initialized, with
+// a channel that the driver can send on whenever the window size has
+// changed.
+type TermDriverWinSizeNotifier interface {
+	NotifyWinSize(winsize chan<- struct{})
+}
+
+// TermDriverPinger may be implemented by a TermDriver whose connection to
+// the terminal can go away, such as a network session.  Ping checks that
+// the connection is still alive.  It is called periodically if
+// ScreenOptions.PingInterval is set, and if it returns an error, the
+// Screen posts an EventError and shuts itself down.
+type TermDriverPinger interface {
+	Ping() error
+}
+
+// TermDriverCellSizer may be implemented by a TermDriver that knows the
+// size of the terminal's character cells in pixels.  This is needed to
+// find the cells that mouse events reported in pixels are in, so without
+// it MousePixelMotion reports positions in cells only.  CellSize returns
+// zeros if the size is not known.  It is called whenever the window size
+// may have changed.
+type TermDriverCellSizer interface {
+	CellSize() (width int, height int)
+}
+
+// TermDriverReconnector may be implemented by a TermDriver whose
+// connection can drop and later be re-established, such as an SSH
+// session.  When reading input fails, the Screen calls Reconnect, which
+// should block until the connection is back (or return an error if it
+// never will be).  Init is then called again to obtain the new files
+// (the driver is responsible for closing the old ones), the terminal
+// modes are restored, the display is completely redrawn, and an
+// EventResize is posted so that the application can refresh.
+type TermDriverReconnector interface {
+	Reconnect() error
+}
+
 // defaultTermDriver is what's used when you don't specify a custom TermDriver
 type defaultTermDriver struct {
 	winch chan os.Signal
@@ -0,0 +1,26 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pty provides a tcell TermDriver that displays on a new
+// pseudoterminal, rather than the one the application was started from.
+// The application itself plays the part of the terminal, by reading
+// and writing the master side of the pair, which suits programs such as
+// multiplexers that embed a terminal emulator.  For example:
+//
+//	d, err := pty.NewTermDriver("xterm-256color")
+//	s, err := tcell.NewTerminfoScreenWithOptions(d, tcell.ScreenOptions{})
+//	go emulate(d.Master())
+//
+// Pseudoterminals are not available on Windows.
+package pty
@@ -0,0 +1,113 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !windows
+
+package pty
+
+import (
+	"os"
+	"sync"
+
+	cpty "github.com/creack/pty"
+)
+
+// TermDriver is a tcell.TermDriver for the slave side of a
+// pseudoterminal pair.
+type TermDriver struct {
+	term    string
+	master  *os.File
+	slave   *os.File
+	winsize chan<- struct{}
+	mu      sync.Mutex
+}
+
+// NewTermDriver opens a new pseudoterminal pair, for a terminal of the
+// given type, such as "xterm-256color".  Its size is initially 80 by 24.
+func NewTermDriver(term string) (*TermDriver, error) {
+	master, slave, err := cpty.Open()
+	if err != nil {
+		return nil, err
+	}
+	if err = cpty.Setsize(master, &cpty.Winsize{Cols: 80, Rows: 24}); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, err
+	}
+	return &TermDriver{term: term, master: master, slave: slave}, nil
+}
+
+// Master returns the master side of the pair.  What the screen displays
+// is read from it, and input for the screen is written to it.
+func (d *TermDriver) Master() *os.File {
+	return d.master
+}
+
+// Resize changes the size of the pseudoterminal, and lets the screen
+// know about it.
+func (d *TermDriver) Resize(cols, rows int) error {
+	err := cpty.Setsize(d.master, &cpty.Winsize{Cols: uint16(cols), Rows: uint16(rows)})
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	winsize := d.winsize
+	d.mu.Unlock()
+	if winsize != nil {
+		select {
+		case winsize <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Close closes both sides of the pair.  This should be done after the
+// screen is finalized.
+func (d *TermDriver) Close() error {
+	err := d.slave.Close()
+	if e := d.master.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// Init returns the slave side of the pair, for both input and output.
+func (d *TermDriver) Init(winch chan os.Signal) (*os.File, *os.File, error) {
+	return d.slave, d.slave, nil
+}
+
+// WinSize returns the size of the pseudoterminal.
+func (d *TermDriver) WinSize() (int, int, error) {
+	rows, cols, err := cpty.Getsize(d.master)
+	return cols, rows, err
+}
+
+// GetTerm returns the terminal type given to NewTermDriver.
+func (d *TermDriver) GetTerm() string {
+	return d.term
+}
+
+// Engage does nothing.
+func (d *TermDriver) Engage() {}
+
+// Disengage does nothing.
+func (d *TermDriver) Disengage() {}
+
+// NotifyWinSize arranges for calls to Resize to be reported on winsize.
+func (d *TermDriver) NotifyWinSize(winsize chan<- struct{}) {
+	d.mu.Lock()
+	d.winsize = winsize
+	d.mu.Unlock()
+}
@@ -0,0 +1,73 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !windows
+
+package pty
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+var _ tcell.TermDriver = &TermDriver{}
+var _ tcell.TermDriverWinSizeNotifier = &TermDriver{}
+
+func TestTermDriver(t *testing.T) {
+	d, err := NewTermDriver("xterm")
+	if err != nil {
+		t.Skipf("No pseudoterminal: %v", err)
+	}
+	defer d.Close()
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, d.Master())
+	}()
+
+	if w, h, err := d.WinSize(); w != 80 || h != 24 || err != nil {
+		t.Errorf("Bad initial size %dx%d (%v)", w, h, err)
+	}
+
+	s, err := tcell.NewTerminfoScreenWithOptions(d, tcell.ScreenOptions{})
+	if err != nil {
+		t.Fatalf("Failed to make screen: %v", err)
+	}
+	if err = s.Init(); err != nil {
+		t.Fatalf("Failed to initialize screen: %v", err)
+	}
+	defer s.Fini()
+
+	if err = d.Resize(100, 30); err != nil {
+		t.Fatalf("Resize failed: %v", err)
+	}
+	_, _ = d.Master().Write([]byte("x"))
+	deadline := time.After(time.Second)
+	for {
+		ev, _ := s.PollEventFor(100 * time.Millisecond)
+		if ev, ok := ev.(*tcell.EventKey); ok && ev.Rune() == 'x' {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("Key not received")
+		default:
+		}
+	}
+	if w, h := s.Size(); w != 100 || h != 30 {
+		t.Errorf("Bad screen size %dx%d", w, h)
+	}
+}
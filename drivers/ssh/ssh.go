@@ -0,0 +1,209 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ssh provides a tcell TermDriver for the sessions of an SSH
+// server built with golang.org/x/crypto/ssh, so that a tcell application
+// can be served to SSH clients.  For example:
+//
+//	d := ssh.NewTermDriver(ch, reqs)
+//	s, err := tcell.NewTerminfoScreenWithOptions(d, tcell.ScreenOptions{})
+//
+// where ch and reqs are what NewChannel.Accept returned for a "session"
+// channel.
+package ssh
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// ErrNoPty is returned by Init if the client closed the session without
+// asking for a pseudoterminal.
+var ErrNoPty = errors.New("ssh: no pseudoterminal requested")
+
+// TermDriver is a tcell.TermDriver for an SSH session channel.  The
+// terminal type and size are taken from the client's "pty-req" request,
+// and the size is updated by its "window-change" requests.
+type TermDriver struct {
+	ch      gossh.Channel
+	ptyReq  chan struct{} // closed once pty-req arrives, or never will
+	term    string
+	hasPty  bool
+	w       int
+	h       int
+	pw      int // width in pixels, or zero if unknown
+	ph      int // height in pixels, or zero if unknown
+	winsize chan<- struct{}
+	mu      sync.Mutex
+}
+
+// NewTermDriver returns a TermDriver for the session channel ch.  The
+// requests for the channel must be passed as reqs, as the driver handles
+// them from now on: pty-req, window-change, shell and env are accepted,
+// and any others are refused.  The channel is not closed by the driver.
+func NewTermDriver(ch gossh.Channel, reqs <-chan *gossh.Request) *TermDriver {
+	d := &TermDriver{
+		ch:     ch,
+		ptyReq: make(chan struct{}),
+	}
+	go d.handleRequests(reqs)
+	return d
+}
+
+// ptyRequest is the payload of a pty-req request, from RFC 4254.
+type ptyRequest struct {
+	Term   string
+	Cols   uint32
+	Rows   uint32
+	Width  uint32
+	Height uint32
+	Modes  string
+}
+
+// windowChange is the payload of a window-change request.
+type windowChange struct {
+	Cols   uint32
+	Rows   uint32
+	Width  uint32
+	Height uint32
+}
+
+func (d *TermDriver) handleRequests(reqs <-chan *gossh.Request) {
+	var once sync.Once
+	defer once.Do(func() { close(d.ptyReq) })
+	for req := range reqs {
+		ok := false
+		switch req.Type {
+		case "pty-req":
+			var pr ptyRequest
+			if gossh.Unmarshal(req.Payload, &pr) == nil {
+				d.mu.Lock()
+				d.term = pr.Term
+				d.hasPty = true
+				d.mu.Unlock()
+				d.setSize(int(pr.Cols), int(pr.Rows), int(pr.Width), int(pr.Height))
+				once.Do(func() { close(d.ptyReq) })
+				ok = true
+			}
+		case "window-change":
+			var wc windowChange
+			if gossh.Unmarshal(req.Payload, &wc) == nil {
+				d.setSize(int(wc.Cols), int(wc.Rows), int(wc.Width), int(wc.Height))
+				ok = true
+			}
+		case "shell", "env":
+			ok = true
+		}
+		if req.WantReply {
+			_ = req.Reply(ok, nil)
+		}
+	}
+}
+
+func (d *TermDriver) setSize(w, h, pw, ph int) {
+	d.mu.Lock()
+	d.w, d.h = w, h
+	d.pw, d.ph = pw, ph
+	winsize := d.winsize
+	d.mu.Unlock()
+	if winsize != nil {
+		select {
+		case winsize <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Init waits for the client to request a pseudoterminal, and then
+// returns pipes that are relayed to and from the channel.
+func (d *TermDriver) Init(winch chan os.Signal) (*os.File, *os.File, error) {
+	<-d.ptyReq
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.hasPty {
+		return nil, nil, ErrNoPty
+	}
+	inR, inW, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		inR.Close()
+		inW.Close()
+		return nil, nil, err
+	}
+	go func() {
+		_, _ = io.Copy(inW, d.ch)
+		inW.Close()
+	}()
+	go func() {
+		_, _ = io.Copy(d.ch, outR)
+		outR.Close()
+	}()
+	return inR, outW, nil
+}
+
+// WinSize returns the size that the client last reported.
+func (d *TermDriver) WinSize() (int, int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.w, d.h, nil
+}
+
+// CellSize returns the size of a character cell in pixels, from the size
+// in pixels that the client last reported, or zeros if it did not say.
+func (d *TermDriver) CellSize() (int, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.w <= 0 || d.h <= 0 {
+		return 0, 0
+	}
+	return d.pw / d.w, d.ph / d.h
+}
+
+// GetTerm returns the terminal type that the client asked for, waiting
+// for its pty-req request if need be.
+func (d *TermDriver) GetTerm() string {
+	<-d.ptyReq
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.term
+}
+
+// Engage does nothing, as the client's terminal modes are its own affair.
+func (d *TermDriver) Engage() {}
+
+// Disengage does nothing, as the client's terminal modes are its own
+// affair.
+func (d *TermDriver) Disengage() {}
+
+// NotifyWinSize arranges for window-change requests to be sent on
+// winsize.
+func (d *TermDriver) NotifyWinSize(winsize chan<- struct{}) {
+	d.mu.Lock()
+	d.winsize = winsize
+	d.mu.Unlock()
+}
+
+// Ping checks that the client is still there by sending a keepalive
+// request, which clients answer even if only to refuse it.
+func (d *TermDriver) Ping() error {
+	_, err := d.ch.SendRequest("keepalive@openssh.com", true, nil)
+	return err
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+var _ tcell.TermDriver = &TermDriver{}
+var _ tcell.TermDriverWinSizeNotifier = &TermDriver{}
+var _ tcell.TermDriverPinger = &TermDriver{}
+var _ tcell.TermDriverCellSizer = &TermDriver{}
+
+// testChannel is a gossh.Channel that reads from r, and collects what is
+// written to it.
+type testChannel struct {
+	r io.Reader
+	w chan []byte
+}
+
+func (c *testChannel) Read(b []byte) (int, error) { return c.r.Read(b) }
+func (c *testChannel) Write(b []byte) (int, error) {
+	c.w <- append([]byte{}, b...)
+	return len(b), nil
+}
+func (c *testChannel) Close() error      { return nil }
+func (c *testChannel) CloseWrite() error { return nil }
+func (c *testChannel) SendRequest(string, bool, []byte) (bool, error) {
+	return false, nil
+}
+func (c *testChannel) Stderr() io.ReadWriter { return nil }
+
+func TestTermDriver(t *testing.T) {
+	ch := &testChannel{r: bytes.NewBufferString("hello"), w: make(chan []byte, 1)}
+	reqs := make(chan *gossh.Request, 2)
+	d := NewTermDriver(ch, reqs)
+
+	reqs <- &gossh.Request{Type: "pty-req", Payload: gossh.Marshal(&ptyRequest{
+		Term: "xterm-256color",
+		Cols: 100,
+		Rows: 30,
+	})}
+	if term := d.GetTerm(); term != "xterm-256color" {
+		t.Errorf("Bad terminal type %q", term)
+	}
+	if w, h, err := d.WinSize(); w != 100 || h != 30 || err != nil {
+		t.Errorf("Bad size %dx%d (%v)", w, h, err)
+	}
+	if cw, ch := d.CellSize(); cw != 0 || ch != 0 {
+		t.Errorf("Cell size should be unknown, got %dx%d", cw, ch)
+	}
+
+	winsize := make(chan struct{}, 1)
+	d.NotifyWinSize(winsize)
+	reqs <- &gossh.Request{Type: "window-change", Payload: gossh.Marshal(&windowChange{
+		Cols:   120,
+		Rows:   40,
+		Width:  960,
+		Height: 640,
+	})}
+	select {
+	case <-winsize:
+	case <-time.After(time.Second):
+		t.Fatalf("No window size change")
+	}
+	if w, h, _ := d.WinSize(); w != 120 || h != 40 {
+		t.Errorf("Bad size after change %dx%d", w, h)
+	}
+	if cw, ch := d.CellSize(); cw != 8 || ch != 16 {
+		t.Errorf("Bad cell size %dx%d", cw, ch)
+	}
+
+	in, out, err := d.Init(nil)
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	b := make([]byte, 10)
+	if n, _ := io.ReadAtLeast(in, b, 5); string(b[:n]) != "hello" {
+		t.Errorf("Bad input %q", b[:n])
+	}
+	_, _ = out.Write([]byte("world"))
+	if data := <-ch.w; string(data) != "world" {
+		t.Errorf("Bad output %q", data)
+	}
+	close(reqs)
+}
+
+func TestNoPty(t *testing.T) {
+	reqs := make(chan *gossh.Request)
+	d := NewTermDriver(&testChannel{}, reqs)
+	close(reqs)
+	if _, _, err := d.Init(nil); err != ErrNoPty {
+		t.Errorf("Expected ErrNoPty, got %v", err)
+	}
+}
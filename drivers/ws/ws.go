@@ -0,0 +1,198 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ws provides a tcell TermDriver for a WebSocket connection, so
+// that a tcell application can be served over HTTP to a terminal emulator
+// running in a browser, such as xterm.js.  For example:
+//
+//	func handler(w http.ResponseWriter, r *http.Request) {
+//		d, err := ws.NewTermDriver(w, r)
+//		if err != nil {
+//			return
+//		}
+//		defer d.Close()
+//		s, err := tcell.NewTerminfoScreenWithOptions(d, tcell.ScreenOptions{})
+//		...
+//	}
+//
+// Everything the screen writes is sent to the browser in binary messages.
+// The browser sends input as text or binary messages, and reports the
+// size of the terminal with a text message holding a JSON object such as
+// {"type":"resize","cols":80,"rows":24}.
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultTerm is the terminal type used if the request does not give
+// one with a "term" query parameter.  It suits xterm.js.
+const DefaultTerm = "xterm-256color"
+
+// pingTimeout is how long Ping waits to send a ping.
+const pingTimeout = 10 * time.Second
+
+var upgrader = websocket.Upgrader{}
+
+// TermDriver is a tcell.TermDriver for a WebSocket connection.
+type TermDriver struct {
+	conn    *websocket.Conn
+	term    string
+	w       int
+	h       int
+	winsize chan<- struct{}
+	mu      sync.Mutex
+}
+
+// NewTermDriver upgrades the HTTP request to a WebSocket connection, and
+// returns a TermDriver for it.  The terminal type is taken from the
+// "term" query parameter, if present.  Until the browser says otherwise,
+// the terminal is taken to be 80 by 24.  If the upgrade fails, an HTTP
+// error has already been sent in reply.
+func NewTermDriver(w http.ResponseWriter, r *http.Request) (*TermDriver, error) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	d := &TermDriver{conn: conn, term: DefaultTerm, w: 80, h: 24}
+	if term := r.URL.Query().Get("term"); term != "" {
+		d.term = term
+	}
+	return d, nil
+}
+
+// Close closes the connection.  This should be done after the screen is
+// finalized.
+func (d *TermDriver) Close() error {
+	return d.conn.Close()
+}
+
+// controlMessage is a message from the browser about the terminal,
+// rather than input for it.
+type controlMessage struct {
+	Type string `json:"type"`
+	Cols int    `json:"cols"`
+	Rows int    `json:"rows"`
+}
+
+// control handles msg if it is a control message, and returns false if
+// it is input.
+func (d *TermDriver) control(msg []byte) bool {
+	var cm controlMessage
+	if len(msg) == 0 || msg[0] != '{' || json.Unmarshal(msg, &cm) != nil {
+		return false
+	}
+	switch cm.Type {
+	case "resize":
+		if cm.Cols > 0 && cm.Rows > 0 {
+			d.setSize(cm.Cols, cm.Rows)
+		}
+		return true
+	}
+	return false
+}
+
+func (d *TermDriver) setSize(w, h int) {
+	d.mu.Lock()
+	d.w, d.h = w, h
+	winsize := d.winsize
+	d.mu.Unlock()
+	if winsize != nil {
+		select {
+		case winsize <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Init returns pipes that are relayed to and from the connection.
+func (d *TermDriver) Init(winch chan os.Signal) (*os.File, *os.File, error) {
+	inR, inW, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		inR.Close()
+		inW.Close()
+		return nil, nil, err
+	}
+	go func() {
+		defer inW.Close()
+		for {
+			kind, msg, err := d.conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if kind == websocket.TextMessage && d.control(msg) {
+				continue
+			}
+			if _, err = inW.Write(msg); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer outR.Close()
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := outR.Read(buf)
+			if n > 0 {
+				if d.conn.WriteMessage(websocket.BinaryMessage, buf[:n]) != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return inR, outW, nil
+}
+
+// WinSize returns the size that the browser last reported.
+func (d *TermDriver) WinSize() (int, int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.w, d.h, nil
+}
+
+// GetTerm returns the terminal type.
+func (d *TermDriver) GetTerm() string {
+	return d.term
+}
+
+// Engage does nothing.
+func (d *TermDriver) Engage() {}
+
+// Disengage does nothing.
+func (d *TermDriver) Disengage() {}
+
+// NotifyWinSize arranges for resize messages to be reported on winsize.
+func (d *TermDriver) NotifyWinSize(winsize chan<- struct{}) {
+	d.mu.Lock()
+	d.winsize = winsize
+	d.mu.Unlock()
+}
+
+// Ping sends a WebSocket ping, which fails if the connection is gone.
+func (d *TermDriver) Ping() error {
+	return d.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pingTimeout))
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ws
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/gorilla/websocket"
+)
+
+var _ tcell.TermDriver = &TermDriver{}
+var _ tcell.TermDriverWinSizeNotifier = &TermDriver{}
+var _ tcell.TermDriverPinger = &TermDriver{}
+
+func TestTermDriver(t *testing.T) {
+	drivers := make(chan *TermDriver, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, err := NewTermDriver(w, r)
+		if err != nil {
+			t.Errorf("Upgrade failed: %v", err)
+			return
+		}
+		drivers <- d
+	}))
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/?term=xterm"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	d := <-drivers
+	defer d.Close()
+
+	if term := d.GetTerm(); term != "xterm" {
+		t.Errorf("Bad terminal type %q", term)
+	}
+	winsize := make(chan struct{}, 1)
+	d.NotifyWinSize(winsize)
+	in, out, err := d.Init(nil)
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"resize","cols":132,"rows":43}`))
+	_ = conn.WriteMessage(websocket.TextMessage, []byte("hi"))
+	select {
+	case <-winsize:
+	case <-time.After(time.Second):
+		t.Fatalf("No window size change")
+	}
+	if w, h, _ := d.WinSize(); w != 132 || h != 43 {
+		t.Errorf("Bad size %dx%d", w, h)
+	}
+	b := make([]byte, 2)
+	if _, err = io.ReadFull(in, b); err != nil || string(b) != "hi" {
+		t.Errorf("Bad input %q (%v)", b, err)
+	}
+
+	_, _ = out.Write([]byte("hello"))
+	if kind, msg, err := conn.ReadMessage(); err != nil || kind != websocket.BinaryMessage || string(msg) != "hello" {
+		t.Errorf("Bad output %q (%v)", msg, err)
+	}
+	if err = d.Ping(); err != nil {
+		t.Errorf("Ping failed: %v", err)
+	}
+}
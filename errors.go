@@ -48,6 +48,34 @@ var (
 	// ErrEventQFull indicates that the event queue is full, and
 	// cannot accept more events.
 	ErrEventQFull = errors.New("event queue full")
+
+	// ErrNoClipboard indicates that the clipboard could not be accessed.
+	// Either the screen has no support for it, or the terminal did not
+	// answer our request in a timely fashion.
+	ErrNoClipboard = errors.New("clipboard not available")
+
+	// ErrBadSelection indicates that an unknown clipboard selection
+	// was requested.
+	ErrBadSelection = errors.New("unknown clipboard selection")
+
+	// ErrNoGraphics indicates that the terminal cannot display images
+	// in the requested format.
+	ErrNoGraphics = errors.New("graphics not supported")
+
+	// ErrNoCapability indicates that the terminal does not have the
+	// requested capability, or that the screen is not terminal based.
+	ErrNoCapability = errors.New("terminal capability not available")
+
+	// ErrBadColor indicates that a color could not be parsed.
+	ErrBadColor = errors.New("invalid color")
+
+	// ErrBadEscape indicates that text ended part way through an escape
+	// sequence.
+	ErrBadEscape = errors.New("incomplete escape sequence")
+
+	// ErrUnsupported indicates that a value was asked for that the screen
+	// does not support, such as a column count other than 80 or 132.
+	ErrUnsupported = errors.New("not supported")
 )
 
 // An EventError is an event representing some sort of error, and carries
@@ -67,6 +95,18 @@ func (ev *EventError) Error() string {
 	return ev.err.Error()
 }
 
+// Err returns the error that the event carries.
+func (ev *EventError) Err() error {
+	return ev.err
+}
+
+// Unwrap returns the error that the event carries, so that errors.Is
+// and errors.As can be used to check for particular errors, such as
+// io.EOF.
+func (ev *EventError) Unwrap() error {
+	return ev.err
+}
+
 // NewEventError creates an ErrorEvent with the given error payload.
 func NewEventError(err error) *EventError {
 	return &EventError{t: time.Now(), err: err}
@@ -15,6 +15,10 @@
 package tcell
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"testing"
 	"time"
 )
@@ -68,3 +72,161 @@ func TestMouseEvents(t *testing.T) {
 		t.Errorf("Modifiers should be control")
 	}
 }
+
+func TestPostFunc(t *testing.T) {
+
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	called := make(chan bool, 1)
+	go func() {
+		if err := s.PostFunc(func() { called <- true }); err != nil {
+			t.Errorf("PostFunc failed: %v", err)
+		}
+	}()
+
+	ev := s.PollEvent()
+	evf, ok := ev.(*EventFunc)
+	if !ok {
+		t.Fatalf("Expected function event, got %T", ev)
+	}
+	evf.Call()
+	select {
+	case <-called:
+	default:
+		t.Errorf("Function was not called")
+	}
+}
+
+func TestPollEventContext(t *testing.T) {
+
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if ev, err := s.PollEventContext(ctx); ev != nil || err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded, got %v, %v", ev, err)
+	}
+
+	s.InjectKey(KeyEnter, 0, ModNone)
+	ev, err := s.PollEventContext(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if evk, ok := ev.(*EventKey); !ok || evk.Key() != KeyEnter {
+		t.Errorf("Expected enter key, got %v", ev)
+	}
+}
+
+func TestPollEventFor(t *testing.T) {
+
+	s := mkTestScreen(t, "")
+
+	for i := 0; i < 3; i++ {
+		if ev, err := s.PollEventFor(time.Millisecond); ev != nil || err != nil {
+			t.Errorf("Expected timeout, got %v, %v", ev, err)
+		}
+	}
+
+	s.InjectKey(KeyEnter, 0, ModNone)
+	if ev, err := s.PollEventFor(time.Second); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if evk, ok := ev.(*EventKey); !ok || evk.Key() != KeyEnter {
+		t.Errorf("Expected enter key, got %v", ev)
+	}
+
+	s.Fini()
+	if _, err := s.PollEventFor(time.Second); err != ErrNoScreen {
+		t.Errorf("Expected ErrNoScreen after Fini, got %v", err)
+	}
+}
+
+func TestHasPendingEvent(t *testing.T) {
+
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	if s.HasPendingEvent() {
+		t.Errorf("No events should be pending")
+	}
+	s.InjectKeyBytes([]byte("abc"))
+	n := 0
+	for s.HasPendingEvent() {
+		s.PollEvent()
+		n++
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 events, got %d", n)
+	}
+}
+
+func TestTickerEvents(t *testing.T) {
+
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	s.SetTicker(5 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ev, err := s.PollEventContext(ctx)
+	if err != nil {
+		t.Fatalf("No tick received: %v", err)
+	}
+	if _, ok := ev.(*EventTicker); !ok {
+		t.Errorf("Expected ticker event, got %T", ev)
+	}
+
+	s.SetTicker(0)
+	// drain anything posted before the ticker stopped
+	for len(s.(*simscreen).evch) > 0 {
+		s.PollEvent()
+	}
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel2()
+	if ev, _ := s.PollEventContext(ctx2); ev != nil {
+		t.Errorf("Unexpected event after stopping ticker: %T", ev)
+	}
+}
+
+type testCustomEvent struct {
+	t    time.Time
+	name string
+}
+
+func (ev *testCustomEvent) When() time.Time {
+	return ev.t
+}
+
+func TestCustomEvent(t *testing.T) {
+
+	s := NewSimulationScreen("")
+	s.(optionSetter).setOptions(ScreenOptions{EventQueueSize: 20})
+	if e := s.Init(); e != nil {
+		t.Fatalf("Failed to initialize screen: %v", e)
+	}
+	defer s.Fini()
+
+	for i := 0; i < 20; i++ {
+		if err := s.PostEvent(&testCustomEvent{t: time.Now(), name: "custom"}); err != nil {
+			t.Fatalf("Post %d failed: %v", i, err)
+		}
+	}
+	if err := s.PostEvent(&testCustomEvent{}); err != ErrEventQFull {
+		t.Errorf("Expected full queue, got %v", err)
+	}
+	ev := s.PollEvent()
+	if evc, ok := ev.(*testCustomEvent); !ok || evc.name != "custom" {
+		t.Errorf("Custom event did not round trip: %v", ev)
+	}
+}
+
+func TestEventErrorUnwrap(t *testing.T) {
+	ev := NewEventError(fmt.Errorf("reading input: %w", io.EOF))
+	if !errors.Is(ev, io.EOF) {
+		t.Errorf("EventError should wrap io.EOF")
+	}
+	if ev.Err() == nil || ev.Error() != "reading input: EOF" {
+		t.Errorf("Bad error %v", ev.Err())
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"time"
+)
+
+// EventFocus is sent when the terminal window (or tab) gains or loses
+// focus.  These are only reported if focus reporting was enabled with
+// EnableFocus, and the terminal supports it.
+type EventFocus struct {
+	t time.Time
+
+	// Focused is true if the window gained focus, false if it lost it.
+	Focused bool
+}
+
+// When returns the time when this EventFocus was created.
+func (ev *EventFocus) When() time.Time {
+	return ev.t
+}
+
+// NewEventFocus returns a new EventFocus.
+func NewEventFocus(focused bool) *EventFocus {
+	return &EventFocus{t: time.Now(), Focused: focused}
+}
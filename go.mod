@@ -3,9 +3,13 @@ module github.com/gdamore/tcell/v2
 go 1.12
 
 require (
+	github.com/creack/pty v1.1.11
 	github.com/gdamore/encoding v1.0.0
+	github.com/gorilla/websocket v1.4.2
 	github.com/lucasb-eyer/go-colorful v1.0.3
 	github.com/mattn/go-runewidth v0.0.10
+	github.com/rivo/uniseg v0.1.0
+	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad
 	golang.org/x/sys v0.0.0-20201119102817-f84b799fce68
 	golang.org/x/term v0.0.0-20201210144234-2321bbc49cbf
 	golang.org/x/text v0.3.0
@@ -39,3 +39,30 @@ func (ev *EventInterrupt) Data() interface{} {
 func NewEventInterrupt(data interface{}) *EventInterrupt {
 	return &EventInterrupt{t: time.Now(), v: data}
 }
+
+// EventFunc carries a function to be run by the goroutine that is
+// polling for events.  It is posted with Screen.PostFunc, and allows
+// other goroutines to safely update the display, by handing the work
+// over to the event loop.  The event loop should call Call when it
+// receives this event.
+type EventFunc struct {
+	t time.Time
+	f func()
+}
+
+// When returns the time when this event was created.
+func (ev *EventFunc) When() time.Time {
+	return ev.t
+}
+
+// Call runs the function carried by the event.
+func (ev *EventFunc) Call() {
+	if ev.f != nil {
+		ev.f()
+	}
+}
+
+// NewEventFunc creates an EventFunc that will run the given function.
+func NewEventFunc(f func()) *EventFunc {
+	return &EventFunc{t: time.Now(), f: f}
+}
@@ -379,6 +379,8 @@ const (
 	// These key codes are used internally, and will never appear to applications.
 	keyPasteStart Key = iota + 16384
 	keyPasteEnd
+	keyFocusIn
+	keyFocusOut
 )
 
 // These are the control keys.  Note that they overlap with other keys,
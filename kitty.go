@@ -0,0 +1,87 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// kittyChunkSize is the largest amount of base64 data that the kitty
+// graphics protocol allows in a single escape sequence.
+const kittyChunkSize = 4096
+
+// hasKittyGraphics returns true if the environment suggests a terminal
+// emulator that supports the kitty graphics protocol.
+func hasKittyGraphics(term string) bool {
+	if strings.Contains(term, "kitty") || os.Getenv("KITTY_WINDOW_ID") != "" {
+		return true
+	}
+	return os.Getenv("TERM_PROGRAM") == "WezTerm"
+}
+
+// encodeKittyImage returns the escape sequences to transmit and display
+// an image with the kitty graphics protocol, scaled to cols by rows
+// cells (or its natural size, if these are zero).  The image is sent as
+// PNG data, in chunks.  The cursor is not moved, and the terminal is
+// asked not to reply.
+func encodeKittyImage(img image.Image, cols, rows int, id uint32) ([]byte, error) {
+	data := &bytes.Buffer{}
+	if e := png.Encode(data, img); e != nil {
+		return nil, e
+	}
+	b64 := base64.StdEncoding.EncodeToString(data.Bytes())
+
+	ctrl := "a=T,f=100,q=2,C=1"
+	if id != 0 {
+		ctrl += ",i=" + strconv.FormatUint(uint64(id), 10)
+	}
+	if cols > 0 {
+		ctrl += ",c=" + strconv.Itoa(cols)
+	}
+	if rows > 0 {
+		ctrl += ",r=" + strconv.Itoa(rows)
+	}
+
+	buf := &bytes.Buffer{}
+	for first := true; first || len(b64) > 0; first = false {
+		chunk := b64
+		if len(chunk) > kittyChunkSize {
+			chunk = chunk[:kittyChunkSize]
+		}
+		b64 = b64[len(chunk):]
+		more := "0"
+		if len(b64) > 0 {
+			more = "1"
+		}
+		buf.WriteString("\x1b_G")
+		if first {
+			buf.WriteString(ctrl + ",")
+		}
+		buf.WriteString("m=" + more + ";" + chunk + "\x1b\\")
+	}
+	return buf.Bytes(), nil
+}
+
+// kittyDeleteImage returns the escape sequence to remove an image, and
+// free its data, with the kitty graphics protocol.
+func kittyDeleteImage(id uint32) string {
+	return "\x1b_Ga=d,d=I,q=2,i=" + strconv.FormatUint(uint64(id), 10) + "\x1b\\"
+}
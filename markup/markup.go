@@ -0,0 +1,190 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package markup parses text with simple style tags, such as
+//
+//	"[bold]Warning:[/bold] disk [fg=red]full[/fg]"
+//
+// into styled runes that can be displayed on a tcell Screen.  Tags may be
+// nested, and each closing tag must match the most recent open one, or
+// be [/], which closes whatever is open.  Every tag must be closed by the
+// end of the text.  A literal "[" is written as
+// "[[".  The tags understood are bold, dim, italic, underline, blink,
+// reverse, strikethrough, overline and invisible, which take no value,
+// and fg, bg and url, which do, as in [fg=#ff8000] or
+// [url=https://example.com].  Colors are given as for tcell.GetColor.
+// More tags can be added to a Parser with RegisterTag.
+package markup
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// TagFunc applies a tag to a style, returning the style for the text
+// inside the tag.  The value is what follows "=" in the tag, or the empty
+// string.  An error is returned if the value is not valid for the tag.
+type TagFunc func(style tcell.Style, value string) (tcell.Style, error)
+
+// Parser parses markup.  Its zero value has no tags; use NewParser to get
+// one that knows the standard tags.
+type Parser struct {
+	tags map[string]TagFunc
+}
+
+// NewParser returns a Parser that knows the standard tags.
+func NewParser() *Parser {
+	p := &Parser{}
+	for name, fn := range standardTags {
+		p.RegisterTag(name, fn)
+	}
+	return p
+}
+
+// RegisterTag adds a tag to the parser, or replaces an existing tag of
+// the same name.  Tag names are matched without regard to case.
+func (p *Parser) RegisterTag(name string, fn TagFunc) {
+	if p.tags == nil {
+		p.tags = make(map[string]TagFunc)
+	}
+	p.tags[strings.ToLower(name)] = fn
+}
+
+// Parse converts markup to styled runes, starting from the default
+// style.  An unknown tag, a bad value, a closing tag that does not match,
+// a tag without its closing "]", or a tag still open at the end of the
+// text is reported as an error, giving the offset of the tag in the text.
+func (p *Parser) Parse(s string) ([]tcell.StyledRune, error) {
+	type open struct {
+		tag   string
+		at    int // the offset of the tag
+		name  string
+		outer tcell.Style
+	}
+	var stack []open
+	style := tcell.StyleDefault
+	res := make([]tcell.StyledRune, 0, len(s))
+
+	for i := 0; i < len(s); {
+		if s[i] != '[' {
+			r, n := utf8.DecodeRuneInString(s[i:])
+			res = append(res, tcell.StyledRune{R: r, Style: style})
+			i += n
+			continue
+		}
+		if strings.HasPrefix(s[i:], "[[") {
+			res = append(res, tcell.StyledRune{R: '[', Style: style})
+			i += 2
+			continue
+		}
+		end := strings.IndexByte(s[i:], ']')
+		if end < 0 {
+			return nil, fmt.Errorf("markup: tag at offset %d has no closing ]", i)
+		}
+		tag := s[i+1 : i+end]
+
+		if strings.HasPrefix(tag, "/") {
+			name := strings.ToLower(tag[1:])
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("markup: [%s] at offset %d closes nothing", tag, i)
+			}
+			top := stack[len(stack)-1]
+			if name != "" && name != top.name {
+				return nil, fmt.Errorf("markup: [%s] at offset %d does not match [%s]", tag, i, top.name)
+			}
+			stack = stack[:len(stack)-1]
+			style = top.outer
+			i += end + 1
+			continue
+		}
+
+		name, value := tag, ""
+		if eq := strings.IndexByte(tag, '='); eq >= 0 {
+			name, value = tag[:eq], tag[eq+1:]
+		}
+		name = strings.ToLower(name)
+		fn, ok := p.tags[name]
+		if !ok {
+			return nil, fmt.Errorf("markup: unknown tag [%s] at offset %d", tag, i)
+		}
+		inner, err := fn(style, value)
+		if err != nil {
+			return nil, fmt.Errorf("markup: tag [%s] at offset %d: %v", tag, i, err)
+		}
+		stack = append(stack, open{tag, i, name, style})
+		style = inner
+		i += end + 1
+	}
+	if len(stack) != 0 {
+		top := stack[len(stack)-1]
+		return nil, fmt.Errorf("markup: [%s] at offset %d is never closed", top.tag, top.at)
+	}
+	return res, nil
+}
+
+// defaultParser is used by Parse.
+var defaultParser = NewParser()
+
+// Parse parses markup with the standard tags.
+func Parse(s string) ([]tcell.StyledRune, error) {
+	return defaultParser.Parse(s)
+}
+
+// attrTag returns a TagFunc that adds the given attributes.
+func attrTag(attrs tcell.AttrMask) TagFunc {
+	return func(style tcell.Style, value string) (tcell.Style, error) {
+		if value != "" {
+			return style, fmt.Errorf("unexpected value %q", value)
+		}
+		return style.With(attrs), nil
+	}
+}
+
+// colorValue parses the value of a color tag.
+func colorValue(value string) (tcell.Color, error) {
+	c := tcell.GetColor(strings.ToLower(value))
+	if c == tcell.ColorDefault {
+		return c, fmt.Errorf("invalid color %q", value)
+	}
+	return c, nil
+}
+
+var standardTags = map[string]TagFunc{
+	"bold":          attrTag(tcell.AttrBold),
+	"dim":           attrTag(tcell.AttrDim),
+	"italic":        attrTag(tcell.AttrItalic),
+	"underline":     attrTag(tcell.AttrUnderline),
+	"blink":         attrTag(tcell.AttrBlink),
+	"reverse":       attrTag(tcell.AttrReverse),
+	"strikethrough": attrTag(tcell.AttrStrikeThrough),
+	"overline":      attrTag(tcell.AttrOverline),
+	"invisible":     attrTag(tcell.AttrInvisible),
+	"fg": func(style tcell.Style, value string) (tcell.Style, error) {
+		c, err := colorValue(value)
+		return style.Foreground(c), err
+	},
+	"bg": func(style tcell.Style, value string) (tcell.Style, error) {
+		c, err := colorValue(value)
+		return style.Background(c), err
+	},
+	"url": func(style tcell.Style, value string) (tcell.Style, error) {
+		if value == "" {
+			return style, fmt.Errorf("missing URL")
+		}
+		return style.URL(value), nil
+	},
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package markup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestParse(t *testing.T) {
+	rs, err := Parse("a[bold]b[fg=red]c[/fg]d[/bold][[[url=http://x][bg=#00ff00]e[/]f[/]")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	bold := tcell.StyleDefault.Bold(true)
+	link := tcell.StyleDefault.URL("http://x")
+	expect := []tcell.StyledRune{
+		{R: 'a', Style: tcell.StyleDefault},
+		{R: 'b', Style: bold},
+		{R: 'c', Style: bold.Foreground(tcell.ColorRed)},
+		{R: 'd', Style: bold},
+		{R: '[', Style: tcell.StyleDefault},
+		{R: 'e', Style: link.Background(tcell.NewHexColor(0x00ff00))},
+		{R: 'f', Style: link},
+	}
+	if len(rs) != len(expect) {
+		t.Fatalf("Expected %d runes, got %d", len(expect), len(rs))
+	}
+	for i := range rs {
+		if rs[i] != expect[i] {
+			t.Errorf("Rune %d: expected %+v, got %+v", i, expect[i], rs[i])
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	var values = []struct {
+		text string
+		msg  string
+	}{
+		{"[shiny]x", "unknown tag [shiny] at offset 0"},
+		{"x[bold]y[/italic]", "[/italic] at offset 8 does not match [bold]"},
+		{"[/bold]", "closes nothing"},
+		{"a[bold", "no closing ]"},
+		{"[bold]a[fg=red]b[/fg]c", "[bold] at offset 0 is never closed"},
+		{"[bold]a[fg=red]b", "[fg=red] at offset 7 is never closed"},
+		{"[fg=nosuchcolor]", `invalid color "nosuchcolor"`},
+		{"[bold=yes]", `unexpected value "yes"`},
+		{"[url]", "missing URL"},
+	}
+	for _, v := range values {
+		if _, err := Parse(v.text); err == nil || !strings.Contains(err.Error(), v.msg) {
+			t.Errorf("%q: expected error with %q, got %v", v.text, v.msg, err)
+		}
+	}
+}
+
+func TestRegisterTag(t *testing.T) {
+	p := NewParser()
+	warn := tcell.StyleDefault.Foreground(tcell.ColorYellow).Bold(true)
+	p.RegisterTag("Warn", func(style tcell.Style, value string) (tcell.Style, error) {
+		return style.Merge(warn), nil
+	})
+	rs, err := p.Parse("[warn]![/WARN]")
+	if err != nil || len(rs) != 1 || rs[0].Style != warn {
+		t.Errorf("Bad custom tag %v %v", rs, err)
+	}
+	if _, err = (&Parser{}).Parse("[bold]"); err == nil {
+		t.Errorf("The zero Parser should have no tags")
+	}
+}
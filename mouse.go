@@ -35,11 +35,14 @@ import (
 // Applications can inspect the time between events to resolve double or
 // triple clicks.
 type EventMouse struct {
-	t   time.Time
-	btn ButtonMask
-	mod ModMask
-	x   int
-	y   int
+	t      time.Time
+	btn    ButtonMask
+	mod    ModMask
+	x      int
+	y      int
+	px     int
+	py     int
+	motion bool
 }
 
 // When returns the time when this EventMouse was created.
@@ -64,6 +67,22 @@ func (ev *EventMouse) Position() (int, int) {
 	return ev.x, ev.y
 }
 
+// PixelPosition returns the mouse position in pixels, if the screen
+// was asked to report them with MousePixelMotion, the terminal supports
+// it, and the size of its cells is known (see TermDriverCellSizer).
+// Otherwise both values are zero.  The origin 0, 0 is at the upper
+// left corner.
+func (ev *EventMouse) PixelPosition() (int, int) {
+	return ev.px, ev.py
+}
+
+// Dragging returns true if this event reports the mouse moving while at
+// least one button is held down, as happens during drag and drop.  Plain
+// motion, presses, releases and wheel motion are not dragging.
+func (ev *EventMouse) Dragging() bool {
+	return ev.motion && ev.btn&buttonsHeld != 0
+}
+
 // NewEventMouse is used to create a new mouse event.  Applications
 // shouldn't need to use this; its mostly for screen implementors.
 func NewEventMouse(x, y int, btn ButtonMask, mod ModMask) *EventMouse {
@@ -101,3 +120,7 @@ const (
 	ButtonSecondary = Button2
 	ButtonMiddle    = Button3
 )
+
+// buttonsHeld are the buttons that can be held down, unlike the wheel.
+const buttonsHeld = Button1 | Button2 | Button3 | Button4 |
+	Button5 | Button6 | Button7 | Button8
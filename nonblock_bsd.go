@@ -18,6 +18,7 @@ package tcell
 
 import (
 	"syscall"
+	"time"
 
 	"golang.org/x/sys/unix"
 )
@@ -29,6 +30,17 @@ import (
 // that loop.  Normally we use VMIN 1 and VTIME 0, which ensures we pick up bytes when
 // they come but don't spin burning cycles.
 func (t *tScreen) nonBlocking(on bool) {
+	// If the input loop is waiting in the runtime's poller, as it does
+	// for pipes from a TermDriver, and for some terminals, changing the
+	// terminal modes will not wake it, but a deadline will.
+	if on {
+		_ = t.in.SetReadDeadline(time.Now())
+	} else {
+		_ = t.in.SetReadDeadline(time.Time{})
+	}
+	if !t.tty {
+		return
+	}
 	fd := int(t.in.Fd())
 	tio, err := unix.IoctlGetTermios(fd, unix.TIOCGETA)
 	if err != nil {
@@ -15,16 +15,21 @@
 package tcell
 
 import (
+	"strings"
 	"time"
+	"unicode"
 )
 
 // EventPaste is used to mark the start and end of a bracketed paste.
 // An event with .Start() true will be sent to mark the start.
 // Then a number of keys will be sent to indicate that the content
 // is pasted in.  At the end, an event with .Start() false will be sent.
+// For convenience, the end event also carries all of the pasted text.
 type EventPaste struct {
 	start bool
 	t     time.Time
+	text  string
+	mime  string
 }
 
 // When returns the time when this EventMouse was created.
@@ -42,6 +47,74 @@ func (ev *EventPaste) End() bool {
 	return !ev.start
 }
 
+// Text returns the text that was pasted, as it was delivered by the
+// key events since the start of the paste.  It is only available from
+// the event marking the end of the paste.
+func (ev *EventPaste) Text() string {
+	return ev.text
+}
+
+// PlainText is like Text, but with any control characters other than
+// newlines and tabs removed, and carriage returns made into newlines, so
+// that it is safe to display.
+func (ev *EventPaste) PlainText() string {
+	text := strings.ReplaceAll(ev.text, "\r\n", "\n")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\r':
+			return '\n'
+		case r == '\n' || r == '\t':
+			return r
+		case unicode.IsControl(r) || r == unicode.ReplacementChar:
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// MIME returns the MIME type of the pasted data.  Bracketed paste only
+// carries text, so this is "text/plain" unless the terminal said otherwise.
+func (ev *EventPaste) MIME() string {
+	if ev.mime == "" {
+		return "text/plain"
+	}
+	return ev.mime
+}
+
+// pasteCollector gathers the text delivered between the start and the
+// end of a paste, so that it can be attached to the end event.
+type pasteCollector struct {
+	active bool
+	text   strings.Builder
+}
+
+// filter notes the text of key events during a paste.  The events
+// themselves are not changed, apart from the end of the paste, which
+// gets the text.
+func (pc *pasteCollector) filter(ev Event) {
+	switch ev := ev.(type) {
+	case *EventPaste:
+		if ev.start {
+			pc.active = true
+			pc.text.Reset()
+		} else if pc.active {
+			ev.text = pc.text.String()
+			pc.active = false
+			pc.text.Reset()
+		}
+	case *EventKey:
+		if !pc.active {
+			return
+		}
+		if ev.Key() == KeyRune {
+			pc.text.WriteRune(ev.Rune())
+		} else if ev.Key() < KeyRune {
+			// control characters have keys of their own
+			pc.text.WriteRune(rune(ev.Key()))
+		}
+	}
+}
+
 // NewEventPaste returns a new EventPaste.
 func NewEventPaste(start bool) *EventPaste {
 	return &EventPaste{t: time.Now(), start: start}
@@ -0,0 +1,160 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"image"
+)
+
+// PixelMode selects how DrawPixelImage approximates an image with text.
+type PixelMode int
+
+const (
+	// PixelModeHalfBlock uses the upper half block, with the foreground
+	// and background colors giving two pixels per cell, one above the
+	// other.  This gives the most faithful colors.
+	PixelModeHalfBlock PixelMode = iota
+
+	// PixelModeBraille uses Braille patterns, giving 2 by 4 pixels per
+	// cell.  Each cell can only have two colors, so this is better for
+	// images with sharp edges, such as plots.
+	PixelModeBraille
+)
+
+// brailleDots are the bits of the Braille pattern for each pixel of a
+// cell, indexed by row and then column.
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// pixelSampler averages the colors of an image over the regions that
+// correspond to the pixels of a smaller grid.
+type pixelSampler struct {
+	img    image.Image
+	bounds image.Rectangle
+	w, h   int
+}
+
+// at returns the average color, as 16-bit components, of the area of
+// the image that pixel (x, y) of the grid covers.
+func (ps *pixelSampler) at(x, y int) (r, g, b uint32) {
+	sw, sh := ps.bounds.Dx(), ps.bounds.Dy()
+	x0, x1 := x*sw/ps.w, (x+1)*sw/ps.w
+	y0, y1 := y*sh/ps.h, (y+1)*sh/ps.h
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	n := uint32(0)
+	for sy := y0; sy < y1 && sy < sh; sy++ {
+		for sx := x0; sx < x1 && sx < sw; sx++ {
+			pr, pg, pb, _ := ps.img.At(ps.bounds.Min.X+sx, ps.bounds.Min.Y+sy).RGBA()
+			r += pr
+			g += pg
+			b += pb
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 0, 0
+	}
+	return r / n, g / n, b / n
+}
+
+// rgbColor makes a Color from 16-bit components.
+func rgbColor(r, g, b uint32) Color {
+	return NewRGBColor(int32(r>>8), int32(g>>8), int32(b>>8))
+}
+
+// drawPixelImage does the work of Screen.DrawPixelImage, using only
+// SetContent, so that it works for any Screen.
+func drawPixelImage(s Screen, x, y, w, h int, img image.Image, mode PixelMode) {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return
+	}
+	pw, ph := 1, 2
+	if mode == PixelModeBraille {
+		pw, ph = 2, 4
+	}
+	if w <= 0 {
+		w = (bounds.Dx() + pw - 1) / pw
+	}
+	if h <= 0 {
+		h = (bounds.Dy() + ph - 1) / ph
+	}
+	ps := &pixelSampler{img: img, bounds: bounds, w: w * pw, h: h * ph}
+
+	for row := 0; row < h; row++ {
+		for col := 0; col < w; col++ {
+			if mode != PixelModeBraille {
+				fg := rgbColor(ps.at(col, row*2))
+				bg := rgbColor(ps.at(col, row*2+1))
+				style := StyleDefault.Foreground(fg).Background(bg)
+				s.SetContent(x+col, y+row, '▀', nil, style)
+				continue
+			}
+			ch, style := brailleCell(ps, col*2, row*4)
+			s.SetContent(x+col, y+row, ch, nil, style)
+		}
+	}
+}
+
+// brailleCell works out the Braille pattern and colors for the cell
+// whose top left pixel is at (px, py).  The pixels brighter than the
+// average are drawn in the foreground color, and the rest are left
+// in the background color.  Each color is the average of its pixels.
+func brailleCell(ps *pixelSampler, px, py int) (rune, Style) {
+	var rs, gs, bs, ls [4][2]uint32
+	var total uint32
+	for dy := 0; dy < 4; dy++ {
+		for dx := 0; dx < 2; dx++ {
+			r, g, b := ps.at(px+dx, py+dy)
+			rs[dy][dx], gs[dy][dx], bs[dy][dx] = r, g, b
+			// an approximation of luminance that is cheap to compute
+			ls[dy][dx] = (r*2 + g*5 + b) / 8
+			total += ls[dy][dx]
+		}
+	}
+	mean := total / 8
+
+	var fr, fg, fb, fn, br, bg, bb, bn uint32
+	ch := rune(0x2800)
+	for dy := 0; dy < 4; dy++ {
+		for dx := 0; dx < 2; dx++ {
+			if ls[dy][dx] > mean {
+				ch |= brailleDots[dy][dx]
+				fr, fg, fb = fr+rs[dy][dx], fg+gs[dy][dx], fb+bs[dy][dx]
+				fn++
+			} else {
+				br, bg, bb = br+rs[dy][dx], bg+gs[dy][dx], bb+bs[dy][dx]
+				bn++
+			}
+		}
+	}
+	style := StyleDefault
+	if fn > 0 {
+		style = style.Foreground(rgbColor(fr/fn, fg/fn, fb/fn))
+	}
+	if bn > 0 {
+		style = style.Background(rgbColor(br/bn, bg/bn, bb/bn))
+	}
+	return ch, style
+}
@@ -0,0 +1,269 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package record records what is shown on a screen, in the asciicast v2
+// format used by asciinema, and plays such recordings back.
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ErrBadRecording is returned by Playback when the recording is not in
+// the asciicast v2 format.
+var ErrBadRecording = errors.New("not an asciicast v2 recording")
+
+// frameStart starts the output for each frame, by moving the cursor to
+// the top left corner.  Each frame is the whole of the screen.
+const frameStart = "\x1b[H"
+
+// header is the first line of an asciicast v2 recording.
+type header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recorder is a Screen that records what is shown on another screen.
+// Each time that Show or Sync is called, the contents of the screen are
+// written as a frame of the recording, if they have changed, along with
+// the time since recording started.  Changes in size are recorded too.
+type Recorder struct {
+	tcell.Screen
+
+	mu      sync.Mutex
+	w       *bufio.Writer
+	shadow  tcell.SimulationScreen
+	start   time.Time
+	started bool
+	width   int
+	height  int
+	last    []byte
+	err     error
+	txDepth int // transactions in progress, during which nothing is recorded
+}
+
+// NewRecorder returns a Recorder that records what is shown on screen,
+// writing it to w.  The Recorder is used in place of screen, which may
+// be initialized either before or after the Recorder is made.
+func NewRecorder(screen tcell.Screen, w io.Writer) *Recorder {
+	shadow := tcell.NewSimulationScreen("UTF-8")
+	_ = shadow.Init()
+	return &Recorder{
+		Screen: screen,
+		w:      bufio.NewWriter(w),
+		shadow: shadow,
+		start:  time.Now(),
+	}
+}
+
+// Show shows the screen, and records it.
+func (r *Recorder) Show() {
+	r.Screen.Show()
+	r.frame()
+}
+
+// Sync shows the screen, and records it.
+func (r *Recorder) Sync() {
+	r.Screen.Sync()
+	r.frame()
+}
+
+// Transaction calls f as a transaction on the screen, and records the
+// screen once, after f returns.
+func (r *Recorder) Transaction(f func()) {
+	r.mu.Lock()
+	r.txDepth++
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.txDepth--
+		r.mu.Unlock()
+		r.frame()
+	}()
+	r.Screen.Transaction(f)
+}
+
+// Fini finishes with the screen, and with the recording, which is then
+// flushed to the writer.
+func (r *Recorder) Fini() {
+	r.frame()
+	r.Screen.Fini()
+	r.mu.Lock()
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+	r.shadow.Fini()
+	r.mu.Unlock()
+}
+
+// Err returns the first error that writing the recording encountered.
+// Once there is an error, nothing more is recorded.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// frame records the current contents of the screen.
+func (r *Recorder) frame() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil || r.txDepth > 0 {
+		return
+	}
+	w, h := r.Screen.Size()
+	switch {
+	case !r.started:
+		r.started = true
+		r.writeHeader(w, h)
+		r.shadow.SetSize(w, h)
+	case w != r.width || h != r.height:
+		r.writeEvent("r", fmt.Sprintf("%dx%d", w, h))
+		r.shadow.SetSize(w, h)
+	}
+	r.width, r.height = w, h
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			mainc, combc, style, _ := r.Screen.GetContent(x, y)
+			r.shadow.SetContent(x, y, mainc, combc, style)
+		}
+	}
+	data := r.shadow.RenderANSI()
+	if bytes.Equal(data, r.last) {
+		return
+	}
+	r.last = data
+	r.writeEvent("o", frameStart+string(data))
+}
+
+// writeHeader writes the header of the recording.
+func (r *Recorder) writeHeader(w, h int) {
+	hdr := header{
+		Version:   2,
+		Width:     w,
+		Height:    h,
+		Timestamp: r.start.Unix(),
+		Env:       map[string]string{"TERM": os.Getenv("TERM")},
+	}
+	b, err := json.Marshal(&hdr)
+	if err != nil {
+		r.err = err
+		return
+	}
+	r.writeLine(b)
+}
+
+// writeEvent writes an event, with the time since recording started.
+func (r *Recorder) writeEvent(code, data string) {
+	secs := float64(time.Since(r.start)/time.Microsecond) / 1e6
+	b, err := json.Marshal([]interface{}{secs, code, data})
+	if err != nil {
+		r.err = err
+		return
+	}
+	r.writeLine(b)
+}
+
+func (r *Recorder) writeLine(b []byte) {
+	if r.err != nil {
+		return
+	}
+	if _, err := r.w.Write(append(b, '\n')); err != nil {
+		r.err = err
+	}
+}
+
+// Playback plays back a recording made by a Recorder, returning a
+// simulation screen that shows the end of it.  The frames are played as
+// fast as possible, rather than at the times that they were recorded.
+// Other asciicast v2 recordings may be played back too, but as only the
+// styles and text of their output are understood, the results are only
+// right if each event draws the whole screen from the top left corner.
+// The caller should call Fini on the screen when done with it.
+func Playback(rd io.Reader) (tcell.SimulationScreen, error) {
+	br := bufio.NewReader(rd)
+	line, err := br.ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return nil, ErrBadRecording
+	}
+	var hdr header
+	if json.Unmarshal(line, &hdr) != nil || hdr.Version != 2 {
+		return nil, ErrBadRecording
+	}
+
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		return nil, err
+	}
+	s.SetSize(hdr.Width, hdr.Height)
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) != 0 {
+			if e := playEvent(s, line); e != nil {
+				s.Fini()
+				return nil, e
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.Fini()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// playEvent plays one event of a recording on s.
+func playEvent(s tcell.SimulationScreen, line []byte) error {
+	var ev []interface{}
+	if json.Unmarshal(line, &ev) != nil || len(ev) != 3 {
+		return ErrBadRecording
+	}
+	code, _ := ev[1].(string)
+	data, ok := ev[2].(string)
+	if !ok {
+		return ErrBadRecording
+	}
+	switch code {
+	case "o":
+		s.Clear()
+		// a frame cut short is shown as far as it goes
+		_, _ = s.DrawANSI(0, 0, data, tcell.StyleDefault)
+		s.Show()
+	case "r":
+		var w, h int
+		if _, err := fmt.Sscanf(data, "%dx%d", &w, &h); err != nil {
+			return ErrBadRecording
+		}
+		s.SetSize(w, h)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestRecordPlayback(t *testing.T) {
+	sim := tcell.NewSimulationScreen("")
+	var buf bytes.Buffer
+	rec := NewRecorder(sim, &buf)
+	if err := rec.Init(); err != nil {
+		t.Fatalf("Failed to initialize screen: %v", err)
+	}
+	sim.SetSize(20, 5)
+
+	bold := tcell.StyleDefault.Bold(true).Foreground(tcell.ColorRed)
+	rec.DrawText(0, 0, tcell.StyleDefault, "first")
+	rec.Show()
+	rec.Show() // no change, so no frame
+	rec.DrawText(0, 0, bold, "second")
+	rec.DrawText(3, 2, tcell.StyleDefault, "世界")
+	rec.Sync()
+	sim.SetSize(10, 4)
+	rec.Show()
+	rec.Fini()
+	if err := rec.Err(); err != nil {
+		t.Fatalf("Recording failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Expected header and 4 events, got %d lines", len(lines))
+	}
+	var hdr header
+	if err := json.Unmarshal([]byte(lines[0]), &hdr); err != nil {
+		t.Fatalf("Bad header: %v", err)
+	}
+	if hdr.Version != 2 || hdr.Width != 20 || hdr.Height != 5 {
+		t.Errorf("Bad header %+v", hdr)
+	}
+	if _, ok := hdr.Env["TERM"]; !ok {
+		t.Errorf("TERM not recorded")
+	}
+	if !strings.Contains(lines[3], `"r","10x4"`) {
+		t.Errorf("Resize not recorded: %s", lines[3])
+	}
+
+	s, err := Playback(&buf)
+	if err != nil {
+		t.Fatalf("Playback failed: %v", err)
+	}
+	defer s.Fini()
+	if w, h := s.Size(); w != 10 || h != 4 {
+		t.Errorf("Expected size 10x4, got %dx%d", w, h)
+	}
+	for x, r := range "second" {
+		mainc, _, style, _ := s.GetContent(x, 0)
+		if mainc != r || style != bold {
+			t.Errorf("%d: expected %q in bold red, got %q %v", x, r, mainc, style)
+		}
+	}
+	if mainc, _, _, width := s.GetContent(3, 2); mainc != '世' || width != 2 {
+		t.Errorf("Wide character not played back: %q %d", mainc, width)
+	}
+	if mainc, _, _, _ := s.GetContent(5, 2); mainc != '界' {
+		t.Errorf("Expected second wide character, got %q", mainc)
+	}
+}
+
+func TestPlaybackBad(t *testing.T) {
+	if _, err := Playback(strings.NewReader("not json\n")); err != ErrBadRecording {
+		t.Errorf("Expected ErrBadRecording, got %v", err)
+	}
+	if _, err := Playback(strings.NewReader(`{"version":1,"width":80,"height":24}`)); err != ErrBadRecording {
+		t.Errorf("Expected ErrBadRecording for version 1, got %v", err)
+	}
+	rec := `{"version":2,"width":5,"height":1}` + "\n" + `[0.5,"o","\u001b[Hhi"]` + "\n"
+	s, err := Playback(strings.NewReader(rec))
+	if err != nil {
+		t.Fatalf("Playback failed: %v", err)
+	}
+	defer s.Fini()
+	if mainc, _, _, _ := s.GetContent(1, 0); mainc != 'i' {
+		t.Errorf("Expected 'i', got %q", mainc)
+	}
+}
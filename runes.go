@@ -108,4 +108,32 @@ var RuneFallbacks = map[rune]string{
 	RuneULCorner: "+",
 	RuneURCorner: "+",
 	RuneVLine:    "|",
+
+	// box drawing characters used by DrawBox and SetCrossing
+	'═': "=",
+	'║': "|",
+	'╔': "+",
+	'╗': "+",
+	'╚': "+",
+	'╝': "+",
+	'╭': "+",
+	'╮': "+",
+	'╰': "+",
+	'╯': "+",
+	'━': "-",
+	'┃': "|",
+	'┏': "+",
+	'┓': "+",
+	'┗': "+",
+	'┛': "+",
+	'╦': "+",
+	'╩': "+",
+	'╠': "+",
+	'╣': "+",
+	'╬': "+",
+	'┳': "+",
+	'┻': "+",
+	'┣': "+",
+	'┫': "+",
+	'╋': "+",
 }
@@ -93,3 +93,77 @@ func TestRuneFallbacks(t *testing.T) {
 		t.Errorf("Should not be able to display hline")
 	}
 }
+
+func TestRuneWidth(t *testing.T) {
+	var values = []struct {
+		r rune
+		w int
+	}{
+		{'a', 1},
+		{'́', 0},
+		{'\t', 0},
+		{'中', 2},
+		{'Ａ', 2},
+		{'😀', 2},
+		{RuneHLine, 1},
+	}
+	for _, v := range values {
+		if w := RuneWidth(v.r); w != v.w {
+			t.Errorf("Rune %q: expected width %d, got %d", v.r, v.w, w)
+		}
+	}
+}
+
+func TestGraphemeCluster(t *testing.T) {
+	var values = []struct {
+		s string
+		n []int
+	}{
+		{"abc", []int{1, 1, 1}},
+		{"éx", []int{2, 1}},
+		// a family, joined by zero width joiners
+		{"\U0001f468‍\U0001f469‍\U0001f467", []int{5}},
+		// a flag, and a thumbs up with a skin tone
+		{"\U0001f1ef\U0001f1f5\U0001f44d\U0001f3fd", []int{2, 2}},
+		{"", nil},
+	}
+	for _, v := range values {
+		gc := GraphemeCluster(v.s)
+		if len(gc) != len(v.n) {
+			t.Errorf("%q: expected %d clusters, got %d", v.s, len(v.n), len(gc))
+			continue
+		}
+		for i, rs := range gc {
+			if len(rs) != v.n[i] {
+				t.Errorf("%q: cluster %d has %d runes, expected %d", v.s, i, len(rs), v.n[i])
+			}
+		}
+	}
+}
+
+func TestStringWidth(t *testing.T) {
+	var values = []struct {
+		s string
+		w int
+	}{
+		{"hello", 5},
+		{"", 0},
+		{"\x1b[1;31mred\x1b[0m", 3},
+		{"\x1b]8;;http://example.com\x1b\\link\x1b]8;;\x1b\\", 4},
+		{"tab\there\n", 7},
+		{"é", 1},
+		{"日本語", 6},
+		{"\x1b[32m世界\x1b[0m!", 5},
+		{"\U0001f1ef\U0001f1f5", 2},
+		{"\U0001f468‍\U0001f469‍\U0001f467", 2},
+		{"abc\x1b[3", 3},
+	}
+	for _, v := range values {
+		if w := StringWidth(v.s); w != v.w {
+			t.Errorf("%q: expected width %d, got %d", v.s, v.w, w)
+		}
+	}
+	if n := testing.AllocsPerRun(10, func() { StringWidth("\x1b[1mplain ASCII\x1b[0m") }); n != 0 {
+		t.Errorf("Expected no allocations for ASCII, got %v", n)
+	}
+}
@@ -14,6 +14,13 @@
 
 package tcell
 
+import (
+	"context"
+	"image"
+	"sync"
+	"time"
+)
+
 // Screen represents the physical (or emulated) screen.
 // This can be a terminal window or a physical console.  Platforms implement
 // this differently.
@@ -32,17 +39,36 @@ type Screen interface {
 	// Fill fills the screen with the given character and style.
 	Fill(rune, Style)
 
+	// FillRegion fills the w by h rectangle whose top left corner is at
+	// (x, y) with the given character and style, which is much faster
+	// than calling SetContent for each cell.  The rectangle is clipped
+	// to the screen.
+	FillRegion(x, y, w, h int, r rune, style Style)
+
+	// CopyRegion copies the contents of the w by h rectangle whose top
+	// left corner is at (srcX, srcY) so that its top left corner is at
+	// (dstX, dstY), for example to scroll part of the screen.  The
+	// rectangles may overlap.  As with SetContent, the change is not
+	// visible until Show is called.
+	CopyRegion(srcX, srcY, dstX, dstY, w, h int)
+
 	// SetCell is an older API, and will be removed.  Please use
 	// SetContent instead; SetCell is implemented in terms of SetContent.
 	SetCell(x int, y int, style Style, ch ...rune)
 
+	// SetCells sets the contents of many cells at once.  This is the
+	// same as calling SetContent for each of them in turn, but is more
+	// efficient, as the screen only needs to be locked once.
+	SetCells(cells []CellUpdate)
+
 	// GetContent returns the contents at the given location.  If the
 	// coordinates are out of range, then the values will be 0, nil,
 	// StyleDefault.  Note that the contents returned are logical contents
 	// and may not actually be what is displayed, but rather are what will
 	// be displayed if Show() or Sync() is called.  The width is the width
 	// in screen cells; most often this will be 1, but some East Asian
-	// characters require two cells.
+	// characters require two cells.  The cell to the right of a wide
+	// character returns 0, nil, the style of the character, and 0.
 	GetContent(x, y int) (mainc rune, combc []rune, style Style, width int)
 
 	// SetContent sets the contents of the given cell location.  If
@@ -54,15 +80,22 @@ type Screen interface {
 	//
 	// The results are not displayd until Show() or Sync() is called.
 	//
-	// Note that wide (East Asian full width) runes occupy two cells,
-	// and attempts to place character at next cell to the right will have
-	// undefined effects.  Wide runes that are printed in the
-	// last column will be replaced with a single width space on output.
+	// Note that wide (East Asian full width) runes occupy two cells.
+	// The cell to the right is set to be the right half of the rune,
+	// with the same style, and GetContent returns zero for its rune and
+	// width.  Placing a character in either half of a wide rune replaces
+	// the other half with a space, as a terminal would.  Setting the
+	// right half to a zero rune leaves it as it is, so that what
+	// GetContent returns can be passed back to SetContent.  Wide runes
+	// that are printed in the last column will be replaced with a single
+	// width space on output.
 	SetContent(x int, y int, mainc rune, combc []rune, style Style)
 
-	// SetStyle sets the default style to use when clearing the screen
-	// or when StyleDefault is specified.  If it is also StyleDefault,
-	// then whatever system/terminal default is relevant will be used.
+	// SetStyle sets the default style.  It is used for the cells cleared
+	// by Clear, for cells that have never been set, and in place of
+	// StyleDefault, so applications wanting a different background need
+	// not fill every cell themselves.  If it is also StyleDefault, then
+	// whatever system/terminal default is relevant will be used.
 	SetStyle(style Style)
 
 	// ShowCursor is used to display the cursor at a given location.
@@ -74,20 +107,223 @@ type Screen interface {
 	// ShowCursor(-1, -1).
 	HideCursor()
 
+	// SetCursorStyle is used to set the cursor style.  If the style
+	// is not supported (or cursor styles are not supported at all),
+	// then this will have no effect.
+	SetCursorStyle(CursorStyle)
+
+	// SetSoftCursor shows a cursor that is drawn by the screen itself, in
+	// the cell at (x, y), rather than by the terminal, for applications
+	// that manage their own cursor.  The cell is shown with the rune r in
+	// the given style, without changing what it holds.  If r is zero, the
+	// contents of the cell are shown, and if style is StyleDefault, the
+	// style of the cell is used, reversed.  The terminal's own cursor is
+	// hidden while the soft cursor is shown, and calling ShowCursor or
+	// HideCursor removes the soft cursor.  Like other changes, the cursor
+	// is drawn on the next Show or Sync.
+	SetSoftCursor(x, y int, style Style, r rune)
+
 	// Size returns the screen size as width, height.  This changes in
 	// response to a call to Clear or Flush.
 	Size() (int, int)
 
 	// PollEvent waits for events to arrive.  Main application loops
 	// must spin on this to prevent the application from stalling.
-	// Furthermore, this will return nil if the Screen is finalized.
+	// Furthermore, this will return nil if the Screen is finalized, once
+	// any events that were already queued have been returned.
 	PollEvent() Event
 
+	// PollEventContext is like PollEvent, but gives up waiting when
+	// the context is cancelled or expires, in which case it returns
+	// the context's error.  As with PollEvent, the event is nil if
+	// the Screen is finalized.
+	PollEventContext(ctx context.Context) (Event, error)
+
+	// PollEventFor is like PollEvent, but waits for at most the given
+	// time, returning nil for both the event and the error if none
+	// arrived.  This suits loops that need to do something regularly,
+	// such as blinking a cursor.  The timers used are reused, so this
+	// may be called in a tight loop.  If the Screen is finalized,
+	// ErrNoScreen is returned.
+	PollEventFor(d time.Duration) (Event, error)
+
+	// HasPendingEvent returns true if there is at least one event waiting
+	// to be collected, so that PollEvent would not block.  This allows a
+	// burst of events, such as a large paste, to be drained before
+	// redrawing the screen.  This is only a hint if other goroutines
+	// are also collecting events.
+	HasPendingEvent() bool
+
+	// ColorDepth returns how many colors the screen can display,
+	// which is one of ColorDepth8, ColorDepth256, or ColorDepthTrue.
+	ColorDepth() ColorDepth
+
+	// TermInfo returns the optional features that the terminal has
+	// been found to support.
+	TermInfo() TermCapabilities
+
+	// TermVersion returns the name and version of the terminal emulator,
+	// as it reported them in reply to an XTVERSION query, for example
+	// "kitty" and "0.26.5".  This allows working around problems in
+	// particular versions.  The reply arrives a short while after the
+	// screen is initialized, and not all terminals answer, so both may be
+	// empty.  Within a multiplexer that passes queries on, this is the
+	// terminal that the multiplexer is running in, once that has replied.
+	TermVersion() (name, version string)
+
+	// DebugInfo returns a description of what has been detected about the
+	// terminal, and the modes that are in use, to help diagnose problems
+	// with rendering or input.  It has one "key = value" line per item,
+	// in the style of TOML, and includes the terminal type, color depth,
+	// window size, mouse, paste and focus modes, the terminal version
+	// and any capability overrides.  The items present vary with the
+	// kind of screen, and may be added to in the future.
+	DebugInfo() string
+
+	// DrawSixel displays an image, with its top left corner at the given
+	// cell, using Sixel graphics.  This is written to the terminal
+	// directly, rather than being kept with the screen contents, so the
+	// image remains only until the cells beneath it are drawn over, and
+	// it must be drawn again after the screen is synced or resized.
+	// The image is reduced to as many colors as the terminal has color
+	// registers for, if it says (by XTSMGRAPHICS), or else to 256.
+	// ErrNoGraphics is returned if the terminal does not support Sixel.
+	DrawSixel(x, y int, img image.Image) error
+
+	// DrawImage displays an image with the kitty graphics protocol, with
+	// its top left corner at the given cell, and scaled to fit w by h
+	// cells (or at its natural size if these are zero).  The id, if not
+	// zero, identifies the image for DeleteImage, and replaces any
+	// earlier image with the same id.  Unlike Sixel images, these are
+	// kept by the terminal independently of the text, and remain until
+	// deleted.  This does nothing if the terminal lacks support.
+	DrawImage(x, y, w, h int, img image.Image, id uint32) error
+
+	// DeleteImage removes an image displayed by DrawImage.
+	DeleteImage(id uint32)
+
+	// DrawPixelImage approximates an image with text, for terminals
+	// that cannot display images, filling w by h cells starting at the
+	// given cell.  If w or h is zero, it is chosen to show one pixel of
+	// the image for each pixel that the mode can draw.  This changes the
+	// screen contents, so Show must be called afterwards.
+	DrawPixelImage(x, y, w, h int, img image.Image, mode PixelMode)
+
+	// DrawText draws text in a single style, starting at the given cell,
+	// and returns the number of columns used.  Each grapheme cluster
+	// (such as a letter with accents, or an emoji sequence) fills one
+	// cell, or two if it is wide.  Text that would go beyond the right
+	// edge of the screen is left out, as are control characters.  This
+	// changes the screen contents, so Show must be called afterwards.
+	DrawText(x, y int, style Style, text string) int
+
+	// DrawBox draws the border of a box, w by h cells, with its top left
+	// corner at the given cell, using the box drawing characters that
+	// border selects.  The inside of the box is left alone.  A box only
+	// one cell high or wide is drawn as a line, and nothing is drawn if
+	// either w or h is less than one.  This changes the screen contents,
+	// so Show must be called afterwards.
+	DrawBox(x, y, w, h int, style Style, border BoxStyle)
+
+	// DrawHLine draws a horizontal line, w cells long, starting at the
+	// given cell and going right, using the box drawing characters that
+	// border selects.
+	DrawHLine(x, y, w int, style Style, border BoxStyle)
+
+	// DrawVLine is like DrawHLine, but draws a vertical line, h cells
+	// long, going down.
+	DrawVLine(x, y, h int, style Style, border BoxStyle)
+
+	// SetCrossing sets the given cell to the box drawing character that
+	// joins up the lines in the cells around it, such as a cross where
+	// two lines cross, or a tee where a line meets a box.  Use it after
+	// drawing the lines and boxes, at each place that they meet.
+	SetCrossing(x, y int, style Style, border BoxStyle)
+
+	// DrawANSI draws text containing ANSI escape sequences (see ParseANSI)
+	// starting at the given cell, such as the colorized output of another
+	// program.  The style set by the escape sequences is merged with base
+	// (see Style.Merge), so base is used where the text sets no style of
+	// its own, and attributes that the text turns off, as with SGR 22 for
+	// normal intensity, are removed from base.  A newline moves to the start of the next row, at the
+	// original column, and tabs are expanded to every 8 columns.  It
+	// returns the number of characters drawn, and ErrBadEscape if the text
+	// ends part way through an escape sequence.  This changes the screen
+	// contents, so Show must be called afterwards.
+	DrawANSI(x, y int, s string, base Style) (int, error)
+
+	// SetResizeDebounce delays handling changes to the window size
+	// until no further changes have been seen for the given duration.
+	// This avoids redrawing many times while a window is being dragged
+	// to a new size.  The default of zero handles every change at once.
+	// Not all screens honor this.
+	SetResizeDebounce(d time.Duration)
+
+	// TParm looks up the named terminfo string capability, such as "cup"
+	// or "setaf", substitutes the parameters into it, and sends the result
+	// to the terminal immediately.  This is for advanced uses that tcell
+	// has no API for; sending sequences behind tcell's back can leave the
+	// screen out of step with what tcell believes is displayed.  If the
+	// capability is not known, or this is not a terminal based screen,
+	// ErrNoCapability is returned.
+	TParm(capName string, params ...int) error
+
+	// SetEscapeTimeout sets how long to wait, after an escape character
+	// or the start of a longer sequence is received, for the rest of the
+	// sequence to arrive.  If nothing more arrives in that time, what was
+	// received is taken on its own, so a lone escape is reported as KeyEsc.
+	// Editors that use the escape key to change modes may want this to be
+	// short.  Zero selects the default of 50 milliseconds.  Screens that
+	// do not receive input as escape sequences ignore this.
+	SetEscapeTimeout(d time.Duration)
+
+	// SetComposeMap makes the screen compose characters from the key
+	// sequences in m, as dead keys do.  Keys that might start a sequence
+	// are held back until it is known whether they do, and a complete
+	// sequence is reported as a single KeyRune event for the composed
+	// character.  If the sequence is broken, the keys are reported as
+	// they were.  A nil map, the default, turns this off.
+	SetComposeMap(m *ComposeMap)
+
+	// SetScrollAccumThreshold makes the screen collect vertical mouse
+	// wheel events, and post an EventScroll for each lines of them in the
+	// same direction, instead of the wheel events themselves.  This gives
+	// applications a consistent rate of scrolling, whatever the terminal.
+	// The default of zero delivers wheel events as they are.
+	SetScrollAccumThreshold(lines int)
+
+	// MouseButtonState returns the mouse buttons that are currently held
+	// down, as tracked from the button press and release events seen so
+	// far.  This tells an application that is handling a press of one
+	// button whether others are already held.  Wheel motion is never
+	// included.
+	MouseButtonState() ButtonMask
+
+	// SetMaxFPS limits how often the screen is redrawn, to at most fps
+	// frames each second.  When Show is called too soon after the last
+	// frame, it returns at once, and the changes are drawn when the frame
+	// time is up, together with any made in the meantime.  Sync is not
+	// held back.  The default of zero means there is no limit.  Not all
+	// screens honor this.
+	SetMaxFPS(fps int)
+
+	// SetTicker arranges for an EventTicker to be posted at the given
+	// interval, which is useful for animations.  A zero interval stops
+	// the ticks.  No ticks are posted while the screen is suspended.
+	SetTicker(d time.Duration)
+
 	// PostEvent tries to post an event into the event stream.  This
 	// can fail if the event queue is full.  In that case, the event
 	// is dropped, and ErrEventQFull is returned.
 	PostEvent(ev Event) error
 
+	// PostFunc posts an EventFunc carrying f into the event stream.
+	// The application's event loop should run it by calling the event's
+	// Call method, which makes this a safe way for other goroutines to
+	// update the display.  Like PostEvent, this fails with ErrEventQFull
+	// if the event queue is full.
+	PostFunc(f func()) error
+
 	// Deprecated: PostEventWait is unsafe, and will be removed
 	// in the future.
 	//
@@ -115,6 +351,13 @@ type Screen interface {
 	// DisablePaste() disables bracketed paste mode.
 	DisablePaste()
 
+	// EnableFocus enables reporting of focus changes with EventFocus,
+	// if supported.
+	EnableFocus()
+
+	// DisableFocus disables reporting of focus changes.
+	DisableFocus()
+
 	// HasMouse returns true if the terminal (apparently) supports a
 	// mouse.  Note that the a return value of true doesn't guarantee that
 	// a mouse/pointing device is present; a false return definitely
@@ -137,12 +380,50 @@ type Screen interface {
 	// physical display, assuming that it is not synchronized with any
 	// internal model.  This may be both expensive and visually jarring,
 	// so it should only be used when believed to actually be necessary.
+	// The display is always cleared and redrawn in full, whether or not
+	// the contents of any cell have changed.
 	//
 	// Typically this is called as a result of a user-requested redraw
 	// (e.g. to clear up on screen corruption caused by some other program),
 	// or during a resize event.
 	Sync()
 
+	// SyncRegion draws every cell in the w by h rectangle whose top left
+	// corner is at (x, y), whether or not it has changed.  This is useful
+	// when only part of the display is known to be wrong, such as when
+	// another program has written to it.  Nothing outside the rectangle
+	// is drawn, so changes made elsewhere are left for the next Show() or
+	// Sync().  The cursor position and the attributes in effect are not
+	// assumed to be known either.
+	SyncRegion(x, y, w, h int)
+
+	// Transaction calls f, and then shows the screen once f returns, so
+	// that all of the changes made by f appear together, as one frame.
+	// Calls to Show made by f, perhaps from functions that do not know
+	// that they are part of something bigger, do nothing, so that the
+	// screen is never seen part way through.  If f calls Sync, then the
+	// screen is drawn with Sync at the end.  Transactions may be nested,
+	// in which case the screen is shown when the outermost one ends.
+	// Other goroutines may still change the contents of the screen while
+	// f runs, but they cannot show them until it returns.
+	Transaction(f func())
+
+	// HardReset sends the terminal a full reset (RIS), for when it has
+	// got into a state that Sync cannot recover from, such as being
+	// left in insert mode by another program.  After giving the terminal
+	// a moment to reset, the modes that tcell uses are set up again, and
+	// the display is completely redrawn.  Unlike calling Fini and then
+	// Init, the terminal is not closed and reopened.  Nothing is done
+	// while the screen is suspended.
+	HardReset() error
+
+	// SoftReset sends the terminal a soft reset (DECSTR), which puts
+	// right things such as scrolling margins and character sets without
+	// the full teardown of HardReset.  The modes that tcell uses are then
+	// set up again, and the display is redrawn.  Nothing is done while
+	// the screen is suspended.
+	SoftReset() error
+
 	// CharacterSet returns information about the character set.
 	// This isn't the full locale, but it does give us the input/output
 	// character set.  Note that this is just for diagnostic purposes,
@@ -207,30 +488,255 @@ type Screen interface {
 
 	// Suspend pauses input and output processing.  It also restores the
 	// terminal settings to what they were when the application started.
-	// This can be used to, for example, run a sub-shell.
+	// This can be used to, for example, run a sub-shell.  The contents
+	// of the screen and its settings are retained while suspended.
 	Suspend() error
 
-	// Resume resumes after Suspend().
+	// Resume resumes after Suspend().  The screen size is checked again
+	// (posting an EventResize if it changed), and the retained contents
+	// are redrawn.
 	Resume() error
 
+	// SetTermDriver replaces the TermDriver of a terminal screen, for
+	// example when an SSH client has reconnected.  The terminal is
+	// released, the new driver is initialized, and then the terminal is
+	// set up again, with everything redrawn from the retained contents
+	// and an EventResize posted.  The new terminal is assumed to be of
+	// the same type as the old one.  Closing the old driver's files is
+	// left to its owner.  Other kinds of screen have no TermDriver, and
+	// return ErrNoScreen.
+	SetTermDriver(driver TermDriver) error
+
 	// Beep attempts to sound an OS-dependent audible alert and returns an error
 	// when unsuccessful.
 	Beep() error
+
+	// SetBellMode selects what Beep does: sound the bell, flash the
+	// screen, or both.  Flashing suits terminals whose audible bell has
+	// been turned off.  The default is BellAudio.
+	SetBellMode(mode BellMode)
+
+	// SetVisualBellDuration sets how long the screen is flashed for
+	// by Beep, if the bell mode calls for it.  If zero, the default of
+	// 100 milliseconds is used.
+	SetVisualBellDuration(d time.Duration)
+
+	// Flash briefly shows the whole screen in reverse video, for the
+	// given time, or for 100 milliseconds if it is zero.  This can be
+	// used to draw attention to an error, much like a visual bell.  It
+	// returns at once, without waiting for the flash to finish.
+	Flash(d time.Duration)
+
+	// SetClipboard stores data in the given selection, which is one of
+	// "c" (the clipboard), "p" (the primary selection), or "s" (the
+	// secondary selection).  For terminals this uses OSC 52, and there
+	// is no way to tell whether the terminal actually honored it.
+	SetClipboard(selection string, data []byte) error
+
+	// GetClipboard returns the contents of the given selection (see
+	// SetClipboard).  For terminals this asks the terminal using OSC 52,
+	// and waits (briefly) for the reply.  Many terminals do not permit
+	// applications to read the clipboard, in which case ErrNoClipboard
+	// is returned.  Note that the reply is processed along with other
+	// input, so this may fail if the application is not draining events
+	// with PollEvent.
+	GetClipboard(selection string) ([]byte, error)
+
+	// QueryBackgroundColor asks the terminal for its background color,
+	// using OSC 11, and waits (briefly) for the reply.  This lets an
+	// application choose colors that suit a dark or light background.
+	// If the terminal does not answer, ErrNoCapability is returned.  As
+	// with GetClipboard, the reply is processed along with other input.
+	// Once known, the background color is also used when colors must be
+	// approximated from a limited palette, so that the foreground colors
+	// chosen are not hard to tell apart from it.
+	QueryBackgroundColor() (Color, error)
+
+	// BackgroundColor returns the background color last reported by the
+	// terminal, or ColorDefault if it is not known.  This includes changes
+	// that the terminal reports unasked, which are also posted as an
+	// EventColorChange.
+	BackgroundColor() Color
+
+	// QueryForegroundColor is like QueryBackgroundColor, but asks for
+	// the default foreground color, using OSC 10.  Once known, it is used
+	// in the same way when approximating background colors.
+	QueryForegroundColor() (Color, error)
+
+	// ForegroundColor returns the foreground color last reported by the
+	// terminal, or ColorDefault if it is not known.
+	ForegroundColor() Color
+
+	// SetTitle sets the title of the window (or tab) the screen is
+	// displayed in, if supported.  Terminal screens need the terminfo
+	// tsl and fsl capabilities for this, and otherwise do nothing.
+	SetTitle(title string)
+
+	// SetIconTitle sets the title used when the window is iconified,
+	// if supported.  Terminal screens can only do this when tsl starts
+	// an XTerm style title sequence.  Most modern terminals ignore this.
+	SetIconTitle(title string)
+
+	// SetColumns switches the terminal between 80 and 132 columns, with
+	// DECCOLM, as some older applications expect.  Other column counts
+	// return ErrUnsupported.  The terminal clears itself when it does
+	// this, so everything is drawn again by the next Show, and an
+	// EventResize is posted with the new size.  Many terminal emulators
+	// ignore DECCOLM, or only honor it if the user allows it, so if the
+	// width does not change ErrNoCapability is returned.  Fini puts
+	// the terminal back to the width it had before.
+	SetColumns(n int) error
 }
 
 // NewScreen returns a default Screen suitable for the user's terminal
 // environment.
 func NewScreen() (Screen, error) {
+	return NewScreenWithOptions(ScreenOptions{})
+}
+
+// ScreenOptions holds settings that are fixed when a Screen is created.
+// The zero value selects the defaults for everything.
+type ScreenOptions struct {
+	// EventQueueSize is the number of events that can be waiting to be
+	// polled before PostEvent fails with ErrEventQFull.  If zero, the
+	// default of 10 is used.
+	EventQueueSize int
+
+	// PingInterval is how often a terminal screen checks that its
+	// TermDriver is still connected, by calling its Ping method, if it
+	// implements TermDriverPinger.  If zero, no checks are made.
+	PingInterval time.Duration
+
+	// WriteBufferSize is the size of the buffer that a terminal screen
+	// uses for output.  Each frame is collected and then written out in
+	// pieces of at most this size, as is other output, such as images,
+	// with a single flush at the end.
+	// If zero, the default of 32 KiB is used.
+	WriteBufferSize int
+
+	// CapabilityOverrides replaces capabilities in the terminal database
+	// entry used by a terminal screen, keyed by their terminfo names.  This
+	// works around terminals that misreport what they can do.  See
+	// terminfo.Terminfo.SetCapability for the capabilities that can be
+	// set, and how their values are given.  Unknown capabilities cause
+	// ErrNoCapability to be returned when the screen is created.
+	//
+	// Users may give overrides too, in the TCELL_TERMINFO_OVERRIDES
+	// environment variable, which take precedence over these.  That holds
+	// name=value pairs separated by commas, such as "colors=256,bel=",
+	// and the values may use the escapes of terminfo source, such as \E
+	// for escape, ^G for a control character, and \, for a comma.
+	CapabilityOverrides map[string]string
+}
+
+// eventQueueSize returns the capacity to use for the event channel.
+func (o ScreenOptions) eventQueueSize() int {
+	if o.EventQueueSize > 0 {
+		return o.EventQueueSize
+	}
+	return 10
+}
+
+// writeBufferSize returns the size to use for output buffers.
+func (o ScreenOptions) writeBufferSize() int {
+	if o.WriteBufferSize > 0 {
+		return o.WriteBufferSize
+	}
+	return 32 << 10
+}
+
+// optionSetter is implemented by our screens to accept ScreenOptions.
+// This must be called before Init.
+type optionSetter interface {
+	setOptions(ScreenOptions)
+}
+
+// NewScreenWithOptions is like NewScreen, but uses the given options
+// instead of the defaults.
+func NewScreenWithOptions(opts ScreenOptions) (Screen, error) {
+	var s Screen
+	var e error
 	// Windows is happier if we try for a console screen first.
-	if s, _ := NewConsoleScreen(); s != nil {
-		return s, nil
-	} else if s, e := NewTerminfoScreen(); s != nil {
-		return s, nil
+	if s, _ = NewConsoleScreen(); s == nil {
+		if s, e = NewTerminfoScreenWithOptions(&defaultTermDriver{}, opts); s == nil {
+			return nil, e
+		}
+	}
+	if setter, ok := s.(optionSetter); ok {
+		setter.setOptions(opts)
+	}
+	return s, nil
+}
+
+// defaultEscapeTimeout is how long a partial escape sequence is held,
+// unless SetEscapeTimeout is used.
+const defaultEscapeTimeout = 50 * time.Millisecond
+
+// frameTime returns the shortest time a frame may take to keep to the
+// given number of frames per second, or zero if there is no limit.
+func frameTime(fps int) time.Duration {
+	if fps <= 0 {
+		return 0
+	}
+	return time.Second / time.Duration(fps)
+}
+
+// pendingEvent returns an event that was still queued when the screen
+// was finished, such as the EventError that made a terminal screen shut
+// itself down, or nil if there is none.
+func pendingEvent(evch chan Event) Event {
+	select {
+	case ev := <-evch:
+		return ev
+	default:
+		return nil
+	}
+}
+
+// pollTimers holds timers for pollEventFor to reuse.
+var pollTimers sync.Pool
+
+// pollEventFor waits for at most d for an event, as for PollEventFor.
+func pollEventFor(evch chan Event, quit chan struct{}, d time.Duration) (Event, error) {
+	tm, _ := pollTimers.Get().(*time.Timer)
+	if tm == nil {
+		tm = time.NewTimer(d)
 	} else {
-		return nil, e
+		tm.Reset(d)
+	}
+	defer func() {
+		// make sure that the timer is stopped and drained when reused
+		if !tm.Stop() {
+			select {
+			case <-tm.C:
+			default:
+			}
+		}
+		pollTimers.Put(tm)
+	}()
+
+	select {
+	case <-quit:
+		if ev := pendingEvent(evch); ev != nil {
+			return ev, nil
+		}
+		return nil, ErrNoScreen
+	case ev := <-evch:
+		return ev, nil
+	case <-tm.C:
+		return nil, nil
 	}
 }
 
+// validSelection returns true if sel names a clipboard selection.
+func validSelection(sel string) bool {
+	switch sel {
+	case "c", "p", "s":
+		return true
+	}
+	return false
+}
+
 // MouseFlags are options to modify the handling of mouse events.
 // Actual events can be or'd together.
 type MouseFlags int
@@ -239,4 +745,33 @@ const (
 	MouseButtonEvents = MouseFlags(1) // Click events only
 	MouseDragEvents   = MouseFlags(2) // Click-drag events (includes button events)
 	MouseMotionEvents = MouseFlags(4) // All mouse events (includes click and drag events)
+	MousePixelMotion  = MouseFlags(8) // All mouse events, with positions also reported in pixels
+)
+
+// BellMode says how Beep alerts the user.
+type BellMode int
+
+const (
+	BellAudio  = BellMode(iota) // Sound the bell (the default)
+	BellVisual                  // Flash the screen
+	BellBoth                    // Sound the bell and flash the screen
+)
+
+// defaultFlashTime is how long the screen is flashed for when no other
+// duration is given.
+const defaultFlashTime = 100 * time.Millisecond
+
+// CursorStyle represents a given cursor style, which can include the shape
+// and whether the cursor blinks or is solid.  Support for changing this is
+// not universal.
+type CursorStyle int
+
+const (
+	CursorStyleDefault           = CursorStyle(iota) // The default
+	CursorStyleBlinkingBlock                         // Blinking block
+	CursorStyleSteadyBlock                           // Steady block
+	CursorStyleBlinkingUnderline                     // Blinking underline
+	CursorStyleSteadyUnderline                       // Steady underline
+	CursorStyleBlinkingBar                           // Blinking bar
+	CursorStyleSteadyBar                             // Steady bar
 )
@@ -0,0 +1,95 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"time"
+)
+
+// EventScroll is sent in place of mouse wheel events, when the screen has
+// been asked to accumulate them with SetScrollAccumThreshold.  Terminals
+// differ a great deal in how many wheel events they send for the same
+// physical movement, and this lets applications scroll by a consistent
+// amount.
+type EventScroll struct {
+	t   time.Time
+	x   int
+	y   int
+	mod ModMask
+
+	// Delta is the number of steps to scroll.  It is positive for
+	// scrolling down (towards the user), and negative for up.
+	Delta int
+}
+
+// When returns the time when this EventScroll was created.
+func (ev *EventScroll) When() time.Time {
+	return ev.t
+}
+
+// Position returns the mouse position, in character cells, of the last
+// wheel event that contributed to this one.
+func (ev *EventScroll) Position() (int, int) {
+	return ev.x, ev.y
+}
+
+// Modifiers returns the keyboard modifiers that were pressed with the
+// last wheel event that contributed to this one.
+func (ev *EventScroll) Modifiers() ModMask {
+	return ev.mod
+}
+
+// NewEventScroll returns a new EventScroll.
+func NewEventScroll(x, y, delta int, mod ModMask) *EventScroll {
+	return &EventScroll{t: time.Now(), x: x, y: y, mod: mod, Delta: delta}
+}
+
+// scrollAccum collects vertical wheel events, so that they can be
+// reported as EventScroll once there have been enough of them.
+type scrollAccum struct {
+	threshold int
+	count     int
+}
+
+// filter returns the event that should be posted in place of ev.  When
+// accumulation is enabled, plain vertical wheel events are swallowed,
+// returning nil, until threshold of them in the same direction have been
+// seen.  Everything else is returned as is.
+func (sa *scrollAccum) filter(ev Event) Event {
+	mev, ok := ev.(*EventMouse)
+	if !ok || sa.threshold <= 0 {
+		return ev
+	}
+	dir := 0
+	switch mev.Buttons() {
+	case WheelUp:
+		dir = -1
+	case WheelDown:
+		dir = 1
+	default:
+		return ev
+	}
+	if sa.count*dir < 0 {
+		// changed direction, so start again
+		sa.count = 0
+	}
+	sa.count += dir
+	if sa.count*dir < sa.threshold {
+		return nil
+	}
+	sa.count = 0
+	x, y := mev.Position()
+	return NewEventScroll(x, y, dir, mev.Modifiers())
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"strconv"
+)
+
+// sgrState is the rendition that a terminal gives to the text it is sent,
+// as set with SGR (Select Graphic Rendition) sequences.  The colors are
+// those that the terminal is actually sent, so RGB colors are only found
+// here if the terminal can show them, and ColorReset is ColorDefault.
+// The underline style and color are only kept while underlining is on.
+type sgrState struct {
+	fg      Color
+	bg      Color
+	attrs   AttrMask
+	ulStyle int
+	ulColor Color
+}
+
+// sgrOff has the SGR parameters that turn off each attribute.  Bold and
+// dim are both turned off by 22.
+var sgrOff = []struct {
+	attr  AttrMask
+	param string
+}{
+	{AttrBold | AttrDim, "22"},
+	{AttrItalic, "23"},
+	{AttrUnderline, "24"},
+	{AttrBlink, "25"},
+	{AttrReverse, "27"},
+	{AttrInvisible, "28"},
+	{AttrStrikeThrough, "29"},
+	{AttrOverline, "55"},
+}
+
+// sgrOn has the SGR parameters that turn on each attribute, apart from
+// underline, which has a style.
+var sgrOn = []struct {
+	attr  AttrMask
+	param string
+}{
+	{AttrBold, "1"},
+	{AttrDim, "2"},
+	{AttrItalic, "3"},
+	{AttrBlink, "5"},
+	{AttrReverse, "7"},
+	{AttrInvisible, "8"},
+	{AttrStrikeThrough, "9"},
+	{AttrOverline, "53"},
+}
+
+// sgrDiff returns the SGR sequence that takes a terminal from the old
+// state to the new one, which is empty if they are the same.  Only what
+// differs is changed, using the SGR parameters that turn attributes off
+// one at a time, unless starting again with a full reset is shorter.
+func sgrDiff(old, new sgrState) []byte {
+	params := sgrParams(nil, old, new)
+	if len(params) == 0 {
+		return nil
+	}
+	if reset := sgrParams([]byte{'0'}, sgrState{}, new); len(reset) < len(params) {
+		params = reset
+	}
+	b := make([]byte, 0, len(params)+3)
+	b = append(b, "\x1b["...)
+	b = append(b, params...)
+	return append(b, 'm')
+}
+
+// sgrParams appends to b the SGR parameters, separated by semicolons,
+// that change the old state to the new one.
+func sgrParams(b []byte, old, new sgrState) []byte {
+	off := old.attrs &^ new.attrs
+	on := new.attrs &^ old.attrs
+	for _, a := range sgrOff {
+		if off&a.attr != 0 {
+			b = sgrParam(b, a.param)
+			// Whichever of bold and dim stays on must be turned
+			// on again.
+			on |= new.attrs & a.attr
+		}
+	}
+	for _, a := range sgrOn {
+		if on&a.attr != 0 {
+			b = sgrParam(b, a.param)
+		}
+	}
+	if new.attrs&AttrUnderline != 0 &&
+		(on&AttrUnderline != 0 || new.ulStyle != old.ulStyle) {
+		if new.ulStyle == ulSolid {
+			b = sgrParam(b, "4")
+		} else {
+			b = sgrParam(b, "4:"+strconv.Itoa(new.ulStyle))
+		}
+	}
+	if new.ulColor != old.ulColor {
+		b = sgrUnderlineColor(b, new.ulColor)
+	}
+	if new.fg != old.fg {
+		b = sgrColorParam(b, new.fg, "3", "9", "38", "39")
+	}
+	if new.bg != old.bg {
+		b = sgrColorParam(b, new.bg, "4", "10", "48", "49")
+	}
+	return b
+}
+
+// sgrParam appends one SGR parameter to b.
+func sgrParam(b []byte, p string) []byte {
+	if len(b) != 0 {
+		b = append(b, ';')
+	}
+	return append(b, p...)
+}
+
+// sgrColorParam appends the SGR parameter for the color c to b.  The
+// first eight palette colors use the given prefix (as 3 for 31), the
+// next eight the bright prefix (as 9 for 91), and the others the
+// extended one (as 38;5;n).  ColorDefault uses the reset parameter.
+func sgrColorParam(b []byte, c Color, prefix, bright, extended, reset string) []byte {
+	n := int(c & 0xff)
+	switch {
+	case !c.Valid():
+		return sgrParam(b, reset)
+	case c.IsRGB():
+		r, g, bl := c.RGB()
+		return sgrParam(b, extended+";2;"+strconv.Itoa(int(r))+";"+
+			strconv.Itoa(int(g))+";"+strconv.Itoa(int(bl)))
+	case n < 8:
+		return sgrParam(b, prefix+strconv.Itoa(n))
+	case n < 16:
+		return sgrParam(b, bright+strconv.Itoa(n-8))
+	}
+	return sgrParam(b, extended+";5;"+strconv.Itoa(n))
+}
+
+// sgrUnderlineColor appends the SGR parameter for the underline color c
+// to b.  This has only the extended form, with colons, as XTerm uses.
+func sgrUnderlineColor(b []byte, c Color) []byte {
+	switch {
+	case !c.Valid():
+		return sgrParam(b, "59")
+	case c.IsRGB():
+		r, g, bl := c.RGB()
+		return sgrParam(b, "58:2::"+strconv.Itoa(int(r))+":"+
+			strconv.Itoa(int(g))+":"+strconv.Itoa(int(bl)))
+	}
+	return sgrParam(b, "58:5:"+strconv.Itoa(int(c&0xff)))
+}
@@ -0,0 +1,82 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2/terminfo"
+)
+
+func TestSGRDiff(t *testing.T) {
+	red := PaletteColor(1)
+	bold := sgrState{attrs: AttrBold}
+	curly := sgrState{attrs: AttrUnderline, ulStyle: ulCurly, ulColor: red}
+	redCurly := sgrState{fg: red, attrs: AttrBold | AttrUnderline, ulStyle: ulCurly, ulColor: red}
+	cases := []struct {
+		name     string
+		old, new sgrState
+		expect   string
+	}{
+		{"same", bold, bold, ""},
+		{"bold on", sgrState{}, bold, "\x1b[1m"},
+		{"default fg", sgrState{fg: red, attrs: AttrBold}, bold, "\x1b[39m"},
+		{"bold off", sgrState{attrs: AttrBold | AttrItalic}, sgrState{attrs: AttrItalic}, "\x1b[22m"},
+		{"dim stays", sgrState{fg: red, attrs: AttrBold | AttrDim}, sgrState{fg: red, attrs: AttrDim}, "\x1b[22;2m"},
+		{"underline", bold, sgrState{attrs: AttrBold | AttrUnderline}, "\x1b[4m"},
+		{"curly", sgrState{}, curly, "\x1b[4:3;58:5:1m"},
+		{"underline off", redCurly, sgrState{fg: red, attrs: AttrBold}, "\x1b[24;59m"},
+		{"bright", sgrState{}, sgrState{fg: PaletteColor(9), bg: PaletteColor(12)}, "\x1b[91;104m"},
+		{"palette", sgrState{}, sgrState{fg: PaletteColor(200)}, "\x1b[38;5;200m"},
+		{"rgb", sgrState{}, sgrState{bg: NewRGBColor(1, 2, 3)}, "\x1b[48;2;1;2;3m"},
+		{"reset", sgrState{attrs: AttrBold | AttrItalic | AttrReverse, fg: red}, sgrState{}, "\x1b[0m"},
+		{"reset shorter", sgrState{attrs: AttrItalic | AttrReverse | AttrBlink}, bold, "\x1b[0;1m"},
+	}
+	for _, c := range cases {
+		if out := string(sgrDiff(c.old, c.new)); out != c.expect {
+			t.Errorf("%s: expected %q, got %q", c.name, c.expect, out)
+		}
+	}
+}
+
+func TestPrepareSGR(t *testing.T) {
+	cases := []struct {
+		term   string
+		direct bool
+		attrs  AttrMask
+	}{
+		{"xterm-256color", true, AttrBold | AttrDim | AttrItalic | AttrUnderline |
+			AttrBlink | AttrReverse | AttrInvisible | AttrStrikeThrough},
+		// screen has no italics, and SGR 3 is standout to it
+		{"screen", true, AttrBold | AttrDim | AttrUnderline | AttrBlink | AttrReverse},
+		{"vt100", false, AttrNone},
+	}
+	for _, c := range cases {
+		ti, err := terminfo.LookupTerminfo(c.term)
+		if err != nil {
+			t.Fatalf("No terminfo for %s: %v", c.term, err)
+		}
+		ts := &tScreen{ti: ti}
+		ts.prepareSGR()
+		if ts.sgrDirect != c.direct || ts.sgrAttrs != c.attrs {
+			t.Errorf("%s: expected %v %x, got %v %x", c.term,
+				c.direct, c.attrs, ts.sgrDirect, ts.sgrAttrs)
+		}
+		if st := ts.sgrFor(StyleDefault.Italic(true).Overline(true)); c.direct &&
+			st.attrs&AttrOverline != 0 {
+			t.Errorf("%s: overline without styled underlines", c.term)
+		}
+	}
+}
@@ -15,6 +15,10 @@
 package tcell
 
 import (
+	"image"
+	"image/color"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -63,6 +67,19 @@ func TestClearScreen(t *testing.T) {
 	}
 }
 
+func TestSetStyle(t *testing.T) {
+	st := StyleDefault.Background(ColorNavy)
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+	s.SetStyle(st)
+	s.Clear()
+	s.Show()
+	b, _, _ := s.GetContents()
+	if b[0].Style != st || b[len(b)-1].Style != st {
+		t.Errorf("Cleared cells should have the default style")
+	}
+}
+
 func TestSetCell(t *testing.T) {
 	st := StyleDefault.Background(ColorRed).Blink(true)
 	s := mkTestScreen(t, "")
@@ -150,3 +167,786 @@ func TestBeep(t *testing.T) {
 		}
 	}
 }
+
+func TestDrawPixelImage(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	img := image.NewRGBA(image.Rect(0, 0, 2, 4))
+	for x := 0; x < 2; x++ {
+		img.Set(x, 0, color.RGBA{R: 0xff, A: 0xff})
+		img.Set(x, 1, color.RGBA{B: 0xff, A: 0xff})
+	}
+	img.Set(0, 2, color.White)
+
+	s.DrawPixelImage(0, 0, 0, 0, img, PixelModeHalfBlock)
+	if c, _, st, _ := s.GetContent(1, 0); c != '▀' ||
+		st != StyleDefault.Foreground(NewRGBColor(0xff, 0, 0)).
+			Background(NewRGBColor(0, 0, 0xff)) {
+		t.Errorf("Bad half block cell %c %v", c, st)
+	}
+	if c, _, st, _ := s.GetContent(0, 1); c != '▀' ||
+		st != StyleDefault.Foreground(NewRGBColor(0xff, 0xff, 0xff)).
+			Background(NewRGBColor(0, 0, 0)) {
+		t.Errorf("Bad half block cell %c %v", c, st)
+	}
+
+	s.DrawPixelImage(0, 0, 0, 0, img, PixelModeBraille)
+	if c, _, _, _ := s.GetContent(0, 0); c != 0x2800|0x01|0x08|0x04 {
+		t.Errorf("Bad braille cell %U", c)
+	}
+}
+
+func TestInjectKeyBytes(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	if !s.InjectKeyBytes([]byte("a\x1bOA\x1b[1;5C\x1b[<0;3;4M")) {
+		t.Errorf("Failed to inject bytes")
+	}
+	evk := s.PollEvent().(*EventKey)
+	if evk.Key() != KeyRune || evk.Rune() != 'a' {
+		t.Errorf("Expected rune a, got %s", evk.Name())
+	}
+	evk = s.PollEvent().(*EventKey)
+	if evk.Key() != KeyUp || evk.Modifiers() != ModNone {
+		t.Errorf("Expected up arrow, got %s", evk.Name())
+	}
+	evk = s.PollEvent().(*EventKey)
+	if evk.Key() != KeyRight || evk.Modifiers() != ModCtrl {
+		t.Errorf("Expected ctrl right arrow, got %s", evk.Name())
+	}
+	evm := s.PollEvent().(*EventMouse)
+	if x, y := evm.Position(); x != 2 || y != 3 || evm.Buttons() != Button1 {
+		t.Errorf("Bad mouse event at %d,%d", x, y)
+	}
+
+	if s.InjectKeyBytes([]byte{0xff}) {
+		t.Errorf("Invalid UTF-8 should not be accepted")
+	}
+}
+
+func TestInjectKeyBytesConcurrent(t *testing.T) {
+	const n, m = 8, 20
+	s := NewSimulationScreen("")
+	s.(optionSetter).setOptions(ScreenOptions{EventQueueSize: n * m})
+	if e := s.Init(); e != nil {
+		t.Fatalf("Failed to initialize screen: %v", e)
+	}
+	defer s.Fini()
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			s.InjectKeyBytes([]byte(strings.Repeat("\x1b[1;5C", m)))
+		}()
+	}
+	wg.Wait()
+	// each sequence is parsed whole, however the calls overlap
+	for i := 0; i < n*m; i++ {
+		evk := s.PollEvent().(*EventKey)
+		if evk.Key() != KeyRight || evk.Modifiers() != ModCtrl {
+			t.Fatalf("Expected ctrl right arrow, got %s", evk.Name())
+		}
+	}
+}
+
+func TestSetSizeEvent(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	for _, sz := range []struct{ w, h int }{{40, 10}, {100, 30}} {
+		s.SetSize(sz.w, sz.h)
+		ev, ok := s.PollEvent().(*EventResize)
+		if !ok {
+			t.Fatalf("Expected resize event")
+		}
+		if w, h := ev.Size(); w != sz.w || h != sz.h {
+			t.Errorf("Expected size %dx%d, got %dx%d", sz.w, sz.h, w, h)
+		}
+		if w, h := s.Size(); w != sz.w || h != sz.h {
+			t.Errorf("Screen size is %dx%d", w, h)
+		}
+	}
+}
+
+func TestRenderANSI(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	s.SetSize(3, 2)
+	s.SetContent(0, 0, 'a', nil, StyleDefault.Foreground(ColorRed))
+	s.SetContent(1, 0, 'b', nil, StyleDefault.Foreground(ColorRed))
+	s.SetContent(0, 1, 'c', nil, StyleDefault.Bold(true))
+
+	expect := "\x1b(B\x1b[m\x1b[91mab\x1b[0m \r\n" +
+		"\x1b[1mc\x1b[0m  \x1b(B\x1b[m"
+	if out := string(s.RenderANSI()); out != expect {
+		t.Errorf("Bad rendering %q", out)
+	}
+}
+
+func TestFillRegion(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	st := StyleDefault.Background(ColorBlue)
+	s.FillRegion(78, -1, 5, 3, '#', st)
+	for y := 0; y < 3; y++ {
+		for x := 76; x < 80; x++ {
+			c, _, cst, _ := s.GetContent(x, y)
+			if (x >= 78 && y < 2) != (c == '#' && cst == st) {
+				t.Errorf("Wrong content %c at %d,%d", c, x, y)
+			}
+		}
+	}
+}
+
+func TestCopyRegion(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	for y := 0; y < 4; y++ {
+		s.SetContent(0, y, rune('a'+y), nil, StyleDefault)
+	}
+	// scroll rows 1-3 up by one, overlapping the source
+	s.CopyRegion(0, 1, 0, 0, 1, 3)
+	for y, expect := range "bcdd" {
+		if c, _, _, _ := s.GetContent(0, y); c != expect {
+			t.Errorf("Expected %c at row %d, got %c", expect, y, c)
+		}
+	}
+	// copying from outside the screen changes nothing
+	s.CopyRegion(-1, 0, 0, 0, 1, 1)
+	if c, _, _, _ := s.GetContent(0, 0); c != 'b' {
+		t.Errorf("Cell changed by copy from outside: %c", c)
+	}
+	// scroll rows 0-2 down by one, overlapping the other way
+	s.CopyRegion(0, 0, 0, 1, 1, 3)
+	for y, expect := range "bbcd" {
+		if c, _, _, _ := s.GetContent(0, y); c != expect {
+			t.Errorf("Expected %c at row %d, got %c", expect, y, c)
+		}
+	}
+	// a huge rectangle is clipped to the screen
+	s.CopyRegion(0, 1, 0, 0, 1<<30, 1<<30)
+	for y, expect := range "bcd " {
+		if c, _, _, _ := s.GetContent(0, y); c != expect {
+			t.Errorf("Expected %c at row %d, got %c", expect, y, c)
+		}
+	}
+}
+
+func TestGetContent(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	st := StyleDefault.Foreground(ColorGreen)
+	s.SetContent(3, 2, '世', nil, st)
+	s.SetContent(5, 2, 'e', []rune{'́'}, StyleDefault)
+
+	if c, comb, cst, w := s.GetContent(3, 2); c != '世' || len(comb) != 0 || cst != st || w != 2 {
+		t.Errorf("Wrong wide cell %c %v %v %d", c, comb, cst, w)
+	}
+	if c, comb, _, w := s.GetContent(5, 2); c != 'e' || len(comb) != 1 || comb[0] != '́' || w != 1 {
+		t.Errorf("Wrong combining cell %c %v %d", c, comb, w)
+	}
+	if c, comb, cst, w := s.GetContent(80, 0); c != 0 || comb != nil || cst != StyleDefault || w != 0 {
+		t.Errorf("Out of range cell should be empty")
+	}
+	// the combining runes are a copy, so changing the cell leaves them be
+	_, comb, _, _ := s.GetContent(5, 2)
+	s.SetContent(5, 2, 'a', []rune{'̀'}, StyleDefault)
+	s.Show()
+	if len(comb) != 1 || comb[0] != '́' {
+		t.Errorf("Combining runes changed with the cell: %v", comb)
+	}
+}
+
+func TestSetCells(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	st := StyleDefault.Bold(true)
+	s.SetCells([]CellUpdate{
+		{X: 1, Y: 1, R: 'x', Style: st},
+		{X: 2, Y: 1, R: 'e', Combining: []rune{'́'}},
+		{X: 100, Y: 1, R: 'z'},
+	})
+	if c, _, cst, _ := s.GetContent(1, 1); c != 'x' || cst != st {
+		t.Errorf("Wrong content %c", c)
+	}
+	if c, comb, _, _ := s.GetContent(2, 1); c != 'e' || len(comb) != 1 {
+		t.Errorf("Wrong content %c %v", c, comb)
+	}
+}
+
+func TestMouseDragging(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	steps := []struct {
+		x, y     int
+		btn      ButtonMask
+		dragging bool
+	}{
+		{1, 1, ButtonNone, false},
+		{2, 1, ButtonNone, false},
+		{2, 1, Button1, false},
+		{3, 1, Button1, true},
+		{3, 2, Button1 | WheelUp, true},
+		{3, 2, ButtonNone, false},
+	}
+	for i, step := range steps {
+		s.InjectMouse(step.x, step.y, step.btn, ModNone)
+		ev, ok := s.PollEvent().(*EventMouse)
+		if !ok {
+			t.Fatalf("Step %d: expected a mouse event", i)
+		}
+		if ev.Dragging() != step.dragging {
+			t.Errorf("Step %d: dragging should be %v", i, step.dragging)
+		}
+	}
+}
+
+func TestScrollAccum(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	s.SetScrollAccumThreshold(3)
+	for i := 0; i < 2; i++ {
+		s.InjectMouse(1, 1, WheelUp, ModNone)
+	}
+	// changing direction starts again
+	for i := 0; i < 3; i++ {
+		s.InjectMouse(1, 1, WheelDown, ModNone)
+	}
+	s.InjectMouse(1, 1, Button1, ModNone)
+
+	ev, ok := s.PollEvent().(*EventScroll)
+	if !ok {
+		t.Fatalf("Expected a scroll event")
+	}
+	if ev.Delta != 1 {
+		t.Errorf("Expected to scroll down one step, got %d", ev.Delta)
+	}
+	if _, ok := s.PollEvent().(*EventMouse); !ok {
+		t.Errorf("Expected button events to pass through")
+	}
+}
+
+func TestComposeMap(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	m := &ComposeMap{}
+	m.Add([]rune{'´', 'e'}, 'é')
+	m.Add([]rune{'^', 'o'}, 'ô')
+	m.Add([]rune{'^'}, '^')
+	if r, ok := m.Lookup([]rune{'´', 'e'}); !ok || r != 'é' {
+		t.Errorf("Lookup failed")
+	}
+	s.SetComposeMap(m)
+
+	for _, r := range "´ex^o´z^1" {
+		s.InjectKey(KeyRune, r, ModNone)
+	}
+	s.InjectKey(KeyEnter, '\r', ModNone)
+
+	expect := "éxô´z^1"
+	for _, r := range expect {
+		ev, ok := s.PollEvent().(*EventKey)
+		if !ok || ev.Key() != KeyRune || ev.Rune() != r {
+			t.Fatalf("Expected %q, got %v", r, ev)
+		}
+	}
+	if ev, ok := s.PollEvent().(*EventKey); !ok || ev.Key() != KeyEnter {
+		t.Errorf("Expected enter")
+	}
+}
+
+func TestPasteText(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	s.InjectKeyBytes([]byte("\x1b[200~ab\x07\rc\x1b[201~"))
+	var end *EventPaste
+	for end == nil {
+		switch ev := s.PollEvent().(type) {
+		case *EventPaste:
+			if ev.End() {
+				end = ev
+			}
+		case nil:
+			t.Fatalf("No end of paste")
+		}
+	}
+	if text := end.Text(); text != "ab\x07\rc" {
+		t.Errorf("Bad paste text %q", text)
+	}
+	if text := end.PlainText(); text != "ab\nc" {
+		t.Errorf("Bad plain text %q", text)
+	}
+	if end.MIME() != "text/plain" {
+		t.Errorf("Bad MIME type %q", end.MIME())
+	}
+}
+
+func TestDrawANSI(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	base := StyleDefault.Background(ColorNavy)
+	n, err := s.DrawANSI(2, 1, "a\x1b[31mb\x1b[0m世é\n\tx", base)
+	if err != nil || n != 6 {
+		t.Errorf("Expected 6 characters, got %d (%v)", n, err)
+	}
+	red := base.Foreground(ColorMaroon)
+	var values = []struct {
+		x, y  int
+		r     rune
+		comb  []rune
+		style Style
+	}{
+		{2, 1, 'a', nil, base},
+		{3, 1, 'b', nil, red},
+		{4, 1, '世', nil, base},
+		{6, 1, 'e', []rune{'́'}, base},
+		{2, 2, ' ', nil, base},
+		{9, 2, ' ', nil, base},
+		{10, 2, 'x', nil, base},
+	}
+	for _, v := range values {
+		r, comb, style, _ := s.GetContent(v.x, v.y)
+		if r != v.r || len(comb) != len(v.comb) || style != v.style {
+			t.Errorf("%d,%d: expected %q %q %+v, got %q %q %+v",
+				v.x, v.y, v.r, v.comb, v.style, r, comb, style)
+		}
+	}
+
+	// a flag, or a ZWJ sequence, is one character, in one cell
+	n, _ = s.DrawANSI(0, 3, "\x1b[1m\U0001f1ef\U0001f1f5\x1b[m\U0001f469\u200d\U0001f4bbz", base)
+	if n != 3 {
+		t.Errorf("Expected 3 characters, got %d", n)
+	}
+	if r, comb, style, w := s.GetContent(0, 3); r != '\U0001f1ef' || len(comb) != 1 || w != 2 || style != base.Bold(true) {
+		t.Errorf("Bad flag %q %q %d", r, comb, w)
+	}
+	if r, comb, _, w := s.GetContent(2, 3); r != '\U0001f469' || len(comb) != 2 || w != 2 {
+		t.Errorf("Bad ZWJ sequence %q %q %d", r, comb, w)
+	}
+	if r, _, _, _ := s.GetContent(4, 3); r != 'z' {
+		t.Errorf("Expected z after the sequences, got %q", r)
+	}
+
+	// normal intensity and no italics undo a bold, italic base
+	s.DrawANSI(0, 4, "a\x1b[22;23mb\x1b[0mc", base.Bold(true).Italic(true))
+	for x, attr := range []AttrMask{AttrBold | AttrItalic, AttrNone, AttrBold | AttrItalic} {
+		if _, _, style, _ := s.GetContent(x, 4); style != base.Attributes(attr) {
+			t.Errorf("%d,4: expected attributes %v, got %+v", x, attr, style)
+		}
+	}
+}
+
+func TestDrawText(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+	w, _ := s.Size()
+
+	style := StyleDefault.Bold(true)
+	// e with an acute accent, a wide character, and a flag
+	if n := s.DrawText(1, 2, style, "e\u0301世\U0001f1ef\U0001f1f5!\a"); n != 6 {
+		t.Errorf("Expected 6 columns, got %d", n)
+	}
+	var values = []struct {
+		x    int
+		r    rune
+		comb []rune
+	}{
+		{1, 'e', []rune{0x301}},
+		{2, '世', nil},
+		{4, 0x1f1ef, []rune{0x1f1f5}},
+		{6, '!', nil},
+		{7, ' ', nil},
+	}
+	for _, v := range values {
+		r, comb, st, _ := s.GetContent(v.x, 2)
+		if r != v.r || len(comb) != len(v.comb) || (r != ' ' && st != style) {
+			t.Errorf("%d: expected %q %q, got %q %q", v.x, v.r, v.comb, r, comb)
+		}
+	}
+	// a flag is two cells wide, even though its first rune alone is not
+	if _, _, _, fw := s.GetContent(4, 2); fw != 2 {
+		t.Errorf("Expected flag to be 2 cells wide, got %d", fw)
+	}
+
+	// a wide character that does not fit is left out
+	if n := s.DrawText(w-3, 0, style, "ab世"); n != 2 {
+		t.Errorf("Expected clipping to 2 columns, got %d", n)
+	}
+	if r, _, _, _ := s.GetContent(w-1, 0); r != ' ' {
+		t.Errorf("Clipped character was drawn: %q", r)
+	}
+}
+
+// screenRows returns the runes in the top left corner of the screen, one
+// string per row.
+func screenRows(s Screen, w, h int) []string {
+	rows := make([]string, h)
+	for y := range rows {
+		for x := 0; x < w; x++ {
+			r, _, _, _ := s.GetContent(x, y)
+			rows[y] += string(r)
+		}
+	}
+	return rows
+}
+
+func TestDrawBox(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	var values = []struct {
+		w, h   int
+		border BoxStyle
+		rows   []string
+	}{
+		{4, 3, BoxRounded, []string{".....", ".╭──╮", ".│..│", ".╰──╯", "....."}},
+		{2, 2, BoxDouble, []string{"....", ".╔╗.", ".╚╝.", "...."}},
+		{3, 1, BoxThick, []string{".....", ".━━━.", "....."}},
+		{1, 2, BoxSingle, []string{"...", ".│.", ".│.", "..."}},
+		{0, 3, BoxSingle, []string{"...", "...", "..."}},
+	}
+	for _, v := range values {
+		s.Fill('.', StyleDefault)
+		s.DrawBox(1, 1, v.w, v.h, StyleDefault, v.border)
+		rows := screenRows(s, len([]rune(v.rows[0])), len(v.rows))
+		for i := range rows {
+			if rows[i] != v.rows[i] {
+				t.Errorf("%dx%d box row %d: expected %q, got %q", v.w, v.h, i, v.rows[i], rows[i])
+			}
+		}
+	}
+}
+
+func TestDrawLines(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	s.Fill('.', StyleDefault)
+	s.DrawBox(0, 0, 5, 5, StyleDefault, BoxSingle)
+	s.DrawHLine(1, 2, 3, StyleDefault, BoxSingle)
+	s.DrawVLine(2, 1, 3, StyleDefault, BoxSingle)
+	s.SetCrossing(2, 2, StyleDefault, BoxSingle)
+	s.SetCrossing(0, 2, StyleDefault, BoxSingle)
+	s.SetCrossing(2, 0, StyleDefault, BoxSingle)
+	s.SetCrossing(2, 4, StyleDefault, BoxSingle)
+	s.SetCrossing(4, 2, StyleDefault, BoxSingle)
+	expect := []string{
+		"┌─┬─┐",
+		"│.│.│",
+		"├─┼─┤",
+		"│.│.│",
+		"└─┴─┘",
+	}
+	rows := screenRows(s, 5, 5)
+	for i := range rows {
+		if rows[i] != expect[i] {
+			t.Errorf("Row %d: expected %q, got %q", i, expect[i], rows[i])
+		}
+	}
+
+	// a line ending at a double box
+	s.Fill('.', StyleDefault)
+	s.DrawBox(0, 0, 3, 3, StyleDefault, BoxDouble)
+	s.DrawHLine(3, 1, 2, StyleDefault, BoxDouble)
+	s.SetCrossing(2, 1, StyleDefault, BoxDouble)
+	if rows = screenRows(s, 5, 3); rows[1] != "║.╠══" {
+		t.Errorf("Expected a tee, got %q", rows[1])
+	}
+}
+
+func TestSoftCursor(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+
+	style := StyleDefault.Foreground(ColorRed)
+	s.SetContent(2, 1, 'a', nil, style)
+	s.SetSoftCursor(2, 1, StyleDefault, 0)
+	s.Show()
+
+	cells, w, _ := s.GetContents()
+	if c := cells[w+2]; string(c.Runes) != "a" || c.Style != style.Reverse(true) {
+		t.Errorf("Cursor not shown reversed: %q %v", c.Runes, c.Style)
+	}
+	if _, _, st, _ := s.GetContent(2, 1); st != style {
+		t.Errorf("Cell contents changed by cursor: %v", st)
+	}
+	if _, _, vis := s.GetCursor(); vis {
+		t.Errorf("Hardware cursor should be hidden")
+	}
+
+	s.SetSoftCursor(3, 1, style, '_')
+	s.Show()
+	cells, _, _ = s.GetContents()
+	if c := cells[w+2]; string(c.Runes) != "a" || c.Style != style {
+		t.Errorf("Cell not restored: %q %v", c.Runes, c.Style)
+	}
+	if c := cells[w+3]; string(c.Runes) != "_" || c.Style != style {
+		t.Errorf("Cursor not drawn: %q %v", c.Runes, c.Style)
+	}
+
+	s.ShowCursor(0, 0)
+	s.Show()
+	cells, _, _ = s.GetContents()
+	if c := cells[w+3]; string(c.Runes) != " " {
+		t.Errorf("Soft cursor not removed: %q", c.Runes)
+	}
+	if x, y, vis := s.GetCursor(); !vis || x != 0 || y != 0 {
+		t.Errorf("Hardware cursor not shown")
+	}
+}
+
+func TestScrollback(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+	w, h := s.Size()
+
+	scroll := func(text string) {
+		s.CopyRegion(0, 1, 0, 0, w, h-1)
+		s.FillRegion(0, h-1, w, 1, ' ', StyleDefault)
+		s.DrawText(0, h-1, StyleDefault, text)
+	}
+
+	// disabled by default
+	s.DrawText(0, 0, StyleDefault, "first")
+	scroll("x")
+	if lines := s.Scrollback(0); len(lines) != 0 {
+		t.Errorf("Expected no scrollback, got %d lines", len(lines))
+	}
+
+	s.SetScrollbackSize(3)
+	for _, text := range []string{"a", "b", "c", "d", "e"} {
+		s.DrawText(0, 0, StyleDefault.Bold(true), text)
+		scroll(text)
+	}
+	lines := s.Scrollback(0)
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %d", len(lines))
+	}
+	for i, text := range []string{"c", "d", "e"} {
+		if len(lines[i]) != w || string(lines[i][0].Runes) != text {
+			t.Errorf("Line %d: expected %q, got %q", i, text, lines[i][0].Runes)
+		}
+		if lines[i][0].Style != StyleDefault.Bold(true) {
+			t.Errorf("Line %d: style not kept", i)
+		}
+	}
+	if lines = s.Scrollback(1); len(lines) != 1 || string(lines[0][0].Runes) != "e" {
+		t.Errorf("Expected only the last line")
+	}
+
+	// shrinking keeps the most recent lines
+	s.SetScrollbackSize(2)
+	if lines = s.Scrollback(5); len(lines) != 2 || string(lines[0][0].Runes) != "d" {
+		t.Errorf("Expected last 2 lines after shrinking, got %d", len(lines))
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+	s.Show()
+
+	front := func(x int) rune {
+		cells, _, _ := s.GetContents()
+		return cells[x].Runes[0]
+	}
+	s.Transaction(func() {
+		s.SetContent(0, 0, 'a', nil, StyleDefault)
+		s.Show()
+		if r := front(0); r == 'a' {
+			t.Errorf("Show should do nothing during a transaction")
+		}
+		s.Transaction(func() {
+			s.SetContent(1, 0, 'b', nil, StyleDefault)
+			s.Sync()
+		})
+		if r := front(1); r == 'b' {
+			t.Errorf("Nested transaction should not show the screen")
+		}
+	})
+	if front(0) != 'a' || front(1) != 'b' {
+		t.Errorf("Screen not shown after the transaction")
+	}
+
+	// showing works as usual afterwards
+	s.SetContent(2, 0, 'c', nil, StyleDefault)
+	s.Show()
+	if front(2) != 'c' {
+		t.Errorf("Show does not work after a transaction")
+	}
+}
+
+func TestSyncRegion(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+	s.SetContent(1, 1, 'a', nil, StyleDefault)
+	s.SetContent(5, 1, 'b', nil, StyleDefault)
+	s.Show()
+
+	// corrupt what is shown, as another program writing might
+	cells, w, _ := s.GetContents()
+	cells[w+1].Runes = []rune{'x'}
+	cells[w+5].Runes = []rune{'x'}
+
+	// changes outside the region are left for the next Show
+	s.SetContent(6, 1, 'c', nil, StyleDefault)
+	s.SyncRegion(0, 0, 3, 3)
+	cells, _, _ = s.GetContents()
+	if r := cells[w+1].Runes[0]; r != 'a' {
+		t.Errorf("Region not redrawn: %q", r)
+	}
+	if r := cells[w+5].Runes[0]; r != 'x' {
+		t.Errorf("Cell outside region redrawn: %q", r)
+	}
+	if r := cells[w+6].Runes[0]; r != ' ' {
+		t.Errorf("Change outside region drawn: %q", r)
+	}
+	s.Show()
+	cells, _, _ = s.GetContents()
+	if r := cells[w+6].Runes[0]; r != 'c' {
+		t.Errorf("Change outside region lost: %q", r)
+	}
+}
+
+func TestWideContent(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+	bold := StyleDefault.Bold(true)
+
+	s.SetContent(2, 0, '世', nil, bold)
+	if r, comb, st, w := s.GetContent(2, 0); r != '世' || comb != nil || st != bold || w != 2 {
+		t.Errorf("Bad wide cell: %q %v %v %d", r, comb, st, w)
+	}
+	if r, comb, st, w := s.GetContent(3, 0); r != 0 || comb != nil || st != bold || w != 0 {
+		t.Errorf("Bad right half: %q %v %v %d", r, comb, st, w)
+	}
+
+	// giving back what GetContent returned changes nothing
+	s.SetContent(3, 0, 0, nil, StyleDefault)
+	if r, _, _, w := s.GetContent(2, 0); r != '世' || w != 2 {
+		t.Errorf("Wide character changed: %q %d", r, w)
+	}
+
+	// overwriting the right half leaves a space on the left
+	s.SetContent(3, 0, 'a', nil, StyleDefault)
+	if r, _, st, w := s.GetContent(2, 0); r != ' ' || st != bold || w != 1 {
+		t.Errorf("Expected a space, got %q %v %d", r, st, w)
+	}
+
+	// overwriting the left half leaves a space on the right
+	s.SetContent(5, 0, '界', nil, bold)
+	s.SetContent(5, 0, 'b', nil, StyleDefault)
+	if r, _, st, w := s.GetContent(6, 0); r != ' ' || st != bold || w != 1 {
+		t.Errorf("Expected a space, got %q %v %d", r, st, w)
+	}
+
+	// a wide character over the right half of another
+	s.SetContent(8, 0, '世', nil, StyleDefault)
+	s.SetContent(9, 0, '界', nil, StyleDefault)
+	if r, _, _, _ := s.GetContent(8, 0); r != ' ' {
+		t.Errorf("Expected a space, got %q", r)
+	}
+	if r, _, _, w := s.GetContent(10, 0); r != 0 || w != 0 {
+		t.Errorf("Expected right half, got %q %d", r, w)
+	}
+
+	// no right half past the edge
+	w, _ := s.Size()
+	s.SetContent(w-1, 1, '世', nil, StyleDefault)
+	if r, _, _, _ := s.GetContent(0, 2); r != ' ' {
+		t.Errorf("Wide character spilled onto the next row")
+	}
+}
+
+func TestDimRendering(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+	s.SetSize(2, 1)
+	s.Show()
+
+	// bold and dim together are both sent, and the terminal decides
+	s.SetContent(0, 0, 'd', nil, StyleDefault.Dim(true).Bold(true))
+	if _, _, st, _ := s.GetContent(0, 0); st.attrs&(AttrDim|AttrBold) != AttrDim|AttrBold {
+		t.Errorf("Dim not kept in the cell: %v", st)
+	}
+	out := string(s.RenderANSI())
+	if !strings.Contains(out, "\x1b[1;2md") {
+		t.Errorf("Expected bold and dim, got %q", out)
+	}
+}
+
+func TestInvisible(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+	s.SetSize(2, 1)
+	s.Show()
+
+	s.SetContent(0, 0, 'p', nil, StyleDefault.Invisible(true))
+	if r, _, st, _ := s.GetContent(0, 0); r != 'p' || st.attrs&AttrInvisible == 0 {
+		t.Errorf("Invisible content not kept: %q %v", r, st)
+	}
+	if out := string(s.RenderANSI()); !strings.Contains(out, "\x1b[8mp") {
+		t.Errorf("Expected concealed text, got %q", out)
+	}
+
+	rs, _ := ParseANSI("\x1b[8ma\x1b[28mb")
+	if len(rs) != 2 || rs[0].Style != StyleDefault.Invisible(true) || rs[1].Style != StyleDefault.Invisible(false) {
+		t.Errorf("Bad parse of SGR 8 and 28: %+v", rs)
+	}
+}
+
+func TestColorOnlyChange(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+	s.SetSize(4, 1)
+
+	bold := StyleDefault.Bold(true)
+	s.SetContent(0, 0, 'a', nil, bold.Foreground(ColorRed))
+	s.SetContent(1, 0, 'b', nil, bold)
+	s.SetContent(2, 0, 'c', nil, bold.Background(ColorBlue))
+	s.SetContent(3, 0, 'd', nil, bold.Foreground(ColorReset).Background(ColorReset))
+
+	expect := "\x1b(B\x1b[m\x1b[1;91ma\x1b[39mb\x1b[104mc" +
+		"\x1b[49md\x1b(B\x1b[m"
+	if out := string(s.RenderANSI()); out != expect {
+		t.Errorf("Bad rendering %q", out)
+	}
+}
+
+func TestSetColumns(t *testing.T) {
+	s := mkTestScreen(t, "")
+	defer s.Fini()
+	s.SetSize(80, 24)
+	for s.HasPendingEvent() {
+		s.PollEvent()
+	}
+
+	if err := s.SetColumns(132); err != nil {
+		t.Fatalf("Failed to change columns: %v", err)
+	}
+	if w, h := s.Size(); w != 132 || h != 24 {
+		t.Errorf("Expected 132x24, got %dx%d", w, h)
+	}
+	if ev, ok := s.PollEvent().(*EventResize); !ok {
+		t.Errorf("Expected resize event")
+	} else if w, _ := ev.Size(); w != 132 {
+		t.Errorf("Resized to %d columns", w)
+	}
+	if err := s.SetColumns(100); err != ErrUnsupported {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
@@ -15,10 +15,16 @@
 package tcell
 
 import (
+	"bytes"
+	"context"
+	"image"
 	"sync"
+	"time"
 	"unicode/utf8"
 
 	"golang.org/x/text/transform"
+
+	"github.com/gdamore/tcell/v2/terminfo"
 )
 
 // NewSimulationScreen returns a SimulationScreen.  Note that
@@ -36,21 +42,26 @@ func NewSimulationScreen(charset string) SimulationScreen {
 // for testing.
 type SimulationScreen interface {
 	// InjectKeyBytes injects a stream of bytes corresponding to
-	// the native encoding (see charset).  It turns true if the entire
-	// set of bytes were processed and delivered as KeyEvents, false
-	// if any bytes were not fully understood.  Any bytes that are not
-	// fully converted are discarded.
+	// the native encoding (see charset), as if they had been read from
+	// an XTerm.  They are parsed just as a terminal screen would parse
+	// them, so escape sequences for special keys, mouse events, and so
+	// forth are understood.  It returns true if the entire set of bytes
+	// were processed and delivered as events, false if any bytes could
+	// not be decoded.  Any bytes that are not fully converted are
+	// discarded.
 	InjectKeyBytes(buf []byte) bool
 
 	// InjectKey injects a key event.  The rune is a UTF-8 rune, post
 	// any translation.
 	InjectKey(key Key, r rune, mod ModMask)
 
-	// InjectMouse injects a mouse event.
+	// InjectMouse injects a mouse event.  An event at a new position,
+	// with the same buttons held as the one before it, is dragging.
 	InjectMouse(x, y int, buttons ButtonMask, mod ModMask)
 
-	// SetSize resizes the underlying physical screen.  It also causes
-	// a resize event to be injected during the next Show() or Sync().
+	// SetSize resizes the underlying physical screen, as if the user
+	// had resized their terminal window.  If the size changed, an
+	// EventResize is posted, just as for a real terminal.
 	// A new physical contents array will be allocated (with data from
 	// the old copied), so any prior value obtained with GetContents
 	// won't be used anymore
@@ -65,6 +76,26 @@ type SimulationScreen interface {
 	// GetCursor returns the cursor details.
 	GetCursor() (x int, y int, visible bool)
 
+	// RenderANSI returns the escape sequences and text that a terminal
+	// screen would send to an XTerm with direct color support, to display
+	// the current contents (as they will be after Show).  Rows are
+	// separated by CR LF, rather than by positioning the cursor.  This
+	// is useful for comparing with "golden" files in tests.
+	RenderANSI() []byte
+
+	// SetScrollbackSize sets how many lines the scrollback buffer keeps.
+	// Rows scroll off the screen when CopyRegion moves whole rows up to
+	// the top of the screen, and the rows that are overwritten are kept
+	// in the buffer, so that tests can see what was shown before.  The
+	// default is zero, which disables the buffer.
+	SetScrollbackSize(n int)
+
+	// Scrollback returns up to n of the lines that most recently scrolled
+	// off the screen, oldest first.  If n is zero or less, all the lines
+	// kept are returned.  The cells have their runes and style, but not
+	// Bytes.
+	Scrollback(n int) [][]SimCell
+
 	Screen
 }
 
@@ -90,26 +121,45 @@ type simscreen struct {
 	evch  chan Event
 	quit  chan struct{}
 
-	front     []SimCell
-	back      CellBuffer
-	clear     bool
-	cursorx   int
-	cursory   int
-	cursorvis bool
-	mouse     bool
-	paste     bool
-	charset   string
-	encoder   transform.Transformer
-	decoder   transform.Transformer
-	fillchar  rune
-	fillstyle Style
-	fallback  map[rune]string
+	front      []SimCell
+	back       CellBuffer
+	clear      bool
+	cursorx    int
+	cursory    int
+	cursorvis  bool
+	cursorsty  CursorStyle
+	softCursor softCursor
+	batch      batch
+	scrollback [][]SimCell // ring buffer of lines scrolled off
+	sbHead     int         // index of the oldest line in scrollback
+	sbLen      int         // number of lines in scrollback
+	mouse      bool
+	paste      bool
+	focus      bool
+	charset    string
+	encoder    transform.Transformer
+	decoder    transform.Transformer
+	fillchar   rune
+	fillstyle  Style
+	fallback   map[rune]string
+	clipboard  map[string][]byte
+	title      string
+	icontitle  string
+	ticker     ticker
+	parser     *tScreen
+	opts       ScreenOptions
+	buttons    ButtonMask
+	scroll     scrollAccum
+	compose    composer
+	pasted     pasteCollector
+	mousex     int
+	mousey     int
 
 	sync.Mutex
 }
 
 func (s *simscreen) Init() error {
-	s.evch = make(chan Event, 10)
+	s.evch = make(chan Event, s.opts.eventQueueSize())
 	s.quit = make(chan struct{})
 	s.fillchar = 'X'
 	s.fillstyle = StyleDefault
@@ -130,6 +180,8 @@ func (s *simscreen) Init() error {
 	s.front = make([]SimCell, s.physw*s.physh)
 	s.back.Resize(80, 25)
 
+	s.clipboard = make(map[string][]byte)
+
 	// default fallbacks
 	s.fallback = make(map[rune]string)
 	for k, v := range RuneFallbacks {
@@ -142,6 +194,7 @@ func (s *simscreen) Fini() {
 	s.Lock()
 	s.fini = true
 	s.back.Resize(0, 0)
+	s.ticker.stop()
 	s.Unlock()
 	if s.quit != nil {
 		close(s.quit)
@@ -167,6 +220,85 @@ func (s *simscreen) Fill(r rune, style Style) {
 	s.Unlock()
 }
 
+func (s *simscreen) CopyRegion(srcX, srcY, dstX, dstY, w, h int) {
+	s.Lock()
+	bw, bh := s.back.Size()
+	if srcX == 0 && dstX == 0 && w >= bw && dstY == 0 && srcY > 0 && h > 0 {
+		// scrolling up, so the rows at the top are lost
+		for y := 0; y < srcY && y < h && y < bh; y++ {
+			s.saveScrollback(y)
+		}
+	}
+	s.back.CopyRegion(srcX, srcY, dstX, dstY, w, h)
+	s.Unlock()
+}
+
+// saveScrollback adds row y of the screen contents to the scrollback
+// buffer, dropping the oldest line if the buffer is full.
+func (s *simscreen) saveScrollback(y int) {
+	size := len(s.scrollback)
+	if size == 0 {
+		return
+	}
+	w, _ := s.back.Size()
+	line := make([]SimCell, w)
+	for x := range line {
+		mainc, combc, style, _ := s.back.getContent(x, y)
+		if style == StyleDefault {
+			style = s.style
+		}
+		line[x].Runes = append([]rune{mainc}, combc...)
+		line[x].Style = style
+	}
+	if s.sbLen < size {
+		s.scrollback[(s.sbHead+s.sbLen)%size] = line
+		s.sbLen++
+	} else {
+		s.scrollback[s.sbHead] = line
+		s.sbHead = (s.sbHead + 1) % size
+	}
+}
+
+func (s *simscreen) SetScrollbackSize(n int) {
+	if n < 0 {
+		n = 0
+	}
+	s.Lock()
+	lines := s.lastScrollback(n)
+	s.scrollback = make([][]SimCell, n)
+	copy(s.scrollback, lines)
+	s.sbHead, s.sbLen = 0, len(lines)
+	s.Unlock()
+}
+
+func (s *simscreen) Scrollback(n int) [][]SimCell {
+	s.Lock()
+	defer s.Unlock()
+	if n <= 0 {
+		n = s.sbLen
+	}
+	return s.lastScrollback(n)
+}
+
+// lastScrollback returns up to n of the most recent lines in the
+// scrollback buffer, oldest first.
+func (s *simscreen) lastScrollback(n int) [][]SimCell {
+	if n > s.sbLen {
+		n = s.sbLen
+	}
+	lines := make([][]SimCell, 0, n)
+	for i := s.sbLen - n; i < s.sbLen; i++ {
+		lines = append(lines, s.scrollback[(s.sbHead+i)%len(s.scrollback)])
+	}
+	return lines
+}
+
+func (s *simscreen) FillRegion(x, y, w, h int, r rune, style Style) {
+	s.Lock()
+	s.back.FillRegion(x, y, w, h, r, style)
+	s.Unlock()
+}
+
 func (s *simscreen) SetCell(x, y int, style Style, ch ...rune) {
 
 	if len(ch) > 0 {
@@ -183,6 +315,14 @@ func (s *simscreen) SetContent(x, y int, mainc rune, combc []rune, st Style) {
 	s.Unlock()
 }
 
+func (s *simscreen) SetCells(cells []CellUpdate) {
+	s.Lock()
+	for _, c := range cells {
+		s.back.SetContent(c.X, c.Y, c.R, c.Combining, c.Style)
+	}
+	s.Unlock()
+}
+
 func (s *simscreen) GetContent(x, y int) (rune, []rune, Style, int) {
 	var mainc rune
 	var combc []rune
@@ -196,7 +336,7 @@ func (s *simscreen) GetContent(x, y int) (rune, []rune, Style, int) {
 
 func (s *simscreen) drawCell(x, y int) int {
 
-	mainc, combc, style, width := s.back.GetContent(x, y)
+	mainc, combc, style, width := s.back.getContent(x, y)
 	if !s.back.Dirty(x, y) {
 		return width
 	}
@@ -258,6 +398,7 @@ func (s *simscreen) drawCell(x, y int) int {
 func (s *simscreen) ShowCursor(x, y int) {
 	s.Lock()
 	s.cursorx, s.cursory = x, y
+	s.softCursor.on = false
 	s.showCursor()
 	s.Unlock()
 }
@@ -266,6 +407,20 @@ func (s *simscreen) HideCursor() {
 	s.ShowCursor(-1, -1)
 }
 
+func (s *simscreen) SetSoftCursor(x, y int, style Style, r rune) {
+	s.Lock()
+	s.cursorx, s.cursory = -1, -1
+	s.showCursor()
+	s.softCursor = softCursor{on: true, x: x, y: y, style: style, r: r}
+	s.Unlock()
+}
+
+func (s *simscreen) SetCursorStyle(cs CursorStyle) {
+	s.Lock()
+	s.cursorsty = cs
+	s.Unlock()
+}
+
 func (s *simscreen) showCursor() {
 
 	x, y := s.cursorx, s.cursory
@@ -283,6 +438,10 @@ func (s *simscreen) hideCursor() {
 
 func (s *simscreen) Show() {
 	s.Lock()
+	if s.batch.hold(false) {
+		s.Unlock()
+		return
+	}
 	s.resize()
 	s.draw()
 	s.Unlock()
@@ -299,14 +458,22 @@ func (s *simscreen) clearScreen() {
 }
 
 func (s *simscreen) draw() {
+	w, h := s.back.Size()
+	s.drawRect(image.Rect(0, 0, w, h))
+}
+
+// drawRect draws the cells within r that have changed, and leaves the
+// rest to be drawn another time.
+func (s *simscreen) drawRect(r image.Rectangle) {
 	s.hideCursor()
 	if s.clear {
 		s.clearScreen()
 	}
 
-	w, h := s.back.Size()
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
+	defer s.softCursor.apply(&s.back)()
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
 			width := s.drawCell(x, y)
 			x += width - 1
 		}
@@ -330,6 +497,14 @@ func (s *simscreen) DisablePaste() {
 	s.paste = false
 }
 
+func (s *simscreen) EnableFocus() {
+	s.focus = true
+}
+
+func (s *simscreen) DisableFocus() {
+	s.focus = false
+}
+
 func (s *simscreen) Size() (int, int) {
 	s.Lock()
 	w, h := s.back.Size()
@@ -360,6 +535,25 @@ func (s *simscreen) PollEvent() Event {
 	}
 }
 
+func (s *simscreen) PollEventContext(ctx context.Context) (Event, error) {
+	select {
+	case <-s.quit:
+		return nil, nil
+	case ev := <-s.evch:
+		return ev, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *simscreen) PollEventFor(d time.Duration) (Event, error) {
+	return pollEventFor(s.evch, s.quit, d)
+}
+
+func (s *simscreen) HasPendingEvent() bool {
+	return len(s.evch) > 0
+}
+
 func (s *simscreen) PostEventWait(ev Event) {
 	s.evch <- ev
 }
@@ -373,66 +567,282 @@ func (s *simscreen) PostEvent(ev Event) error {
 	}
 }
 
+func (s *simscreen) ColorDepth() ColorDepth {
+	return ColorDepth256
+}
+
+// TermInfo reports the features that the simulation emulates.
+func (s *simscreen) TermInfo() TermCapabilities {
+	return TermCapabilities{
+		Colors256:      true,
+		Mouse:          true,
+		BracketedPaste: true,
+		FocusEvents:    true,
+	}
+}
+
+func (s *simscreen) DrawSixel(int, int, image.Image) error {
+	return ErrNoGraphics
+}
+
+func (s *simscreen) DrawImage(int, int, int, int, image.Image, uint32) error {
+	return nil
+}
+
+func (s *simscreen) DeleteImage(uint32) {}
+
+func (s *simscreen) DrawPixelImage(x, y, w, h int, img image.Image, mode PixelMode) {
+	drawPixelImage(s, x, y, w, h, img, mode)
+}
+
+func (s *simscreen) DrawText(x, y int, style Style, text string) int {
+	return drawText(s, x, y, style, text)
+}
+
+func (s *simscreen) DrawBox(x, y, w, h int, style Style, border BoxStyle) {
+	drawBox(s, x, y, w, h, style, border)
+}
+
+func (s *simscreen) DrawHLine(x, y, w int, style Style, border BoxStyle) {
+	drawHLine(s, x, y, w, style, border)
+}
+
+func (s *simscreen) DrawVLine(x, y, h int, style Style, border BoxStyle) {
+	drawVLine(s, x, y, h, style, border)
+}
+
+func (s *simscreen) SetCrossing(x, y int, style Style, border BoxStyle) {
+	setCrossing(s, x, y, style, border)
+}
+
+func (s *simscreen) DrawANSI(x, y int, str string, base Style) (int, error) {
+	return drawANSI(s, x, y, str, base)
+}
+
+// SetResizeDebounce does nothing, as simulated resizes are immediate.
+func (s *simscreen) SetResizeDebounce(time.Duration) {}
+
+// SetEscapeTimeout does nothing, as InjectKeyBytes never waits for
+// more input.
+func (s *simscreen) SetEscapeTimeout(time.Duration) {}
+
+// TermVersion returns nothing, as there is no terminal to ask.
+func (s *simscreen) TermVersion() (string, string) {
+	return "", ""
+}
+
+func (s *simscreen) DebugInfo() string {
+	s.Lock()
+	defer s.Unlock()
+	var d debugInfo
+	d.str("driver", "simulation")
+	d.str("charset", s.charset)
+	d.num("color_depth", int(ColorDepth256))
+	d.num("width", s.physw)
+	d.num("height", s.physh)
+	d.flag("mouse", s.mouse)
+	d.flag("paste", s.paste)
+	d.flag("focus", s.focus)
+	return d.String()
+}
+
+func (s *simscreen) SetComposeMap(m *ComposeMap) {
+	s.Lock()
+	evs := s.compose.setMap(m)
+	s.Unlock()
+	for _, ev := range evs {
+		s.PostEvent(ev)
+	}
+}
+
+func (s *simscreen) SetScrollAccumThreshold(lines int) {
+	s.Lock()
+	s.scroll = scrollAccum{threshold: lines}
+	s.Unlock()
+}
+
+func (s *simscreen) MouseButtonState() ButtonMask {
+	s.Lock()
+	defer s.Unlock()
+	return s.buttons
+}
+
+func (s *simscreen) TParm(string, ...int) error {
+	return ErrNoCapability
+}
+
+// SetMaxFPS does nothing, as there is no terminal to protect, and tests
+// should not be slowed down.
+func (s *simscreen) SetMaxFPS(int) {}
+
+func (s *simscreen) SetTicker(d time.Duration) {
+	s.Lock()
+	s.ticker.d = d
+	if !s.fini {
+		s.ticker.start(s.PostEvent)
+	}
+	s.Unlock()
+}
+
+func (s *simscreen) setOptions(opts ScreenOptions) {
+	s.opts = opts
+}
+
+func (s *simscreen) PostFunc(f func()) error {
+	return s.PostEvent(NewEventFunc(f))
+}
+
 func (s *simscreen) InjectMouse(x, y int, buttons ButtonMask, mod ModMask) {
 	ev := NewEventMouse(x, y, buttons, mod)
-	s.PostEvent(ev)
+	s.Lock()
+	// A move with the same buttons still held is treated as motion.
+	held := buttons & buttonsHeld
+	ev.motion = held != 0 && held == s.buttons && (x != s.mousex || y != s.mousey)
+	s.buttons = held
+	s.mousex, s.mousey = x, y
+	s.Unlock()
+	s.postInput(ev)
 }
 
 func (s *simscreen) InjectKey(key Key, r rune, mod ModMask) {
 	ev := NewEventKey(key, r, mod)
-	s.PostEvent(ev)
+	s.postInput(ev)
 }
 
-func (s *simscreen) InjectKeyBytes(b []byte) bool {
-	failed := false
-
-outer:
-	for len(b) > 0 {
-		if b[0] >= ' ' && b[0] <= 0x7F {
-			// printable ASCII easy to deal with -- no encodings
-			ev := NewEventKey(KeyRune, rune(b[0]), ModNone)
-			s.PostEvent(ev)
-			b = b[1:]
-			continue
+// postInput posts an injected input event, after the same processing
+// that input from a real terminal gets.
+func (s *simscreen) postInput(ev Event) {
+	var evs []Event
+	s.Lock()
+	if ev = s.scroll.filter(ev); ev != nil {
+		// pasted text is not composed
+		if s.pasted.active {
+			evs = []Event{ev}
+		} else {
+			evs = s.compose.filter(ev)
 		}
+		s.pasted.filter(ev)
+	}
+	s.Unlock()
+	for _, ev := range evs {
+		s.PostEvent(ev)
+	}
+}
 
-		if b[0] < 0x80 {
-			mod := ModNone
-			// No encodings start with low numbered values
-			if Key(b[0]) >= KeyCtrlA && Key(b[0]) <= KeyCtrlZ {
-				mod = ModCtrl
-			}
-			ev := NewEventKey(Key(b[0]), 0, mod)
-			s.PostEvent(ev)
-			b = b[1:]
-			continue
+func (s *simscreen) InjectKeyBytes(b []byte) bool {
+	// The parser and the decoder are shared, so the lock is held while
+	// they are used, but not while posting the events.
+	s.Lock()
+	if s.parser == nil {
+		s.parser = newInputParser(s.decoder)
+	}
+	p := s.parser
+	p.cells.Resize(s.physw, s.physh)
+
+	// The parser silently discards anything that cannot be decoded,
+	// so check for that first.
+	s.decoder.Reset()
+	text, _, e := transform.Bytes(s.decoder, b)
+	failed := e != nil || bytes.ContainsRune(text, utf8.RuneError)
+
+	// All the input is here, so there is no point waiting for the rest
+	// of any partial escape sequence.
+	evs := p.collectEventsFromInput(bytes.NewBuffer(b), true)
+	s.Unlock()
+
+	for _, ev := range evs {
+		s.postInput(ev)
+	}
+	return !failed
+}
+
+func (s *simscreen) RenderANSI() []byte {
+	s.Lock()
+	defer s.Unlock()
+
+	r := newRenderer(s.encoder, s.fallback)
+	w, h := s.back.Size()
+	r.w, r.h = w, h
+	r.style = s.style
+	r.cells.Resize(w, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			mainc, combc, style, _ := s.back.getContent(x, y)
+			r.cells.SetContent(x, y, mainc, combc, style)
 		}
+	}
+	r.cells.Invalidate()
 
-		utfb := make([]byte, len(b)*4) // worst case
-		for l := 1; l < len(b); l++ {
-			s.decoder.Reset()
-			nout, nin, _ := s.decoder.Transform(utfb, b[:l], true)
-
-			if nout != 0 {
-				r, _ := utf8.DecodeRune(utfb[:nout])
-				if r != utf8.RuneError {
-					ev := NewEventKey(KeyRune, r, ModNone)
-					s.PostEvent(ev)
-				}
-				b = b[nin:]
-				continue outer
-			}
+	for y := 0; y < h; y++ {
+		if y > 0 {
+			r.writeString("\r\n")
 		}
-		failed = true
-		b = b[1:]
-		continue
+		r.cx, r.cy = 0, y
+		for x := 0; x < w; x++ {
+			width := r.drawCell(x, y)
+			x += width - 1
+		}
+	}
+	if r.curstyle.url != "" {
+		r.sendURL("")
 	}
+	r.TPuts(r.ti.AttrOff)
+	return r.buf.Bytes()
+}
 
-	return !failed
+// newRenderer returns a tScreen that is only used to render the contents
+// of the simulation, as they would be sent to an XTerm.  Everything is
+// fixed, rather than depending on the environment, so that the results
+// are always the same.
+func newRenderer(encoder transform.Transformer, fallback map[rune]string) *tScreen {
+	xt, _ := terminfo.LookupTerminfo("xterm-256color")
+	ti := *xt
+	ti.SetFgRGB = "\x1b[38;2;%p1%d;%p2%d;%p3%dm"
+	ti.SetBgRGB = "\x1b[48;2;%p1%d;%p2%d;%p3%dm"
+	ti.SetFgBgRGB = "\x1b[38;2;%p1%d;%p2%d;%p3%d;48;2;%p4%d;%p5%d;%p6%dm"
+	t := &tScreen{
+		ti:         &ti,
+		encoder:    encoder,
+		asciiSafe:  encodesASCII(encoder),
+		fallback:   fallback,
+		colors:     make(map[Color]Color),
+		fgColors:   make(map[Color]Color),
+		bgColors:   make(map[Color]Color),
+		truecolor:  true,
+		depth:      ColorDepthTrue,
+		hyperlinks: true,
+		styledUl:   true,
+		buffering:  true,
+		curstyle:   styleInvalid,
+	}
+	t.buildAcsMap()
+	t.prepareSGR()
+	return t
+}
+
+// newInputParser returns a tScreen that is only used to parse input, so
+// that injected bytes are understood exactly as a real terminal screen
+// would understand them.  The XTerm encodings are used.
+func newInputParser(decoder transform.Transformer) *tScreen {
+	ti, _ := terminfo.LookupTerminfo("xterm")
+	t := &tScreen{
+		ti:        ti,
+		mouse:     []byte(ti.Mouse),
+		keyexist:  make(map[Key]bool),
+		keycodes:  make(map[string]*tKeyCode),
+		decoder:   decoder,
+		buffering: true,
+	}
+	t.prepareKeys()
+	return t
 }
 
 func (s *simscreen) Sync() {
 	s.Lock()
+	if s.batch.hold(true) {
+		s.Unlock()
+		return
+	}
 	s.clear = true
 	s.resize()
 	s.back.Invalidate()
@@ -440,6 +850,40 @@ func (s *simscreen) Sync() {
 	s.Unlock()
 }
 
+func (s *simscreen) SyncRegion(x, y, w, h int) {
+	s.Lock()
+	defer s.Unlock()
+	s.back.InvalidateRegion(x, y, w, h)
+	if s.batch.hold(false) {
+		return
+	}
+	s.resize()
+	if s.clear {
+		// the whole screen has to be drawn anyway
+		s.draw()
+		return
+	}
+	// include the left half of a wide character, as InvalidateRegion does
+	bw, bh := s.back.Size()
+	s.drawRect(image.Rect(x-1, y, x+w, y+h).Intersect(image.Rect(0, 0, bw, bh)))
+}
+
+func (s *simscreen) Transaction(f func()) {
+	transaction(s, &s.batch, f, s.Show, s.Sync)
+}
+
+// HardReset just redraws everything, as there is no terminal to reset.
+func (s *simscreen) HardReset() error {
+	s.Sync()
+	return nil
+}
+
+// SoftReset just redraws everything, as there is no terminal to reset.
+func (s *simscreen) SoftReset() error {
+	s.Sync()
+	return nil
+}
+
 func (s *simscreen) CharacterSet() string {
 	return s.charset
 }
@@ -455,7 +899,7 @@ func (s *simscreen) SetSize(w, h int) {
 	s.cursorx, s.cursory = -1, -1
 	s.physw, s.physh = w, h
 	s.front = newc
-	s.back.Resize(w, h)
+	s.resize()
 	s.Unlock()
 }
 
@@ -521,10 +965,89 @@ func (s *simscreen) Beep() error {
 	return nil
 }
 
+// SetBellMode does nothing, as there is no bell to ring.
+func (s *simscreen) SetBellMode(BellMode) {}
+
+// SetVisualBellDuration does nothing, as there is no bell to ring.
+func (s *simscreen) SetVisualBellDuration(time.Duration) {}
+
+// Flash does nothing, as the simulation does not emulate reverse video.
+func (s *simscreen) Flash(time.Duration) {}
+
+// SetTermDriver fails, as the simulation has no TermDriver.
+func (s *simscreen) SetTermDriver(TermDriver) error {
+	return ErrNoScreen
+}
+
 func (s *simscreen) Suspend() error {
 	return nil
 }
 
 func (s *simscreen) Resume() error {
 	return nil
-}
\ No newline at end of file
+}
+
+func (s *simscreen) SetClipboard(sel string, data []byte) error {
+	if !validSelection(sel) {
+		return ErrBadSelection
+	}
+	s.Lock()
+	s.clipboard[sel] = append([]byte{}, data...)
+	s.Unlock()
+	return nil
+}
+
+func (s *simscreen) GetClipboard(sel string) ([]byte, error) {
+	if !validSelection(sel) {
+		return nil, ErrBadSelection
+	}
+	s.Lock()
+	data := append([]byte{}, s.clipboard[sel]...)
+	s.Unlock()
+	return data, nil
+}
+
+// QueryForegroundColor fails, as the simulation has no real colors.
+func (s *simscreen) QueryForegroundColor() (Color, error) {
+	return ColorDefault, ErrNoCapability
+}
+
+// ForegroundColor returns ColorDefault.
+func (s *simscreen) ForegroundColor() Color {
+	return ColorDefault
+}
+
+// QueryBackgroundColor fails, as the simulation has no real colors.
+func (s *simscreen) QueryBackgroundColor() (Color, error) {
+	return ColorDefault, ErrNoCapability
+}
+
+// BackgroundColor returns ColorDefault.
+func (s *simscreen) BackgroundColor() Color {
+	return ColorDefault
+}
+
+func (s *simscreen) SetTitle(title string) {
+	s.Lock()
+	s.title = title
+	s.Unlock()
+}
+
+func (s *simscreen) SetIconTitle(title string) {
+	s.Lock()
+	s.icontitle = title
+	s.Unlock()
+}
+
+// SetColumns changes the width of the simulated terminal, which honors
+// DECCOLM.
+func (s *simscreen) SetColumns(n int) error {
+	if _, err := deccolm(n); err != nil {
+		return err
+	}
+	s.Lock()
+	h := s.physh
+	s.Unlock()
+	s.SetSize(n, h)
+	return nil
+}
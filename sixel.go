@@ -0,0 +1,167 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+)
+
+// sixelPalette returns a palette of at most n colors, evenly spread over
+// the RGB color cube.  With too few colors for the 8 corners of the cube,
+// shades of grey are used instead, as on the VT330.
+func sixelPalette(n int) color.Palette {
+	if n < 8 {
+		return greyPalette(n)
+	}
+	levels := 2
+	for (levels+1)*(levels+1)*(levels+1) <= n {
+		levels++
+	}
+	pal := make(color.Palette, 0, levels*levels*levels)
+	for r := 0; r < levels; r++ {
+		for g := 0; g < levels; g++ {
+			for b := 0; b < levels; b++ {
+				pal = append(pal, color.RGBA{
+					R: uint8(r * 255 / (levels - 1)),
+					G: uint8(g * 255 / (levels - 1)),
+					B: uint8(b * 255 / (levels - 1)),
+					A: 0xff,
+				})
+			}
+		}
+	}
+	return pal
+}
+
+// greyPalette returns n shades of grey, from black to white, or just
+// white if n is less than 2.
+func greyPalette(n int) color.Palette {
+	if n < 2 {
+		return color.Palette{color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}}
+	}
+	pal := make(color.Palette, n)
+	for i := range pal {
+		v := uint8(i * 255 / (n - 1))
+		pal[i] = color.RGBA{R: v, G: v, B: v, A: 0xff}
+	}
+	return pal
+}
+
+// encodeSixel converts an image to a Sixel stream, including the
+// introducing DCS and the terminating ST, using at most ncolors color
+// registers.  Mostly transparent pixels are left as they are on the screen.
+func encodeSixel(img image.Image, ncolors int) []byte {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	pal := sixelPalette(ncolors)
+	pimg := image.NewPaletted(bounds, pal)
+	draw.FloydSteinberg.Draw(pimg, bounds, img, bounds.Min)
+
+	// Work out the color register of each pixel, or -1 if transparent.
+	idx := make([]int, w*h)
+	used := make([]bool, len(pal))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			px, py := bounds.Min.X+x, bounds.Min.Y+y
+			if _, _, _, a := img.At(px, py).RGBA(); a < 0x8000 {
+				idx[y*w+x] = -1
+				continue
+			}
+			ci := int(pimg.ColorIndexAt(px, py))
+			idx[y*w+x] = ci
+			used[ci] = true
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	// P2 = 1 leaves unset pixels alone, the raster attributes give a
+	// square aspect ratio and the image size.
+	buf.WriteString("\x1bP0;1q\"1;1;" + strconv.Itoa(w) + ";" + strconv.Itoa(h))
+	for ci, c := range pal {
+		if !used[ci] {
+			continue
+		}
+		r, g, b, _ := c.RGBA()
+		buf.WriteString("#" + strconv.Itoa(ci) + ";2;" +
+			strconv.Itoa(int(r*100/0xffff)) + ";" +
+			strconv.Itoa(int(g*100/0xffff)) + ";" +
+			strconv.Itoa(int(b*100/0xffff)))
+	}
+
+	row := make([]byte, w)
+	for y0 := 0; y0 < h; y0 += 6 {
+		first := true
+		for ci := range pal {
+			if !used[ci] {
+				continue
+			}
+			present := false
+			for x := 0; x < w; x++ {
+				var bits byte
+				for dy := 0; dy < 6 && y0+dy < h; dy++ {
+					if idx[(y0+dy)*w+x] == ci {
+						bits |= 1 << uint(dy)
+					}
+				}
+				row[x] = bits
+				if bits != 0 {
+					present = true
+				}
+			}
+			if !present {
+				continue
+			}
+			if !first {
+				// carriage return, to overlay the next color
+				buf.WriteByte('$')
+			}
+			first = false
+			buf.WriteString("#" + strconv.Itoa(ci))
+			writeSixelRow(buf, row)
+		}
+		buf.WriteByte('-')
+	}
+	buf.WriteString("\x1b\\")
+	return buf.Bytes()
+}
+
+// writeSixelRow writes one color's worth of a six pixel high band,
+// using run length encoding, and omitting any trailing empty sixels.
+func writeSixelRow(buf *bytes.Buffer, row []byte) {
+	end := len(row)
+	for end > 0 && row[end-1] == 0 {
+		end--
+	}
+	for x := 0; x < end; {
+		n := 1
+		for x+n < end && row[x+n] == row[x] {
+			n++
+		}
+		ch := row[x] + '?'
+		if n > 3 {
+			buf.WriteString("!" + strconv.Itoa(n))
+			buf.WriteByte(ch)
+		} else {
+			for i := 0; i < n; i++ {
+				buf.WriteByte(ch)
+			}
+		}
+		x += n
+	}
+}
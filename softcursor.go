@@ -0,0 +1,48 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+// softCursor is a cursor that a screen draws itself, as the contents of
+// a cell, rather than having the terminal show its own cursor.
+type softCursor struct {
+	on    bool
+	x, y  int
+	style Style
+	r     rune
+}
+
+// apply puts the cursor in the cells, so that it is drawn along with
+// them, and returns a function that puts back what was there.  The cell
+// is then dirty, as what was drawn is not what it holds, so moving the
+// cursor away redraws the cell properly.
+func (sc *softCursor) apply(cb *CellBuffer) func() {
+	w, h := cb.Size()
+	if !sc.on || sc.x < 0 || sc.y < 0 || sc.x >= w || sc.y >= h {
+		return func() {}
+	}
+	mainc, combc, style, _ := cb.GetContent(sc.x, sc.y)
+
+	r, comb, cstyle := sc.r, []rune(nil), sc.style
+	if r == 0 {
+		r, comb = mainc, combc
+	}
+	if cstyle == StyleDefault {
+		cstyle = style.Reverse(style.attrs&AttrReverse == 0)
+	}
+	cb.SetContent(sc.x, sc.y, r, comb, cstyle)
+	return func() {
+		cb.SetContent(sc.x, sc.y, mainc, combc, style)
+	}
+}
@@ -23,11 +23,23 @@ package tcell
 //
 // To use Style, just declare a variable of its type.
 type Style struct {
-	fg    Color
-	bg    Color
-	attrs AttrMask
+	fg      Color
+	bg      Color
+	attrs   AttrMask
+	url     string
+	ulStyle int
+	ulColor Color
+	off     AttrMask // attributes turned off explicitly, for Merge
 }
 
+// Underline styles, numbered to match the sub-parameter of SGR 4.
+const (
+	ulSolid  = 0
+	ulCurly  = 3
+	ulDotted = 4
+	ulDashed = 5
+)
+
 // StyleDefault represents a default style, based upon the context.
 // It is the zero value.
 var StyleDefault Style
@@ -39,9 +51,13 @@ var styleInvalid = Style{attrs: AttrInvalid}
 // as requested.  ColorDefault can be used to select the global default.
 func (s Style) Foreground(c Color) Style {
 	return Style{
-		fg:    c,
-		bg:    s.bg,
-		attrs: s.attrs,
+		fg:      c,
+		bg:      s.bg,
+		attrs:   s.attrs,
+		url:     s.url,
+		ulStyle: s.ulStyle,
+		ulColor: s.ulColor,
+		off:     s.off,
 	}
 }
 
@@ -49,9 +65,13 @@ func (s Style) Foreground(c Color) Style {
 // as requested.  ColorDefault can be used to select the global default.
 func (s Style) Background(c Color) Style {
 	return Style{
-		fg:    s.fg,
-		bg:    c,
-		attrs: s.attrs,
+		fg:      s.fg,
+		bg:      c,
+		attrs:   s.attrs,
+		url:     s.url,
+		ulStyle: s.ulStyle,
+		ulColor: s.ulColor,
+		off:     s.off,
 	}
 }
 
@@ -64,23 +84,33 @@ func (s Style) Decompose() (fg Color, bg Color, attr AttrMask) {
 func (s Style) setAttrs(attrs AttrMask, on bool) Style {
 	if on {
 		return Style{
-			fg:    s.fg,
-			bg:    s.bg,
-			attrs: s.attrs | attrs,
+			fg:      s.fg,
+			bg:      s.bg,
+			attrs:   s.attrs | attrs,
+			url:     s.url,
+			ulStyle: s.ulStyle,
+			ulColor: s.ulColor,
+			off:     s.off &^ attrs,
 		}
 	}
 	return Style{
-		fg:    s.fg,
-		bg:    s.bg,
-		attrs: s.attrs &^ attrs,
+		fg:      s.fg,
+		bg:      s.bg,
+		attrs:   s.attrs &^ attrs,
+		url:     s.url,
+		ulStyle: s.ulStyle,
+		ulColor: s.ulColor,
+		off:     s.off | attrs,
 	}
 }
 
 // Normal returns the style with all attributes disabled.
 func (s Style) Normal() Style {
 	return Style{
-		fg: s.fg,
-		bg: s.bg,
+		fg:      s.fg,
+		bg:      s.bg,
+		url:     s.url,
+		ulColor: s.ulColor,
 	}
 }
 
@@ -118,7 +148,61 @@ func (s Style) Reverse(on bool) Style {
 // Underline returns a new style based on s, with the underline attribute set
 // as requested.
 func (s Style) Underline(on bool) Style {
-	return s.setAttrs(AttrUnderline, on)
+	return s.setUnderline(ulSolid, on)
+}
+
+// Undercurl returns a new style based on s, with a curly (wavy) underline
+// set as requested.  Terminals that cannot display curly underlines
+// will show a plain underline instead.
+func (s Style) Undercurl(on bool) Style {
+	return s.setUnderline(ulCurly, on)
+}
+
+// Underdotted returns a new style based on s, with a dotted underline
+// set as requested.  Terminals that cannot display dotted underlines
+// will show a plain underline instead.
+func (s Style) Underdotted(on bool) Style {
+	return s.setUnderline(ulDotted, on)
+}
+
+// Underdashed returns a new style based on s, with a dashed underline
+// set as requested.  Terminals that cannot display dashed underlines
+// will show a plain underline instead.
+func (s Style) Underdashed(on bool) Style {
+	return s.setUnderline(ulDashed, on)
+}
+
+// UnderlineColor returns a new style based on s, with the color of the
+// underline set as requested.  This has no effect unless the style is
+// also underlined.  ColorDefault draws the underline in the foreground
+// color, which is the usual behavior.
+func (s Style) UnderlineColor(c Color) Style {
+	return Style{
+		fg:      s.fg,
+		bg:      s.bg,
+		attrs:   s.attrs,
+		url:     s.url,
+		ulStyle: s.ulStyle,
+		ulColor: c,
+		off:     s.off,
+	}
+}
+
+// setUnderline turns the underline on with the given style, or turns it
+// off.  Turning off a specific style only has an effect if that style is
+// the one in use, except for the solid style, which always removes the
+// underline.
+func (s Style) setUnderline(ul int, on bool) Style {
+	if on {
+		s.attrs |= AttrUnderline
+		s.off &^= AttrUnderline
+		s.ulStyle = ul
+	} else if ul == ulSolid || s.ulStyle == ul {
+		s.attrs &^= AttrUnderline
+		s.off |= AttrUnderline
+		s.ulStyle = ulSolid
+	}
+	return s
 }
 
 // StrikeThrough sets strikethrough mode.
@@ -126,12 +210,93 @@ func (s Style) StrikeThrough(on bool) Style {
 	return s.setAttrs(AttrStrikeThrough, on)
 }
 
+// Overline returns a new style based on s, with the overline attribute set
+// as requested.
+func (s Style) Overline(on bool) Style {
+	return s.setAttrs(AttrOverline, on)
+}
+
+// Invisible returns a new style based on s, with the invisible (concealed)
+// attribute set as requested.  Invisible text is not shown, as for a
+// password, but the cells still hold it.
+func (s Style) Invisible(on bool) Style {
+	return s.setAttrs(AttrInvisible, on)
+}
+
 // Attributes returns a new style based on s, with its attributes set as
 // specified.
 func (s Style) Attributes(attrs AttrMask) Style {
 	return Style{
-		fg:    s.fg,
-		bg:    s.bg,
-		attrs: attrs,
+		fg:      s.fg,
+		bg:      s.bg,
+		attrs:   attrs,
+		url:     s.url,
+		ulStyle: s.ulStyle,
+		ulColor: s.ulColor,
+		off:     s.off &^ attrs,
+	}
+}
+
+// With returns a new style based on s, with the given attributes added
+// to those it already has.  This is a shorter way to turn on attributes
+// than calling each of their methods with true, for example:
+//
+//	StyleDefault.Foreground(ColorRed).With(AttrBold | AttrItalic)
+func (s Style) With(attrs AttrMask) Style {
+	return s.setAttrs(attrs&^AttrInvalid, true)
+}
+
+// Without returns a new style based on s, with the given attributes
+// removed, and any others left as they are.  Removing AttrUnderline
+// removes any kind of underline.
+func (s Style) Without(attrs AttrMask) Style {
+	s = s.setAttrs(attrs&^AttrInvalid, false)
+	if attrs&AttrUnderline != 0 {
+		s.ulStyle = ulSolid
+	}
+	return s
+}
+
+// Merge returns a new style based on s, overlaid with the parts of other
+// that are set, so that a child element can be styled by giving only
+// how it differs from its parent.  Colors other than ColorDefault, and
+// a non-empty URL, replace those of s.  The attributes of other are
+// added to those of s, and those that other turned off explicitly, as
+// with Bold(false) or Without, are removed; any others are inherited.
+// If other is underlined, its kind of underline is used.
+func (s Style) Merge(other Style) Style {
+	if other.fg != ColorDefault {
+		s.fg = other.fg
+	}
+	if other.bg != ColorDefault {
+		s.bg = other.bg
+	}
+	if other.url != "" {
+		s.url = other.url
+	}
+	if other.ulColor != ColorDefault {
+		s.ulColor = other.ulColor
+	}
+	if other.attrs&AttrUnderline != 0 || other.off&AttrUnderline != 0 {
+		s.ulStyle = other.ulStyle
+	}
+	s.attrs = s.attrs&^other.off | other.attrs
+	s.off = s.off&^other.attrs | other.off
+	return s
+}
+
+// URL returns a new style based on s, with the hyperlink target set
+// as requested.  If the URL is not empty, and the terminal supports it,
+// the text is displayed as a clickable link to that URL.  An empty URL
+// removes the link.
+func (s Style) URL(url string) Style {
+	return Style{
+		fg:      s.fg,
+		bg:      s.bg,
+		attrs:   s.attrs,
+		url:     url,
+		ulStyle: s.ulStyle,
+		ulColor: s.ulColor,
+		off:     s.off,
 	}
 }
@@ -15,6 +15,7 @@
 package tcell
 
 import (
+	"encoding/json"
 	"testing"
 )
 
@@ -38,4 +39,165 @@ func TestStyle(t *testing.T) {
 	if fg != ColorBlue || bg != ColorRed || attr != AttrBlink {
 		t.Errorf("Bad custom style (%v, %v, %v)", fg, bg, attr)
 	}
+	s.SetContent(1, 1, 'x', nil, s2.Overline(true))
+	_, _, s3, _ := s.GetContent(1, 1)
+	if _, _, attr = s3.Decompose(); attr != AttrBlink|AttrOverline {
+		t.Errorf("Bad overline style (%v)", attr)
+	}
+}
+
+func TestStyleUnderline(t *testing.T) {
+	s := StyleDefault.Undercurl(true).UnderlineColor(ColorRed)
+	if _, _, attr := s.Decompose(); attr != AttrUnderline {
+		t.Errorf("Undercurl should set underline (%v)", attr)
+	}
+	if s.ulStyle != ulCurly || s.ulColor != ColorRed {
+		t.Errorf("Bad underline style (%v, %v)", s.ulStyle, s.ulColor)
+	}
+
+	// Turning off a different style leaves the underline alone.
+	if _, _, attr := s.Underdotted(false).Decompose(); attr != AttrUnderline {
+		t.Errorf("Underdotted(false) should not clear undercurl")
+	}
+	s = s.Underline(false)
+	if _, _, attr := s.Decompose(); attr != AttrNone || s.ulStyle != ulSolid {
+		t.Errorf("Underline(false) should clear undercurl (%v)", attr)
+	}
+}
+
+func TestStyleWith(t *testing.T) {
+	s := StyleDefault.Foreground(ColorRed).Underline(true).With(AttrBold | AttrItalic)
+	if fg, _, attr := s.Decompose(); fg != ColorRed || attr != AttrBold|AttrItalic|AttrUnderline {
+		t.Errorf("Bad style with bold and italic (%v, %v)", fg, attr)
+	}
+	if s != StyleDefault.Foreground(ColorRed).Underline(true).Bold(true).Italic(true) {
+		t.Errorf("With should match the attribute methods")
+	}
+	if _, _, attr := s.Without(AttrItalic | AttrBlink).Decompose(); attr != AttrBold|AttrUnderline {
+		t.Errorf("Bad style without italic (%v)", attr)
+	}
+	s = s.Undercurl(true).Without(AttrUnderline)
+	if s != StyleDefault.Foreground(ColorRed).With(AttrBold|AttrItalic).Underline(false) {
+		t.Errorf("Without underline should clear undercurl (%v)", s.ulStyle)
+	}
+	if _, _, attr := StyleDefault.With(AttrInvalid).Decompose(); attr != AttrNone {
+		t.Errorf("With should not make the style invalid (%v)", attr)
+	}
+}
+
+func TestStyleMerge(t *testing.T) {
+	parent := StyleDefault.Foreground(ColorWhite).Background(ColorNavy).Bold(true).URL("https://example.com")
+	child := StyleDefault.Foreground(ColorYellow).Undercurl(true)
+
+	s := parent.Merge(child)
+	expect := StyleDefault.Foreground(ColorYellow).Background(ColorNavy).
+		Bold(true).Undercurl(true).URL("https://example.com")
+	if s != expect {
+		t.Errorf("Bad merged style %+v", s)
+	}
+	if parent.Merge(StyleDefault) != parent {
+		t.Errorf("Merging the default style should change nothing")
+	}
+	if s = StyleDefault.Merge(parent); s != parent {
+		t.Errorf("Merging into the default style should give the other %+v", s)
+	}
+
+	// attributes turned off explicitly are removed, and others inherited
+	s = parent.Italic(true).Merge(StyleDefault.Bold(false).Underline(true))
+	if _, _, attr := s.Decompose(); attr != AttrItalic|AttrUnderline {
+		t.Errorf("Bad merged attributes %v", attr)
+	}
+	if s = parent.Merge(StyleDefault.Bold(false).Bold(true)); s != parent {
+		t.Errorf("Turning bold back on should keep it %+v", s)
+	}
+}
+
+func TestStyleJSON(t *testing.T) {
+	s := StyleDefault.Foreground(ColorRed).Background(NewHexColor(0x1e1e2e)).
+		Bold(true).Italic(true).Undercurl(true).URL("https://example.com")
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	expect := `{"fg":"#ff0000","bg":"#1e1e2e","attrs":["bold","italic","undercurl"],"url":"https://example.com"}`
+	if string(data) != expect {
+		t.Errorf("Bad JSON %s", data)
+	}
+	var s2 Style
+	if err = json.Unmarshal(data, &s2); err != nil || s2 != s.Foreground(ColorRed.TrueColor()) {
+		t.Errorf("Style did not round trip: %+v %v", s2, err)
+	}
+
+	if data, _ = json.Marshal(StyleDefault); string(data) != "{}" {
+		t.Errorf("Bad JSON for default style %s", data)
+	}
+
+	var values = []struct {
+		json  string
+		style Style
+	}{
+		{`{"fg":"bright-red","bg":"Dark-Blue"}`, StyleDefault.Foreground(PaletteColor(9)).Background(ColorDarkBlue)},
+		{`{"fg":"red","bg":"bright-green"}`, StyleDefault.Foreground(PaletteColor(9)).Background(ColorLime)},
+		{`{"fg":"maroon","bg":"green"}`, StyleDefault.Foreground(PaletteColor(1)).Background(PaletteColor(2))},
+		{`{"fg":"grey","attrs":["Bold","reverse"]}`, StyleDefault.Foreground(ColorGray).Bold(true).Reverse(true)},
+		{`{"bg":"#f00","underline_color":"reset"}`, StyleDefault.Background(NewHexColor(0xff0000)).UnderlineColor(ColorReset)},
+	}
+	for _, tc := range values {
+		if err = json.Unmarshal([]byte(tc.json), &s2); err != nil || s2 != tc.style {
+			t.Errorf("%s: bad style %+v %v", tc.json, s2, err)
+		}
+	}
+
+	if err = json.Unmarshal([]byte(`{"fg":"no-such-color"}`), &s2); err != ErrBadColor {
+		t.Errorf("Expected ErrBadColor, got %v", err)
+	}
+	if err = json.Unmarshal([]byte(`{"attrs":["shiny"]}`), &s2); err == nil {
+		t.Errorf("Unknown attribute should fail")
+	}
+}
+
+func TestParseANSI(t *testing.T) {
+	rs, err := ParseANSI("a\x1b[1;31mb\x1b[0m\x1b[Kc\r\n\x1b[38;5;208;48:2::1:2:3;4:3md\x1b[39;22;24me")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	bold := StyleDefault.Bold(true).Foreground(ColorMaroon)
+	orange := StyleDefault.Foreground(PaletteColor(208)).Background(NewRGBColor(1, 2, 3)).Undercurl(true)
+	expect := []StyledRune{
+		{'a', StyleDefault},
+		{'b', bold},
+		{'c', StyleDefault},
+		{'\n', StyleDefault},
+		{'d', orange},
+		{'e', StyleDefault.Background(NewRGBColor(1, 2, 3)).Without(AttrBold | AttrDim | AttrUnderline)},
+	}
+	if len(rs) != len(expect) {
+		t.Fatalf("Expected %d runes, got %d: %v", len(expect), len(rs), rs)
+	}
+	for i := range rs {
+		if rs[i] != expect[i] {
+			t.Errorf("Rune %d: expected %+v, got %+v", i, expect[i], rs[i])
+		}
+	}
+
+	// hyperlinks, and the older form of RGB colors
+	rs, err = ParseANSI("\x1b]8;;http://x\x1b\\\x1b[38;2;10;20;30m\x1b[91ml\x1b]8;;\al")
+	if err != nil || len(rs) != 2 {
+		t.Fatalf("Bad hyperlink parse %v %v", rs, err)
+	}
+	if rs[0].Style != StyleDefault.Foreground(ColorRed).URL("http://x") ||
+		rs[1].Style != StyleDefault.Foreground(ColorRed) {
+		t.Errorf("Bad hyperlink styles %+v", rs)
+	}
+
+	// a charset designation, as sent by sgr0 for many terminals
+	rs, err = ParseANSI("\x1b[1mx\x1b(B\x1b[my")
+	if err != nil || len(rs) != 2 || rs[1].R != 'y' || rs[1].Style != StyleDefault {
+		t.Errorf("Bad parse of ESC ( B: %+v %v", rs, err)
+	}
+
+	rs, err = ParseANSI("ok\x1b[1")
+	if err != ErrBadEscape || len(rs) != 2 {
+		t.Errorf("Expected ErrBadEscape after two runes, got %v %v", rs, err)
+	}
 }
@@ -0,0 +1,173 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// styleJSON is how a Style appears in JSON.
+type styleJSON struct {
+	Fg      string   `json:"fg,omitempty"`
+	Bg      string   `json:"bg,omitempty"`
+	Attrs   []string `json:"attrs,omitempty"`
+	ULColor string   `json:"underline_color,omitempty"`
+	URL     string   `json:"url,omitempty"`
+}
+
+// attrNames are the names of attributes in JSON, in the order that they
+// are written.  The kinds of underline are handled separately.
+var attrNames = []struct {
+	name string
+	attr AttrMask
+}{
+	{"bold", AttrBold},
+	{"dim", AttrDim},
+	{"italic", AttrItalic},
+	{"underline", AttrUnderline},
+	{"blink", AttrBlink},
+	{"reverse", AttrReverse},
+	{"strikethrough", AttrStrikeThrough},
+	{"overline", AttrOverline},
+	{"invisible", AttrInvisible},
+}
+
+// underlineNames are the names of the kinds of underline in JSON.
+var underlineNames = map[int]string{
+	ulCurly:  "undercurl",
+	ulDotted: "underdotted",
+	ulDashed: "underdashed",
+}
+
+// brightColors are the names of the ANSI colors, in palette order, which
+// may be given with a "bright-" prefix for the bright versions.
+var brightColors = []string{
+	"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white",
+}
+
+// colorHexOf returns c as written in JSON, which is in hex, except for
+// ColorReset, and ColorDefault, which is left out.
+func colorHexOf(c Color) string {
+	switch c {
+	case ColorDefault:
+		return ""
+	case ColorReset:
+		return "reset"
+	}
+	return c.ToHex()
+}
+
+// parseColorName parses a color as written by colorHexOf.  It also
+// accepts the names in ColorNames, and "bright-" followed by the name of an ANSI color,
+// such as "bright-red", for the upper half of the 16 color palette, and
+// colors in hex.  Letter case and dashes are ignored in names.
+func parseColorName(s string) (Color, error) {
+	switch {
+	case s == "":
+		return ColorDefault, nil
+	case s[0] == '#':
+		return ColorFromHex(s)
+	}
+	name := strings.ToLower(s)
+	if name == "reset" {
+		return ColorReset, nil
+	}
+	if strings.HasPrefix(name, "bright-") {
+		for i, n := range brightColors {
+			if name[len("bright-"):] == n {
+				return PaletteColor(8 + i), nil
+			}
+		}
+		return ColorDefault, ErrBadColor
+	}
+	if c, ok := ColorNames[strings.Replace(name, "-", "", -1)]; ok {
+		return c, nil
+	}
+	return ColorDefault, ErrBadColor
+}
+
+// MarshalJSON encodes the style as a JSON object, such as
+// {"fg":"#ff0000","bg":"#1e1e2e","attrs":["bold","italic"]}.  Colors are
+// always written in hex, so that the output does not depend on which of
+// several names is chosen; palette colors are written as their usual RGB
+// values, and so come back as RGB colors.  Parts of the style that are not
+// set are left out.
+func (s Style) MarshalJSON() ([]byte, error) {
+	var sj styleJSON
+	sj.Fg = colorHexOf(s.fg)
+	sj.Bg = colorHexOf(s.bg)
+	sj.ULColor = colorHexOf(s.ulColor)
+	sj.URL = s.url
+	for _, an := range attrNames {
+		if s.attrs&an.attr == 0 {
+			continue
+		}
+		name := an.name
+		if an.attr == AttrUnderline && s.ulStyle != ulSolid {
+			name = underlineNames[s.ulStyle]
+		}
+		sj.Attrs = append(sj.Attrs, name)
+	}
+	return json.Marshal(&sj)
+}
+
+// UnmarshalJSON decodes a style encoded by MarshalJSON.  Colors may also
+// be given by the names in ColorNames, or as "bright-" followed by the
+// name of an ANSI color, such as "bright-red", for the bright half of the
+// 16 color palette.  Letter case and dashes in names are ignored.  Note
+// that ColorNames follows the web colors, where "red" is the bright red
+// of the palette, the same as "bright-red", but "green" is the dark green,
+// and "bright-green" is "lime"; use "maroon" for the dark red.  An unknown color gives
+// ErrBadColor, and an unknown attribute gives an error too.
+func (s *Style) UnmarshalJSON(data []byte) error {
+	var sj styleJSON
+	if err := json.Unmarshal(data, &sj); err != nil {
+		return err
+	}
+	var st Style
+	var err error
+	if st.fg, err = parseColorName(sj.Fg); err != nil {
+		return err
+	}
+	if st.bg, err = parseColorName(sj.Bg); err != nil {
+		return err
+	}
+	if st.ulColor, err = parseColorName(sj.ULColor); err != nil {
+		return err
+	}
+	st.url = sj.URL
+attrs:
+	for _, a := range sj.Attrs {
+		name := strings.ToLower(a)
+		for _, an := range attrNames {
+			if name == an.name {
+				st.attrs |= an.attr
+				continue attrs
+			}
+		}
+		for ul, n := range underlineNames {
+			if name == n {
+				st = st.setUnderline(ul, true)
+				continue attrs
+			}
+		}
+		return errors.New("unknown attribute " + strconv.Quote(a))
+	}
+	*s = st
+	return nil
+}
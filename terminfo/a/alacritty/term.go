@@ -25,6 +25,7 @@ func init() {
 		Italic:       "\x1b[3m",
 		Blink:        "\x1b[5m",
 		Reverse:      "\x1b[7m",
+		Invisible:    "\x1b[8m",
 		EnterKeypad:  "\x1b[?1h\x1b=",
 		ExitKeypad:   "\x1b[?1l\x1b>",
 		SetFg:        "\x1b[%?%p1%{8}%<%t3%p1%d%e%p1%{16}%<%t9%p1%{8}%-%d%e38;5;%p1%d%;m",
@@ -18,6 +18,7 @@ func init() {
 		Underline:    "\x1b[4m",
 		Bold:         "\x1b[1m",
 		Reverse:      "\x1b[7m",
+		Invisible:    "\x1b[8m",
 		SetFg:        "\x1b[3%p1%dm",
 		SetBg:        "\x1b[4%p1%dm",
 		SetFgBg:      "\x1b[3%p1%d;4%p2%dm",
@@ -212,6 +212,7 @@ func LoadTerminfo(name string) (*terminfo.Terminfo, string, error) {
 	t.Dim = tc.getstr("dim")
 	t.Italic = tc.getstr("sitm")
 	t.Reverse = tc.getstr("rev")
+	t.Invisible = tc.getstr("invis")
 	t.EnterKeypad = tc.getstr("smkx")
 	t.ExitKeypad = tc.getstr("rmkx")
 	t.SetFg = tc.getstr("setaf")
@@ -304,6 +305,9 @@ func LoadTerminfo(name string) (*terminfo.Terminfo, string, error) {
 	t.EnterAcs = tc.getstr("smacs")
 	t.ExitAcs = tc.getstr("rmacs")
 	t.EnableAcs = tc.getstr("enacs")
+	t.ToStatusLine = tc.getstr("tsl")
+	t.FromStatusLine = tc.getstr("fsl")
+	t.RepeatChar = tc.getstr("rep")
 	t.Mouse = tc.getstr("kmous")
 	t.KeyShfRight = tc.getstr("kRIT")
 	t.KeyShfLeft = tc.getstr("kLFT")
@@ -394,6 +398,12 @@ func LoadTerminfo(name string) (*terminfo.Terminfo, string, error) {
 		t.SetFg = "\x1b[%?%p1%{8}%<%t3%p1%d%e%p1%{16}%<%t9%p1%{8}%-%d%e38;5;%p1%d%;m"
 	}
 
+	// Styled (curly, dotted, etc.) and colored underlines are a kitty
+	// extension, advertised with either the Su flag or the Smulx string.
+	if tc.getflag("Su") || tc.getstr("Smulx") != "" {
+		t.StyledUnderline = true
+	}
+
 	// We only support colors in ANSI 8 or 256 color mode.
 	if t.Colors < 8 || t.SetFg == "" {
 		t.Colors = 0
@@ -38,6 +38,7 @@ func init() {
 		Bold:         "\x1b[1m",
 		Blink:        "\x1b[5m",
 		Reverse:      "\x1b[7m",
+		Invisible:    "\x1b[8m",
 		SetFg:        "\x1b[%p1%{30}%+%dm",
 		SetBg:        "\x1b[%p1%'('%+%dm",
 		SetFgBg:      "\x1b[%p1%{30}%+%d;%p2%'('%+%dm",
@@ -230,6 +230,7 @@ func getinfo(name string) (*terminfo.Terminfo, string, error) {
 	t.Dim = tc.getstr("dim")
 	t.Italic = tc.getstr("sitm")
 	t.Reverse = tc.getstr("rev")
+	t.Invisible = tc.getstr("invis")
 	t.EnterKeypad = tc.getstr("smkx")
 	t.ExitKeypad = tc.getstr("rmkx")
 	t.SetFg = tc.getstr("setaf")
@@ -271,6 +272,9 @@ func getinfo(name string) (*terminfo.Terminfo, string, error) {
 	t.EnterAcs = tc.getstr("smacs")
 	t.ExitAcs = tc.getstr("rmacs")
 	t.EnableAcs = tc.getstr("enacs")
+	t.ToStatusLine = tc.getstr("tsl")
+	t.FromStatusLine = tc.getstr("fsl")
+	t.RepeatChar = tc.getstr("rep")
 	t.StrikeThrough = tc.getstr("smxx")
 	t.Mouse = tc.getstr("kmous")
 
@@ -382,6 +386,12 @@ func getinfo(name string) (*terminfo.Terminfo, string, error) {
 		t.SetFg = "\x1b[%?%p1%{8}%<%t3%p1%d%e%p1%{16}%<%t9%p1%{8}%-%d%e38;5;%p1%d%;m"
 	}
 
+	// Styled (curly, dotted, etc.) and colored underlines are a kitty
+	// extension, advertised with either the Su flag or the Smulx string.
+	if tc.getflag("Su") || tc.getstr("Smulx") != "" {
+		t.StyledUnderline = true
+	}
+
 	// We only support colors in ANSI 8 or 256 color mode.
 	if t.Colors < 8 || t.SetFg == "" {
 		t.Colors = 0
@@ -487,6 +497,7 @@ func dotGoInfo(w io.Writer, terms []*TData) {
 		dotGoAddStr(w, "Italic", t.Italic)
 		dotGoAddStr(w, "Blink", t.Blink)
 		dotGoAddStr(w, "Reverse", t.Reverse)
+		dotGoAddStr(w, "Invisible", t.Invisible)
 		dotGoAddStr(w, "EnterKeypad", t.EnterKeypad)
 		dotGoAddStr(w, "ExitKeypad", t.ExitKeypad)
 		dotGoAddStr(w, "SetFg", t.SetFg)
@@ -498,6 +509,9 @@ func dotGoInfo(w io.Writer, terms []*TData) {
 		dotGoAddStr(w, "EnterAcs", t.EnterAcs)
 		dotGoAddStr(w, "ExitAcs", t.ExitAcs)
 		dotGoAddStr(w, "EnableAcs", t.EnableAcs)
+		dotGoAddStr(w, "ToStatusLine", t.ToStatusLine)
+		dotGoAddStr(w, "FromStatusLine", t.FromStatusLine)
+		dotGoAddStr(w, "RepeatChar", t.RepeatChar)
 		dotGoAddStr(w, "SetFgRGB", t.SetFgRGB)
 		dotGoAddStr(w, "SetBgRGB", t.SetBgRGB)
 		dotGoAddStr(w, "SetFgBgRGB", t.SetFgBgRGB)
@@ -605,6 +619,7 @@ func dotGoInfo(w io.Writer, terms []*TData) {
 		dotGoAddStr(w, "KeyCtrlEnd", t.KeyCtrlEnd)
 		dotGoAddInt(w, "Modifiers", t.Modifiers)
 		dotGoAddFlag(w, "TrueColor", t.TrueColor)
+		dotGoAddFlag(w, "StyledUnderline", t.StyledUnderline)
 		fmt.Fprintln(w, "\t})")
 	}
 	fmt.Fprintln(w, "}")
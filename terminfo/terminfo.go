@@ -41,125 +41,129 @@ var (
 // in Go, but when we write out JSON, we use the same names as terminfo.
 // The name, aliases and smous, rmous fields do not come from terminfo directly.
 type Terminfo struct {
-	Name         string
-	Aliases      []string
-	Columns      int    // cols
-	Lines        int    // lines
-	Colors       int    // colors
-	Bell         string // bell
-	Clear        string // clear
-	EnterCA      string // smcup
-	ExitCA       string // rmcup
-	ShowCursor   string // cnorm
-	HideCursor   string // civis
-	AttrOff      string // sgr0
-	Underline    string // smul
-	Bold         string // bold
-	Blink        string // blink
-	Reverse      string // rev
-	Dim          string // dim
-	Italic       string // sitm
-	EnterKeypad  string // smkx
-	ExitKeypad   string // rmkx
-	SetFg        string // setaf
-	SetBg        string // setab
-	ResetFgBg    string // op
-	SetCursor    string // cup
-	CursorBack1  string // cub1
-	CursorUp1    string // cuu1
-	PadChar      string // pad
-	KeyBackspace string // kbs
-	KeyF1        string // kf1
-	KeyF2        string // kf2
-	KeyF3        string // kf3
-	KeyF4        string // kf4
-	KeyF5        string // kf5
-	KeyF6        string // kf6
-	KeyF7        string // kf7
-	KeyF8        string // kf8
-	KeyF9        string // kf9
-	KeyF10       string // kf10
-	KeyF11       string // kf11
-	KeyF12       string // kf12
-	KeyF13       string // kf13
-	KeyF14       string // kf14
-	KeyF15       string // kf15
-	KeyF16       string // kf16
-	KeyF17       string // kf17
-	KeyF18       string // kf18
-	KeyF19       string // kf19
-	KeyF20       string // kf20
-	KeyF21       string // kf21
-	KeyF22       string // kf22
-	KeyF23       string // kf23
-	KeyF24       string // kf24
-	KeyF25       string // kf25
-	KeyF26       string // kf26
-	KeyF27       string // kf27
-	KeyF28       string // kf28
-	KeyF29       string // kf29
-	KeyF30       string // kf30
-	KeyF31       string // kf31
-	KeyF32       string // kf32
-	KeyF33       string // kf33
-	KeyF34       string // kf34
-	KeyF35       string // kf35
-	KeyF36       string // kf36
-	KeyF37       string // kf37
-	KeyF38       string // kf38
-	KeyF39       string // kf39
-	KeyF40       string // kf40
-	KeyF41       string // kf41
-	KeyF42       string // kf42
-	KeyF43       string // kf43
-	KeyF44       string // kf44
-	KeyF45       string // kf45
-	KeyF46       string // kf46
-	KeyF47       string // kf47
-	KeyF48       string // kf48
-	KeyF49       string // kf49
-	KeyF50       string // kf50
-	KeyF51       string // kf51
-	KeyF52       string // kf52
-	KeyF53       string // kf53
-	KeyF54       string // kf54
-	KeyF55       string // kf55
-	KeyF56       string // kf56
-	KeyF57       string // kf57
-	KeyF58       string // kf58
-	KeyF59       string // kf59
-	KeyF60       string // kf60
-	KeyF61       string // kf61
-	KeyF62       string // kf62
-	KeyF63       string // kf63
-	KeyF64       string // kf64
-	KeyInsert    string // kich1
-	KeyDelete    string // kdch1
-	KeyHome      string // khome
-	KeyEnd       string // kend
-	KeyHelp      string // khlp
-	KeyPgUp      string // kpp
-	KeyPgDn      string // knp
-	KeyUp        string // kcuu1
-	KeyDown      string // kcud1
-	KeyLeft      string // kcub1
-	KeyRight     string // kcuf1
-	KeyBacktab   string // kcbt
-	KeyExit      string // kext
-	KeyClear     string // kclr
-	KeyPrint     string // kprt
-	KeyCancel    string // kcan
-	Mouse        string // kmous
-	AltChars     string // acsc
-	EnterAcs     string // smacs
-	ExitAcs      string // rmacs
-	EnableAcs    string // enacs
-	KeyShfRight  string // kRIT
-	KeyShfLeft   string // kLFT
-	KeyShfHome   string // kHOM
-	KeyShfEnd    string // kEND
-	KeyShfInsert string // kIC
-	KeyShfDelete string // kDC
+	Name           string
+	Aliases        []string
+	Columns        int    // cols
+	Lines          int    // lines
+	Colors         int    // colors
+	Bell           string // bell
+	Clear          string // clear
+	EnterCA        string // smcup
+	ExitCA         string // rmcup
+	ShowCursor     string // cnorm
+	HideCursor     string // civis
+	AttrOff        string // sgr0
+	Underline      string // smul
+	Bold           string // bold
+	Blink          string // blink
+	Reverse        string // rev
+	Dim            string // dim
+	Italic         string // sitm
+	Invisible      string // invis
+	EnterKeypad    string // smkx
+	ExitKeypad     string // rmkx
+	SetFg          string // setaf
+	SetBg          string // setab
+	ResetFgBg      string // op
+	SetCursor      string // cup
+	CursorBack1    string // cub1
+	CursorUp1      string // cuu1
+	PadChar        string // pad
+	KeyBackspace   string // kbs
+	KeyF1          string // kf1
+	KeyF2          string // kf2
+	KeyF3          string // kf3
+	KeyF4          string // kf4
+	KeyF5          string // kf5
+	KeyF6          string // kf6
+	KeyF7          string // kf7
+	KeyF8          string // kf8
+	KeyF9          string // kf9
+	KeyF10         string // kf10
+	KeyF11         string // kf11
+	KeyF12         string // kf12
+	KeyF13         string // kf13
+	KeyF14         string // kf14
+	KeyF15         string // kf15
+	KeyF16         string // kf16
+	KeyF17         string // kf17
+	KeyF18         string // kf18
+	KeyF19         string // kf19
+	KeyF20         string // kf20
+	KeyF21         string // kf21
+	KeyF22         string // kf22
+	KeyF23         string // kf23
+	KeyF24         string // kf24
+	KeyF25         string // kf25
+	KeyF26         string // kf26
+	KeyF27         string // kf27
+	KeyF28         string // kf28
+	KeyF29         string // kf29
+	KeyF30         string // kf30
+	KeyF31         string // kf31
+	KeyF32         string // kf32
+	KeyF33         string // kf33
+	KeyF34         string // kf34
+	KeyF35         string // kf35
+	KeyF36         string // kf36
+	KeyF37         string // kf37
+	KeyF38         string // kf38
+	KeyF39         string // kf39
+	KeyF40         string // kf40
+	KeyF41         string // kf41
+	KeyF42         string // kf42
+	KeyF43         string // kf43
+	KeyF44         string // kf44
+	KeyF45         string // kf45
+	KeyF46         string // kf46
+	KeyF47         string // kf47
+	KeyF48         string // kf48
+	KeyF49         string // kf49
+	KeyF50         string // kf50
+	KeyF51         string // kf51
+	KeyF52         string // kf52
+	KeyF53         string // kf53
+	KeyF54         string // kf54
+	KeyF55         string // kf55
+	KeyF56         string // kf56
+	KeyF57         string // kf57
+	KeyF58         string // kf58
+	KeyF59         string // kf59
+	KeyF60         string // kf60
+	KeyF61         string // kf61
+	KeyF62         string // kf62
+	KeyF63         string // kf63
+	KeyF64         string // kf64
+	KeyInsert      string // kich1
+	KeyDelete      string // kdch1
+	KeyHome        string // khome
+	KeyEnd         string // kend
+	KeyHelp        string // khlp
+	KeyPgUp        string // kpp
+	KeyPgDn        string // knp
+	KeyUp          string // kcuu1
+	KeyDown        string // kcud1
+	KeyLeft        string // kcub1
+	KeyRight       string // kcuf1
+	KeyBacktab     string // kcbt
+	KeyExit        string // kext
+	KeyClear       string // kclr
+	KeyPrint       string // kprt
+	KeyCancel      string // kcan
+	Mouse          string // kmous
+	AltChars       string // acsc
+	EnterAcs       string // smacs
+	ExitAcs        string // rmacs
+	EnableAcs      string // enacs
+	KeyShfRight    string // kRIT
+	KeyShfLeft     string // kLFT
+	KeyShfHome     string // kHOM
+	KeyShfEnd      string // kEND
+	KeyShfInsert   string // kIC
+	KeyShfDelete   string // kDC
+	ToStatusLine   string // tsl
+	FromStatusLine string // fsl
+	RepeatChar     string // rep
 
 	// These are non-standard extensions to terminfo.  This includes
 	// true color support, and some additional keys.  Its kind of bizarre
@@ -168,10 +172,10 @@ type Terminfo struct {
 	// emulations, so don't depend too much on them in your application.
 
 	StrikeThrough   string // smxx
-	SetFgBg         string // setfgbg
-	SetFgBgRGB      string // setfgbgrgb
-	SetFgRGB        string // setfrgb
-	SetBgRGB        string // setbrgb
+	SetFgBg         string // setaf and setab together (tcell only)
+	SetFgBgRGB      string // setrgbf and setrgbb together (tcell only)
+	SetFgRGB        string // setrgbf
+	SetBgRGB        string // setrgbb
 	KeyShfUp        string // shift-up
 	KeyShfDown      string // shift-down
 	KeyShfPgUp      string // shift-kpp
@@ -218,6 +222,7 @@ type Terminfo struct {
 	PasteEnd        string
 	Modifiers       int
 	TrueColor       bool // true if the terminal supports direct color
+	StyledUnderline bool // true if the terminal supports styled (Su) underlines
 }
 
 const (
@@ -407,13 +412,18 @@ func (t *Terminfo) TParm(s string, p ...int) string {
 			params[0]++
 			params[1]++
 
-		case 'c', 's':
-			// NB: these, and 'd' below are special cased for
+		case 's':
+			// NB: these, and 'c' and 'd' below are special cased for
 			// efficiency.  They could be handled by the richer
 			// format support below, less efficiently.
 			a, stk = stk.Pop()
 			pb.PutString(a)
 
+		case 'c':
+			// the character with the given code, as printf would
+			ai, stk = stk.PopInt()
+			pb.PutCh(byte(ai))
+
 		case 'd':
 			ai, stk = stk.PopInt()
 			pb.PutString(strconv.Itoa(ai))
@@ -686,6 +696,138 @@ func (t *Terminfo) TPuts(w io.Writer, s string) {
 	}
 }
 
+// Capability returns the string capability with the given terminfo
+// name, such as "bel" or "cup", or the empty string if the terminal
+// does not have it.  Only capabilities that are sent to the terminal are
+// known here, not those describing the keys, apart from kmous, which
+// says whether the terminal has a mouse.
+func (t *Terminfo) Capability(name string) string {
+	if p := t.stringCap(name); p != nil {
+		return *p
+	}
+	return ""
+}
+
+// SetCapability changes the capability with the given terminfo name,
+// for terminals whose database entries are wrong.  The string capabilities
+// known to Capability may be set.  The numeric capabilities colors, cols and lines,
+// and the boolean capabilities RGB (or Tc) and Su, may also be set, from
+// their decimal or boolean representations.  An empty value for a boolean
+// capability means true.  It returns false if the name is not known, or
+// the value cannot be parsed.  Terminfo has no names for the sequences
+// that set both colors at once, which tcell makes itself, so those are
+// dropped when either color sequence is set, so that it is not bypassed.
+func (t *Terminfo) SetCapability(name, value string) bool {
+	if p := t.stringCap(name); p != nil {
+		*p = value
+		switch name {
+		case "setaf", "setab":
+			t.SetFgBg = ""
+		case "setrgbf", "setrgbb":
+			t.SetFgBgRGB = ""
+		}
+		return true
+	}
+	var err error
+	switch name {
+	case "colors":
+		t.Colors, err = strconv.Atoi(value)
+	case "cols":
+		t.Columns, err = strconv.Atoi(value)
+	case "lines":
+		t.Lines, err = strconv.Atoi(value)
+	case "RGB", "Tc":
+		t.TrueColor, err = parseFlag(value)
+	case "Su":
+		t.StyledUnderline, err = parseFlag(value)
+	default:
+		return false
+	}
+	return err == nil
+}
+
+// parseFlag parses the value of a boolean capability.
+func parseFlag(value string) (bool, error) {
+	if value == "" {
+		return true, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// stringCap returns the field holding the string capability with the
+// given terminfo name, or nil if it is not one we know.
+func (t *Terminfo) stringCap(name string) *string {
+	switch name {
+	case "bel":
+		return &t.Bell
+	case "clear":
+		return &t.Clear
+	case "smcup":
+		return &t.EnterCA
+	case "rmcup":
+		return &t.ExitCA
+	case "cnorm":
+		return &t.ShowCursor
+	case "civis":
+		return &t.HideCursor
+	case "sgr0":
+		return &t.AttrOff
+	case "smul":
+		return &t.Underline
+	case "bold":
+		return &t.Bold
+	case "blink":
+		return &t.Blink
+	case "rev":
+		return &t.Reverse
+	case "dim":
+		return &t.Dim
+	case "sitm":
+		return &t.Italic
+	case "invis":
+		return &t.Invisible
+	case "smkx":
+		return &t.EnterKeypad
+	case "rmkx":
+		return &t.ExitKeypad
+	case "setaf":
+		return &t.SetFg
+	case "setab":
+		return &t.SetBg
+	case "op":
+		return &t.ResetFgBg
+	case "cup":
+		return &t.SetCursor
+	case "cub1":
+		return &t.CursorBack1
+	case "cuu1":
+		return &t.CursorUp1
+	case "pad":
+		return &t.PadChar
+	case "smacs":
+		return &t.EnterAcs
+	case "rmacs":
+		return &t.ExitAcs
+	case "enacs":
+		return &t.EnableAcs
+	case "tsl":
+		return &t.ToStatusLine
+	case "fsl":
+		return &t.FromStatusLine
+	case "rep":
+		return &t.RepeatChar
+	case "smxx":
+		return &t.StrikeThrough
+	case "setrgbf":
+		return &t.SetFgRGB
+	case "setrgbb":
+		return &t.SetBgRGB
+	case "kmous":
+		return &t.Mouse
+	}
+	return nil
+}
+
 // TGoto returns a string suitable for addressing the cursor at the given
 // row and column.  The origin 0, 0 is in the upper left corner of the screen.
 func (t *Terminfo) TGoto(col, row int) string {
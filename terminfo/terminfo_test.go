@@ -62,6 +62,59 @@ func TestTerminfoExpansion(t *testing.T) {
 	if ti.TParm(ti.SetFg, 200) != "\x1b[38;5;200m" {
 		t.Error("SetFg(200) failed")
 	}
+
+	// Characters, as used by rep.
+	if ti.TParm("%p1%c\x1b[%p2%{1}%-%db", 'x', 5) != "x\x1b[4b" {
+		t.Error("Character expansion failed")
+	}
+}
+
+func TestCapability(t *testing.T) {
+	ti := testTerminfo
+	if ti.Capability("cup") != ti.SetCursor {
+		t.Error("Capability(cup) failed")
+	}
+	if ti.Capability("bel") != "\a" {
+		t.Error("Capability(bel) failed")
+	}
+	if ti.Capability("kf1") != "" || ti.Capability("nonesuch") != "" {
+		t.Error("Unknown capability found")
+	}
+}
+
+func TestSetCapability(t *testing.T) {
+	ti := *testTerminfo
+	if !ti.SetCapability("bel", "") || ti.Bell != "" {
+		t.Error("SetCapability(bel) failed")
+	}
+	if !ti.SetCapability("colors", "16") || ti.Colors != 16 {
+		t.Error("SetCapability(colors) failed")
+	}
+	if !ti.SetCapability("Tc", "") || !ti.TrueColor {
+		t.Error("SetCapability(Tc) failed")
+	}
+	if !ti.SetCapability("Su", "false") || ti.StyledUnderline {
+		t.Error("SetCapability(Su) failed")
+	}
+	if ti.SetCapability("colors", "many") {
+		t.Error("Bad numeric value accepted")
+	}
+	ti.SetFgBgRGB = "\x1b[38;2;%p1%d;%p2%d;%p3%d;48;2;%p4%d;%p5%d;%p6%dm"
+	if !ti.SetCapability("setrgbf", "\x1b[38:2::%p1%d:%p2%d:%p3%dm") || ti.SetFgRGB == "" {
+		t.Error("SetCapability(setrgbf) failed")
+	}
+	if ti.SetFgBgRGB != "" {
+		t.Error("Combined RGB sequence would bypass setrgbf")
+	}
+	if ti.SetCapability("setfrgb", "x") {
+		t.Error("SetCapability accepted a name that terminfo does not have")
+	}
+	if ti.SetCapability("nonesuch", "x") {
+		t.Error("Unknown capability accepted")
+	}
+	if testTerminfo.Bell != "\a" {
+		t.Error("Original entry changed")
+	}
 }
 
 func TestTerminfoDelay(t *testing.T) {
@@ -23,6 +23,7 @@ func init() {
 		Dim:          "\x1bGp",
 		Blink:        "\x1bG2",
 		Reverse:      "\x1bG4",
+		Invisible:    "\x1bG1",
 		PadChar:      "\x00",
 		AltChars:     "+/,.0[a2fxgqh1ihjYk?lZm@nEqDtCu4vAwBx3yszr{c~~",
 		EnterAcs:     "\x1bcE",
@@ -21,6 +21,7 @@ func init() {
 		Dim:          "\x1b[2m",
 		Blink:        "\x1b[5m",
 		Reverse:      "\x1b[7m",
+		Invisible:    "\x1b[8m",
 		EnterKeypad:  "\x1b[?1h",
 		ExitKeypad:   "\x1b[?1l",
 		PadChar:      "\x00",
@@ -74,6 +75,7 @@ func init() {
 		Dim:          "\x1b[2m",
 		Blink:        "\x1b[5m",
 		Reverse:      "\x1b[7m",
+		Invisible:    "\x1b[8m",
 		EnterKeypad:  "\x1b[?1h",
 		ExitKeypad:   "\x1b[?1l",
 		PadChar:      "\x00",
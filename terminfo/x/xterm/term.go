@@ -26,6 +26,7 @@ func init() {
 		Italic:       "\x1b[3m",
 		Blink:        "\x1b[5m",
 		Reverse:      "\x1b[7m",
+		Invisible:    "\x1b[8m",
 		EnterKeypad:  "\x1b[?1h\x1b=",
 		ExitKeypad:   "\x1b[?1l\x1b>",
 		SetFg:        "\x1b[3%p1%dm",
@@ -36,6 +37,7 @@ func init() {
 		EnterAcs:     "\x1b(0",
 		ExitAcs:      "\x1b(B",
 		StrikeThrough:"\x1b[9m",
+		RepeatChar:   "%p1%c\x1b[%p2%{1}%-%db",
 		Mouse:        "\x1b[M",
 		SetCursor:    "\x1b[%i%p1%d;%p2%dH",
 		CursorBack1:  "\b",
@@ -86,6 +88,7 @@ func init() {
 		Italic:       "\x1b[3m",
 		Blink:        "\x1b[5m",
 		Reverse:      "\x1b[7m",
+		Invisible:    "\x1b[8m",
 		EnterKeypad:  "\x1b[?1h\x1b=",
 		ExitKeypad:   "\x1b[?1l\x1b>",
 		SetFg:        "\x1b[%?%p1%{8}%<%t3%p1%d%e%p1%{16}%<%t9%p1%{8}%-%d%e38;5;%p1%d%;m",
@@ -96,6 +99,7 @@ func init() {
 		EnterAcs:     "\x1b(0",
 		ExitAcs:      "\x1b(B",
 		StrikeThrough:"\x1b[9m",
+		RepeatChar:   "%p1%c\x1b[%p2%{1}%-%db",
 		Mouse:        "\x1b[M",
 		SetCursor:    "\x1b[%i%p1%d;%p2%dH",
 		CursorBack1:  "\b",
@@ -146,6 +150,7 @@ func init() {
 		Italic:       "\x1b[3m",
 		Blink:        "\x1b[5m",
 		Reverse:      "\x1b[7m",
+		Invisible:    "\x1b[8m",
 		EnterKeypad:  "\x1b[?1h\x1b=",
 		ExitKeypad:   "\x1b[?1l\x1b>",
 		SetFg:        "\x1b[%?%p1%{8}%<%t3%p1%d%e%p1%{16}%<%t9%p1%{8}%-%d%e38;5;%p1%d%;m",
@@ -156,6 +161,7 @@ func init() {
 		EnterAcs:     "\x1b(0",
 		ExitAcs:      "\x1b(B",
 		StrikeThrough:"\x1b[9m",
+		RepeatChar:   "%p1%c\x1b[%p2%{1}%-%db",
 		Mouse:        "\x1b[M",
 		SetCursor:    "\x1b[%i%p1%d;%p2%dH",
 		CursorBack1:  "\b",
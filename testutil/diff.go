@@ -0,0 +1,103 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testutil has helpers for testing applications that use tcell,
+// by comparing the contents of their screens with what is expected.
+// Simulation screens are the most useful for this.
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// maxDiffs is the most differences that Diff lists, so that a screen
+// that is completely wrong does not give pages of output.
+const maxDiffs = 20
+
+// cellText describes the contents of a cell, as its text, quoted, and its
+// style, in the JSON form, which is easy to read.
+func cellText(mainc rune, combc []rune, style tcell.Style) string {
+	text := string(append([]rune{mainc}, combc...))
+	if mainc == 0 {
+		text = ""
+	}
+	b, _ := json.Marshal(style)
+	return fmt.Sprintf("%q %s", text, b)
+}
+
+// sameStyle reports whether two styles are the same in JSON.
+func sameStyle(s1, s2 tcell.Style) bool {
+	if s1 == s2 {
+		return true
+	}
+	b1, _ := json.Marshal(s1)
+	b2, _ := json.Marshal(s2)
+	return string(b1) == string(b2)
+}
+
+// Diff compares the contents of two screens, cell by cell, and describes
+// how they differ, or returns an empty string if they are the same.  For
+// each cell that differs, its column and row are given, along with the
+// text and style expected and those found.  The contents compared are
+// those set by the application, which are shown when Show is next
+// called.  Styles are compared in their JSON form, so that a palette
+// color matches the same color in RGB, as it is read from a golden file.
+func Diff(expected, actual tcell.Screen) string {
+	var sb strings.Builder
+	ew, eh := expected.Size()
+	aw, ah := actual.Size()
+	if ew != aw || eh != ah {
+		fmt.Fprintf(&sb, "size: expected %dx%d, actual %dx%d\n", ew, eh, aw, ah)
+	}
+	w, h := ew, eh
+	if aw < w {
+		w = aw
+	}
+	if ah < h {
+		h = ah
+	}
+
+	n := 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			em, ec, es, _ := expected.GetContent(x, y)
+			am, ac, as, _ := actual.GetContent(x, y)
+			if em == am && sameStyle(es, as) && string(ec) == string(ac) {
+				continue
+			}
+			if n++; n <= maxDiffs {
+				fmt.Fprintf(&sb, "(%d,%d): expected %s, actual %s\n",
+					x, y, cellText(em, ec, es), cellText(am, ac, as))
+			}
+		}
+	}
+	if n > maxDiffs {
+		fmt.Fprintf(&sb, "... and %d more cells differ\n", n-maxDiffs)
+	}
+	return sb.String()
+}
+
+// AssertEqual fails the test if the contents of the screens differ, with
+// the differences that Diff gives as the message.
+func AssertEqual(t testing.TB, expected, actual tcell.Screen) {
+	t.Helper()
+	if d := Diff(expected, actual); d != "" {
+		t.Errorf("screens differ:\n%s", d)
+	}
+}
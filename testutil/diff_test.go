@@ -0,0 +1,76 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func mkScreen(t *testing.T, w, h int) tcell.SimulationScreen {
+	s := tcell.NewSimulationScreen("")
+	if err := s.Init(); err != nil {
+		t.Fatalf("Failed to initialize screen: %v", err)
+	}
+	s.SetSize(w, h)
+	s.Show()
+	return s
+}
+
+func TestDiff(t *testing.T) {
+	s1 := mkScreen(t, 10, 3)
+	defer s1.Fini()
+	s2 := mkScreen(t, 10, 3)
+	defer s2.Fini()
+
+	s1.DrawText(0, 1, tcell.StyleDefault, "hello")
+	s2.DrawText(0, 1, tcell.StyleDefault, "hello")
+	if d := Diff(s1, s2); d != "" {
+		t.Errorf("Expected no differences, got %q", d)
+	}
+	AssertEqual(t, s1, s2)
+
+	s2.SetContent(1, 1, 'u', nil, tcell.StyleDefault)
+	s2.SetContent(4, 1, 'o', nil, tcell.StyleDefault.Bold(true))
+	d := Diff(s1, s2)
+	lines := strings.Split(strings.TrimSpace(d), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 differences, got %q", d)
+	}
+	if lines[0] != `(1,1): expected "e" {}, actual "u" {}` {
+		t.Errorf("Bad difference: %s", lines[0])
+	}
+	if lines[1] != `(4,1): expected "o" {}, actual "o" {"attrs":["bold"]}` {
+		t.Errorf("Bad difference: %s", lines[1])
+	}
+}
+
+func TestDiffSize(t *testing.T) {
+	s1 := mkScreen(t, 10, 3)
+	defer s1.Fini()
+	s2 := mkScreen(t, 8, 3)
+	defer s2.Fini()
+
+	if d := Diff(s1, s2); !strings.HasPrefix(d, "size: expected 10x3, actual 8x3\n") {
+		t.Errorf("Expected size difference, got %q", d)
+	}
+
+	s2.FillRegion(0, 0, 8, 3, 'x', tcell.StyleDefault)
+	if d := Diff(s1, s2); !strings.Contains(d, "... and 4 more cells differ") {
+		t.Errorf("Expected differences to be cut short, got %q", d)
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ErrBadGolden is returned by LoadGolden for a file that is not in the
+// form that SaveGolden writes.
+var ErrBadGolden = errors.New("bad golden file")
+
+// goldenMagic is the first line of a golden file.
+const goldenMagic = "# tcell golden file 1"
+
+// styleKeys are the characters used for styles in golden files, in the
+// order that styles are found.  StyleDefault is always '.'.  Beyond
+// these, runes from U+0100 up are used.
+const styleKeys = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func init() {
+	// the flag may already be defined by the test program itself,
+	// in which case that one is used
+	if flag.Lookup("update") == nil {
+		flag.Bool("update", false, "update golden files")
+	}
+}
+
+// updateGolden reports whether the -update flag was given.
+func updateGolden() bool {
+	f := flag.Lookup("update")
+	return f != nil && f.Value.String() == "true"
+}
+
+// styleKey returns the key for the i'th style found.
+func styleKey(i int) rune {
+	if i < len(styleKeys) {
+		return rune(styleKeys[i])
+	}
+	return rune(0x100 + i - len(styleKeys))
+}
+
+// SaveGolden writes the contents of a screen to a file, so that it can
+// be loaded by LoadGolden.  The format is text, which shows the changes
+// well when the file is kept under version control.  After the size,
+// the styles used are listed, each with a key.  Then each row is given
+// by the contents of its cells, as a JSON array of strings, and a line
+// with the key of the style of each cell.
+func SaveGolden(screen tcell.Screen, path string) error {
+	var buf bytes.Buffer
+	w, h := screen.Size()
+	keys := map[tcell.Style]rune{tcell.StyleDefault: '.'}
+	var styles []tcell.Style
+	rows := make([][]string, h)
+	rowKeys := make([][]rune, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			mainc, combc, style, _ := screen.GetContent(x, y)
+			text := ""
+			if mainc != 0 {
+				text = string(append([]rune{mainc}, combc...))
+			}
+			rows[y] = append(rows[y], text)
+			k, ok := keys[style]
+			if !ok {
+				k = styleKey(len(styles))
+				keys[style] = k
+				styles = append(styles, style)
+			}
+			rowKeys[y] = append(rowKeys[y], k)
+		}
+	}
+
+	fmt.Fprintf(&buf, "%s\nsize %d %d\n", goldenMagic, w, h)
+	for _, style := range styles {
+		b, err := json.Marshal(style)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "style %c %s\n", keys[style], b)
+	}
+	for y := 0; y < h; y++ {
+		b, err := json.Marshal(rows[y])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "row %d %s\nstyles %d %s\n", y, b, y, string(rowKeys[y]))
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// LoadGolden reads a file written by SaveGolden, and returns a simulation
+// screen with the contents that were saved.  The caller should call Fini
+// on the screen when done with it.
+func LoadGolden(path string) (tcell.SimulationScreen, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<24)
+	if !sc.Scan() || sc.Text() != goldenMagic {
+		return nil, ErrBadGolden
+	}
+	var w, h int
+	if !sc.Scan() {
+		return nil, ErrBadGolden
+	}
+	if _, err := fmt.Sscanf(sc.Text(), "size %d %d", &w, &h); err != nil {
+		return nil, ErrBadGolden
+	}
+
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		return nil, err
+	}
+	s.SetSize(w, h)
+	s.Show()
+	if err := loadCells(s, sc, w); err != nil {
+		s.Fini()
+		return nil, err
+	}
+	s.Show()
+	return s, nil
+}
+
+// loadCells reads the styles and rows of a golden file, setting the
+// contents of s from them.
+func loadCells(s tcell.Screen, sc *bufio.Scanner, w int) error {
+	styles := map[rune]tcell.Style{'.': tcell.StyleDefault}
+	var cells []string
+	for sc.Scan() {
+		words := strings.SplitN(sc.Text(), " ", 3)
+		if len(words) != 3 {
+			return ErrBadGolden
+		}
+		switch words[0] {
+		case "style":
+			var style tcell.Style
+			k := []rune(words[1])
+			if len(k) != 1 || json.Unmarshal([]byte(words[2]), &style) != nil {
+				return ErrBadGolden
+			}
+			styles[k[0]] = style
+
+		case "row":
+			if json.Unmarshal([]byte(words[2]), &cells) != nil || len(cells) != w {
+				return ErrBadGolden
+			}
+
+		case "styles":
+			var y int
+			if _, err := fmt.Sscanf(words[1], "%d", &y); err != nil {
+				return ErrBadGolden
+			}
+			keys := []rune(words[2])
+			if len(keys) != len(cells) {
+				return ErrBadGolden
+			}
+			for x, text := range cells {
+				style, ok := styles[keys[x]]
+				if !ok {
+					return ErrBadGolden
+				}
+				rs := []rune(text)
+				if len(rs) == 0 {
+					rs = []rune{0}
+				}
+				s.SetContent(x, y, rs[0], rs[1:], style)
+			}
+
+		default:
+			return ErrBadGolden
+		}
+	}
+	return sc.Err()
+}
+
+// AssertGolden compares the contents of screen with those saved in the
+// golden file at path, failing the test if they differ.  If the file
+// does not exist, or the test is run with the -update flag, the file is
+// written instead, along with any directories needed for it.
+func AssertGolden(t testing.TB, screen tcell.Screen, path string) {
+	t.Helper()
+	if _, err := os.Stat(path); os.IsNotExist(err) || updateGolden() {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("cannot create directory for %s: %v", path, err)
+		}
+		if err := SaveGolden(screen, path); err != nil {
+			t.Fatalf("cannot write %s: %v", path, err)
+		}
+		t.Logf("wrote golden file %s", path)
+		return
+	}
+	expected, err := LoadGolden(path)
+	if err != nil {
+		t.Fatalf("cannot load %s: %v", path, err)
+	}
+	defer expected.Fini()
+	if d := Diff(expected, screen); d != "" {
+		t.Errorf("screen differs from %s:\n%s", path, d)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// fakeTB records failures, rather than failing the real test.
+type fakeTB struct {
+	testing.TB
+	failed bool
+}
+
+func (f *fakeTB) Helper()                           {}
+func (f *fakeTB) Logf(string, ...interface{})       {}
+func (f *fakeTB) Errorf(string, ...interface{})     { f.failed = true }
+func (f *fakeTB) Fatalf(s string, a ...interface{}) { f.TB.Fatalf(s, a...) }
+
+func TestGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golden")
+	if err != nil {
+		t.Fatalf("Cannot make directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := mkScreen(t, 12, 3)
+	defer s.Fini()
+	s.DrawText(0, 0, tcell.StyleDefault.Bold(true), "bold")
+	s.DrawText(0, 1, tcell.StyleDefault.Foreground(tcell.ColorRed), "é世\U0001f1ef\U0001f1f5")
+	s.SetContent(11, 2, '"', nil, tcell.StyleDefault.URL("http://x"))
+
+	path := filepath.Join(dir, "screen.golden")
+	if err := SaveGolden(s, path); err != nil {
+		t.Fatalf("Cannot save: %v", err)
+	}
+	g, err := LoadGolden(path)
+	if err != nil {
+		t.Fatalf("Cannot load: %v", err)
+	}
+	defer g.Fini()
+	AssertEqual(t, s, g)
+
+	// created on first use, and then compared
+	path = filepath.Join(dir, "new", "assert.golden")
+	ft := &fakeTB{TB: t}
+	AssertGolden(ft, s, path)
+	if _, err := os.Stat(path); err != nil || ft.failed {
+		t.Fatalf("Golden file not created: %v", err)
+	}
+	AssertGolden(ft, s, path)
+	if ft.failed {
+		t.Errorf("Same screen should match")
+	}
+	s.SetContent(0, 2, 'x', nil, tcell.StyleDefault)
+	AssertGolden(ft, s, path)
+	if !ft.failed {
+		t.Errorf("Changed screen should not match")
+	}
+}
+
+func TestLoadGoldenBad(t *testing.T) {
+	f, err := ioutil.TempFile("", "golden")
+	if err != nil {
+		t.Fatalf("Cannot make file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	_, _ = f.WriteString("# tcell golden file 1\nsize 2 1\nrow 0 [\"a\"]\n")
+	f.Close()
+	if _, err := LoadGolden(f.Name()); err != ErrBadGolden {
+		t.Errorf("Expected ErrBadGolden, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"unicode/utf8"
+
+	runewidth "github.com/mattn/go-runewidth"
+	"github.com/rivo/uniseg"
+)
+
+// RuneWidth returns the number of cells that r takes up on the screen.
+// This is 2 for CJK ideographs, fullwidth forms, and most emoji, 0 for
+// combining characters and control characters, and 1 for the rest.  It
+// is the width that screens use for the contents of cells, so it can be
+// used to lay out text without any difference from how it is drawn.
+// Characters of ambiguous East Asian width are taken to be narrow,
+// unless the locale or the RUNEWIDTH_EASTASIAN environment variable say
+// otherwise.
+func RuneWidth(r rune) int {
+	return runewidth.RuneWidth(r)
+}
+
+// cellWidth returns the number of cells that a character, with the
+// combining runes that follow it, takes up.  This is the width of the
+// character, except that emoji presentation (a variation selector 16)
+// and flags (a pair of regional indicators) make it two cells wide, as
+// terminals show them.  The CellBuffer sizes its cells with this too, so
+// that text is laid out just as it is drawn.
+func cellWidth(mainc rune, combc []rune) int {
+	w := RuneWidth(mainc)
+	if len(combc) > 0 {
+		switch {
+		case mainc >= 0x1f1e6 && mainc <= 0x1f1ff:
+			w = 2
+		default:
+			for _, r := range combc {
+				if r == 0xfe0f {
+					w = 2
+				}
+			}
+		}
+	}
+	return w
+}
+
+// GraphemeCluster splits s into its grapheme clusters, which are what
+// users see as single characters.  A cluster may be made of several
+// runes, such as a letter and its accents, an emoji with a skin tone
+// modifier, a family of emoji joined by zero width joiners, or a flag.
+// Each cluster belongs in one cell, with its first rune as the primary
+// rune, and the rest as combining runes, as passed to SetContent.
+func GraphemeCluster(s string) [][]rune {
+	var res [][]rune
+	g := uniseg.NewGraphemes(s)
+	for g.Next() {
+		res = append(res, g.Runes())
+	}
+	return res
+}
+
+// StringWidth returns the number of cells that s takes up on the screen,
+// which is the sum of the widths of its grapheme clusters.  ANSI escape
+// sequences, such as those that set colors, take up no cells, and nor
+// do control characters.  Strings of ASCII are measured without any
+// memory being allocated.
+func StringWidth(s string) int {
+	w := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\x1b':
+			n := EscapeLength(s[i:])
+			if n == 0 {
+				// an incomplete escape sequence
+				return w
+			}
+			i += n
+		case c >= utf8.RuneSelf:
+			j := i + 1
+			for j < len(s) && s[j] != '\x1b' {
+				j++
+			}
+			w += textWidth(s[i:j])
+			i = j
+		default:
+			if c >= ' ' && c != 0x7f {
+				w++
+			}
+			i++
+		}
+	}
+	return w
+}
+
+// textWidth returns the width of s, which has no escape sequences, by
+// grapheme cluster.
+func textWidth(s string) int {
+	w := 0
+	g := uniseg.NewGraphemes(s)
+	for g.Next() {
+		rs := g.Runes()
+		w += cellWidth(rs[0], rs[1:])
+	}
+	return w
+}
+
+// drawText does the work of Screen.DrawText, using only SetContent, so
+// that it works for any Screen.
+func drawText(s Screen, x, y int, style Style, text string) int {
+	sw, sh := s.Size()
+	if y < 0 || y >= sh {
+		return 0
+	}
+	cx := x
+	g := uniseg.NewGraphemes(text)
+	for g.Next() {
+		rs := g.Runes()
+		w := cellWidth(rs[0], rs[1:])
+		if w < 1 {
+			// a control character, or a lone combining mark
+			continue
+		}
+		if cx+w > sw {
+			break
+		}
+		if cx >= 0 {
+			s.SetContent(cx, y, rs[0], rs[1:], style)
+		}
+		cx += w
+	}
+	return cx - x
+}
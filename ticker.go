@@ -0,0 +1,73 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"time"
+)
+
+// EventTicker is sent periodically, at the interval requested with
+// Screen.SetTicker.  It is useful for animations and the like.
+type EventTicker struct {
+	t time.Time
+}
+
+// NewEventTicker creates an EventTicker for a tick at the given time.
+func NewEventTicker(t time.Time) *EventTicker {
+	return &EventTicker{t: t}
+}
+
+// When returns the time of the tick.
+func (ev *EventTicker) When() time.Time {
+	return ev.t
+}
+
+// ticker posts EventTicker events from its own goroutine.  The screens
+// embed one of these, and call its methods with their lock held.
+type ticker struct {
+	d     time.Duration
+	stopQ chan struct{}
+}
+
+// start (re)starts posting events at the configured interval, if any.
+func (tk *ticker) start(post func(Event) error) {
+	tk.stop()
+	if tk.d <= 0 {
+		return
+	}
+	stopQ := make(chan struct{})
+	tk.stopQ = stopQ
+	go func(d time.Duration) {
+		tick := time.NewTicker(d)
+		defer tick.Stop()
+		for {
+			select {
+			case <-stopQ:
+				return
+			case now := <-tick.C:
+				_ = post(NewEventTicker(now))
+			}
+		}
+	}(tk.d)
+}
+
+// stop stops posting events.  The interval is retained, so that a
+// later start resumes with it.
+func (tk *ticker) stop() {
+	if tk.stopQ != nil {
+		close(tk.stopQ)
+		tk.stopQ = nil
+	}
+}
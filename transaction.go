@@ -0,0 +1,64 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"sync"
+)
+
+// batch keeps track of the transactions that a screen is in, during
+// which drawing is put off.  It is protected by the screen's lock.
+type batch struct {
+	depth int  // number of transactions in progress
+	sync  bool // whether Sync was called during them
+}
+
+// hold reports whether drawing should be put off, because a transaction
+// is in progress.  If so, it notes whether a full redraw was wanted.
+func (b *batch) hold(sync bool) bool {
+	if b.depth == 0 {
+		return false
+	}
+	b.sync = b.sync || sync
+	return true
+}
+
+// transaction runs f as a transaction on a screen with the given lock and
+// batch, and then draws the screen, with show or with sync if that was
+// called during the transaction.  Transactions may be nested, in which
+// case only the outermost one draws.
+func transaction(lk sync.Locker, b *batch, f func(), show, sync func()) {
+	lk.Lock()
+	b.depth++
+	lk.Unlock()
+
+	defer func() {
+		lk.Lock()
+		b.depth--
+		done, full := b.depth == 0, b.sync
+		if done {
+			b.sync = false
+		}
+		lk.Unlock()
+		switch {
+		case !done:
+		case full:
+			sync()
+		default:
+			show()
+		}
+	}()
+	f()
+}
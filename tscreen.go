@@ -15,13 +15,19 @@
 package tcell
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
 	"io"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 	"unicode/utf8"
 
 	"golang.org/x/term"
@@ -47,7 +53,13 @@ func NewTerminfoScreen() (Screen, error) {
 }
 
 func NewTerminfoScreenWithDriver(driver TermDriver) (Screen, error) {
-	t := &tScreen{driver: driver}
+	return NewTerminfoScreenWithOptions(driver, ScreenOptions{})
+}
+
+// NewTerminfoScreenWithOptions is like NewTerminfoScreenWithDriver, but
+// uses the given options instead of the defaults.
+func NewTerminfoScreenWithOptions(driver TermDriver, opts ScreenOptions) (Screen, error) {
+	t := &tScreen{driver: driver, opts: opts}
 
 	ti, e := terminfo.LookupTerminfo(driver.GetTerm())
 	if e != nil {
@@ -58,6 +70,28 @@ func NewTerminfoScreenWithDriver(driver TermDriver) (Screen, error) {
 		terminfo.AddTerminfo(ti)
 	}
 
+	// The user's overrides from the environment take precedence over
+	// those of the application.
+	if env := os.Getenv(envOverrides); env != "" {
+		caps := make(map[string]string)
+		for name, value := range opts.CapabilityOverrides {
+			caps[name] = value
+		}
+		for name, value := range parseOverrides(env) {
+			caps[name] = value
+		}
+		t.opts.CapabilityOverrides = caps
+	}
+	if len(t.opts.CapabilityOverrides) > 0 {
+		// work on a copy, as the entry is shared
+		tc := *ti
+		ti = &tc
+		for name, value := range t.opts.CapabilityOverrides {
+			if !ti.SetCapability(name, value) {
+				return nil, ErrNoCapability
+			}
+		}
+	}
 	t.ti = ti
 
 	t.keyexist = make(map[Key]bool)
@@ -67,7 +101,13 @@ func NewTerminfoScreenWithDriver(driver TermDriver) (Screen, error) {
 	}
 	t.prepareKeys()
 	t.buildAcsMap()
+	t.prepareHyperlinks()
+	t.prepareUnderlines()
+	t.prepareTitles()
 	t.sigwinch = make(chan os.Signal, 10)
+	t.winsizech = make(chan struct{}, 10)
+	t.clipch = make(chan []byte, 1)
+	t.colorch = make(chan colorReport, 1)
 	t.fallback = make(map[rune]string)
 	for k, v := range RuneFallbacks {
 		t.fallback[k] = v
@@ -91,13 +131,20 @@ type tScreen struct {
 	fini         bool
 	cells        CellBuffer
 	in           *os.File
+	tty          bool // in is a terminal, rather than a pipe
 	out          *os.File
-	buffering    bool // true if we are collecting writes to buf instead of sending directly to out
+	outw         *bufio.Writer
+	frameTime    time.Duration
+	lastFrame    time.Time // when the last frame was drawn
+	framePending bool      // a Show is waiting for the frame time to pass
+	buffering    bool      // true if we are collecting writes to buf instead of sending directly to out
 	buf          bytes.Buffer
 	curstyle     Style
+	sgr          sgrState
 	style        Style
 	evch         chan Event
 	sigwinch     chan os.Signal
+	winsizech    chan struct{}
 	quit         chan struct{}
 	keyexist     map[Key]bool
 	keycodes     map[string]*tKeyCode
@@ -110,25 +157,85 @@ type tScreen struct {
 	clear        bool
 	cursorx      int
 	cursory      int
+	softCursor   softCursor
 	wasbtn       bool
 	acs          map[rune]string
 	charset      string
 	encoder      transform.Transformer
+	asciiSafe    bool // encoder leaves printable ASCII unchanged
+	batch        batch
 	decoder      transform.Transformer
 	fallback     map[rune]string
 	colors       map[Color]Color
-	palette      []Color
+	fgColors     map[Color]Color // foreground colors, fitted to bgColor
+	bgColors     map[Color]Color // background colors, fitted to fgColor
 	truecolor    bool
+	depth        ColorDepth
 	escaped      bool
 	buttondn     bool
+	buttons      ButtonMask
+	scroll       scrollAccum
+	compose      composer
+	paste        pasteCollector
+	escDelay     time.Duration
 	finiOnce     sync.Once
 	enablePaste  string
 	disablePaste string
+	enableFocus  string
+	disableFocus string
 	saved        *term.State
 	stopQ        chan struct{}
 	wg           sync.WaitGroup
 	mouseFlags   MouseFlags
+	origCols     int       // width before SetColumns, or zero if not used
+	origMode40   modeState // mode 40 before SetColumns
+	pixelMouse   bool      // mouse positions are reported in pixels
+	cellW        int       // width of a cell in pixels, or zero if unknown
+	cellH        int       // height of a cell in pixels, or zero if unknown
 	pasteEnabled bool
+	focusEnabled bool
+	kittyKbd     bool // terminal supports the kitty keyboard protocol
+	syncOutput   bool // terminal supports synchronized output
+	syncQueried  bool
+	daQueried    bool
+	sixelQueried bool
+	da1          []int  // primary device attributes
+	da2          []int  // secondary device attributes: type, version, ROM
+	da3          string // tertiary device attributes: unit ID
+	termName     string // from XTVERSION
+	termVersion  string
+	multiplexer  string // tmux, screen or zellij
+	muxReplies   int    // DA1 replies still to come from the multiplexer
+	hostReplies  bool   // replies are from the terminal the multiplexer is in
+	hostDA1      []int  // primary device attributes of that terminal
+	hostDA2      []int  // secondary device attributes of that terminal
+	hostName     string // from XTVERSION, for that terminal
+	hostVersion  string
+	hyperlinks   bool     // terminal supports OSC 8 hyperlinks
+	styledUl     bool     // terminal supports styled and colored underlines
+	sgrDirect    bool     // styles are changed with sgrDiff
+	sgrAttrs     AttrMask // attributes that sgrDiff may send
+	sixel        bool     // terminal supports Sixel graphics
+	sixelColors  int      // number of Sixel color registers
+	kittyGfx     bool     // terminal supports the kitty graphics protocol
+	clipch       chan []byte
+	modeHandlers map[int]func(modeState) // for DECRQM replies, by mode
+	colorch      chan colorReport
+	colorQueries map[string]colorQuery // color queries not yet answered, by OSC code
+	fgColor      Color                 // foreground color reported by the terminal
+	bgColor      Color                 // background color reported by the terminal
+	enterTitle   string
+	enterIcon    string
+	exitTitle    string
+	cursorStyle  CursorStyle // requested cursor style
+	curCurStyle  CursorStyle // cursor style last sent to the terminal
+	ticker       ticker
+	resizeDelay  time.Duration
+	bellMode     BellMode
+	bellTime     time.Duration
+	flashes      int  // counts flashes, so only the last one ends
+	flashing     bool // screen is reversed by flash
+	opts         ScreenOptions
 
 	sync.Mutex
 }
@@ -137,16 +244,19 @@ func (t *tScreen) Init() error {
 	if e := t.initialize(); e != nil {
 		return e
 	}
+	t.notifyWinSize()
 
-	t.evch = make(chan Event, 10)
+	t.evch = make(chan Event, t.opts.eventQueueSize())
 	t.keychan = make(chan []byte, 10)
-	t.keytimer = time.NewTimer(time.Millisecond * 50)
+	t.keytimer = time.NewTimer(t.escapeTimeout())
+	t.buf.Grow(t.opts.writeBufferSize())
 	t.charset = "UTF-8"
 
 	t.charset = getCharset()
 	if enc := GetEncoding(t.charset); enc != nil {
 		t.encoder = enc.NewEncoder()
 		t.decoder = enc.NewDecoder()
+		t.asciiSafe = encodesASCII(t.encoder)
 	} else {
 		return ErrNoCharset
 	}
@@ -161,21 +271,31 @@ func (t *tScreen) Init() error {
 	if i, _ := strconv.Atoi(os.Getenv("COLUMNS")); i != 0 {
 		w = i
 	}
-	if t.ti.SetFgBgRGB != "" || t.ti.SetFgRGB != "" || t.ti.SetBgRGB != "" {
-		t.truecolor = true
-	}
+	t.depth = detectColorDepth(t.ti, os.Getenv("COLORTERM"))
 	// A user who wants to have his themes honored can
 	// set this environment variable.
-	if os.Getenv("TCELL_TRUECOLOR") == "disable" {
-		t.truecolor = false
+	if os.Getenv("TCELL_TRUECOLOR") == "disable" && t.depth == ColorDepthTrue {
+		t.depth = ColorDepth8
+		if t.ti.Colors >= 256 {
+			t.depth = ColorDepth256
+		}
+	}
+	if t.depth == ColorDepthTrue &&
+		(t.ti.SetFgBgRGB != "" || t.ti.SetFgRGB != "" || t.ti.SetBgRGB != "") {
+		t.truecolor = true
 	}
+	t.prepareSGR()
+	t.sixel = hasSixelProgram()
+	t.sixelColors = 256
+	t.kittyGfx = hasKittyGraphics(t.driver.GetTerm())
+	t.multiplexer = detectMultiplexer()
 	t.colors = make(map[Color]Color)
-	t.palette = make([]Color, t.nColors())
 	for i := 0; i < t.nColors(); i++ {
-		t.palette[i] = Color(i) | ColorValid
 		// identity map for our builtin colors
 		t.colors[Color(i)|ColorValid] = Color(i) | ColorValid
 	}
+	t.fgColors = make(map[Color]Color)
+	t.bgColors = make(map[Color]Color)
 
 	t.quit = make(chan struct{})
 
@@ -289,17 +409,28 @@ func (t *tScreen) prepareXtermModifiers() {
 	t.prepareKeyModXTerm(KeyF12, t.ti.KeyF12)
 }
 
+// xtermLike reports whether we take the terminal to follow XTerm, which
+// we judge by its having XTerm style mouse reporting.  Terminfo has
+// nothing to say about most of what modern terminals do beyond it, such
+// as bracketed paste, focus reporting, cursor styles, hyperlinks, or
+// answering queries, so these are only used with such terminals.  They
+// either support them, or are modern enough to silently discard
+// sequences they do not understand.
+func (t *tScreen) xtermLike() bool {
+	return t.ti.Mouse != ""
+}
+
 func (t *tScreen) prepareBracketedPaste() {
 	// Another workaround for lack of reporting in terminfo.
-	// We assume if the terminal has a mouse entry, that it
-	// offers bracketed paste.  But we allow specific overrides
-	// via our terminal database.
+	// We assume that an XTerm like terminal offers bracketed
+	// paste.  But we allow specific overrides via our terminal
+	// database.
 	if t.ti.EnablePaste != "" {
 		t.enablePaste = t.ti.EnablePaste
 		t.disablePaste = t.ti.DisablePaste
 		t.prepareKey(keyPasteStart, t.ti.PasteStart)
 		t.prepareKey(keyPasteEnd, t.ti.PasteEnd)
-	} else if t.ti.Mouse != "" {
+	} else if t.xtermLike() {
 		t.enablePaste = "\x1b[?2004h"
 		t.disablePaste = "\x1b[?2004l"
 		t.prepareKey(keyPasteStart, "\x1b[200~")
@@ -307,6 +438,16 @@ func (t *tScreen) prepareBracketedPaste() {
 	}
 }
 
+func (t *tScreen) prepareFocusReporting() {
+	// Terminfo has nothing to say about focus reporting either.
+	if t.xtermLike() {
+		t.enableFocus = "\x1b[?1004h"
+		t.disableFocus = "\x1b[?1004l"
+		t.prepareKey(keyFocusIn, "\x1b[I")
+		t.prepareKey(keyFocusOut, "\x1b[O")
+	}
+}
+
 func (t *tScreen) prepareKey(key Key, val string) {
 	t.prepareKeyMod(key, ModNone, val)
 }
@@ -446,6 +587,7 @@ func (t *tScreen) prepareKeys() {
 	t.prepareKey(keyPasteEnd, ti.PasteEnd)
 	t.prepareXtermModifiers()
 	t.prepareBracketedPaste()
+	t.prepareFocusReporting()
 
 outer:
 	// Add key mappings for control keys.
@@ -479,6 +621,7 @@ func (t *tScreen) Fini() {
 
 func (t *tScreen) finish() {
 	close(t.quit)
+	t.restoreColumns()
 	t.finalize()
 }
 
@@ -502,6 +645,22 @@ func (t *tScreen) Fill(r rune, style Style) {
 	t.Unlock()
 }
 
+func (t *tScreen) CopyRegion(srcX, srcY, dstX, dstY, w, h int) {
+	t.Lock()
+	if !t.fini {
+		t.cells.CopyRegion(srcX, srcY, dstX, dstY, w, h)
+	}
+	t.Unlock()
+}
+
+func (t *tScreen) FillRegion(x, y, w, h int, r rune, style Style) {
+	t.Lock()
+	if !t.fini {
+		t.cells.FillRegion(x, y, w, h, r, style)
+	}
+	t.Unlock()
+}
+
 func (t *tScreen) SetContent(x, y int, mainc rune, combc []rune, style Style) {
 	t.Lock()
 	if !t.fini {
@@ -510,6 +669,16 @@ func (t *tScreen) SetContent(x, y int, mainc rune, combc []rune, style Style) {
 	t.Unlock()
 }
 
+func (t *tScreen) SetCells(cells []CellUpdate) {
+	t.Lock()
+	if !t.fini {
+		for _, c := range cells {
+			t.cells.SetContent(c.X, c.Y, c.R, c.Combining, c.Style)
+		}
+	}
+	t.Unlock()
+}
+
 func (t *tScreen) GetContent(x, y int) (rune, []rune, Style, int) {
 	t.Lock()
 	mainc, combc, style, width := t.cells.GetContent(x, y)
@@ -525,6 +694,25 @@ func (t *tScreen) SetCell(x, y int, style Style, ch ...rune) {
 	}
 }
 
+// encodesASCII reports whether enc leaves the printable ASCII characters
+// as they are, which is true of all but the most exotic character sets.
+// If so, they can be sent without using the encoder at all.
+func encodesASCII(enc transform.Transformer) bool {
+	if enc == nil {
+		return false
+	}
+	var src, dst [1]byte
+	for c := byte(' '); c < 0x7f; c++ {
+		src[0] = c
+		enc.Reset()
+		n, _, err := enc.Transform(dst[:], src[:], true)
+		if err != nil || n != 1 || dst[0] != c {
+			return false
+		}
+	}
+	return true
+}
+
 func (t *tScreen) encodeRune(r rune, buf []byte) []byte {
 
 	nb := make([]byte, 6)
@@ -555,6 +743,40 @@ func (t *tScreen) encodeRune(r rune, buf []byte) []byte {
 	return buf
 }
 
+// paletteFg returns the palette color to use for the foreground color fg.
+// Colors are kept apart from the terminal's own background color, if
+// we know it, so that text stays readable.
+func (t *tScreen) paletteFg(fg Color) Color {
+	v, ok := t.fgColors[fg]
+	if !ok {
+		v = nearestDistinctColor(fg, ColorDepth(t.nColors()), t.bgColor)
+		t.fgColors[fg] = v
+	}
+	return v
+}
+
+// paletteBg returns the palette color to use for the background color
+// bg, which is kept apart from the terminal's own foreground color.
+func (t *tScreen) paletteBg(bg Color) Color {
+	v, ok := t.bgColors[bg]
+	if !ok {
+		v = nearestDistinctColor(bg, ColorDepth(t.nColors()), t.fgColor)
+		t.bgColors[bg] = v
+	}
+	return v
+}
+
+// paletteColor returns the palette color nearest to c, as used for
+// underline colors.
+func (t *tScreen) paletteColor(c Color) Color {
+	v, ok := t.colors[c]
+	if !ok {
+		v = NearestColor(c, ColorDepth(t.nColors()))
+		t.colors[c] = v
+	}
+	return v
+}
+
 func (t *tScreen) sendFgBg(fg Color, bg Color) {
 	ti := t.ti
 	if ti.Colors == 0 {
@@ -588,23 +810,10 @@ func (t *tScreen) sendFgBg(fg Color, bg Color) {
 	}
 
 	if fg.Valid() {
-		if v, ok := t.colors[fg]; ok {
-			fg = v
-		} else {
-			v = FindColor(fg, t.palette)
-			t.colors[fg] = v
-			fg = v
-		}
+		fg = t.paletteFg(fg)
 	}
-
 	if bg.Valid() {
-		if v, ok := t.colors[bg]; ok {
-			bg = v
-		} else {
-			v = FindColor(bg, t.palette)
-			t.colors[bg] = v
-			bg = v
-		}
+		bg = t.paletteBg(bg)
 	}
 
 	if fg.Valid() && bg.Valid() && ti.SetFgBg != "" {
@@ -619,11 +828,91 @@ func (t *tScreen) sendFgBg(fg Color, bg Color) {
 	}
 }
 
+// colorsOnly reports whether the terminal can be taken from the current
+// style to style by changing just the colors, leaving the other
+// attributes alone.  sgrDiff does this where styles are sent with SGR
+// directly, so this is for the other terminals, which need terminfo's
+// op capability to go back to the default colors.
+func (t *tScreen) colorsOnly(style Style) bool {
+	cur := t.curstyle
+	if t.sgrDirect || t.ti.Colors == 0 || cur == styleInvalid {
+		return false
+	}
+	if style.attrs != cur.attrs || style.ulStyle != cur.ulStyle ||
+		style.ulColor != cur.ulColor {
+		return false
+	}
+	return t.ti.ResetFgBg != "" || !t.colorsToDefault(style.fg, style.bg)
+}
+
+// colorsToDefault reports whether changing from the colors of the current
+// style to fg and bg takes either of them back to the default.
+func (t *tScreen) colorsToDefault(fg, bg Color) bool {
+	cur := t.curstyle
+	return (!fg.Valid() && cur.fg.Valid()) || (!bg.Valid() && cur.bg.Valid())
+}
+
+// sendColors changes the colors from those of the current style to fg
+// and bg, where colorsOnly allows it.
+func (t *tScreen) sendColors(fg, bg Color) {
+	cur := t.curstyle
+	if t.colorsToDefault(fg, bg) {
+		// This restores both, so the other is set again if need be.
+		t.TPuts(t.ti.ResetFgBg)
+	} else {
+		if fg == cur.fg {
+			fg = ColorDefault
+		}
+		if bg == cur.bg {
+			bg = ColorDefault
+		}
+	}
+	// ColorReset is the same as the default on the screen, and has been
+	// taken care of.
+	if !fg.Valid() {
+		fg = ColorDefault
+	}
+	if !bg.Valid() {
+		bg = ColorDefault
+	}
+	t.sendFgBg(fg, bg)
+}
+
+// sgrFor returns the state that the terminal is to be in to show style,
+// with its colors fitted to those the terminal has.
+func (t *tScreen) sgrFor(style Style) sgrState {
+	st := sgrState{attrs: style.attrs & t.sgrAttrs}
+	if t.ti.Colors != 0 {
+		if style.fg.Valid() {
+			st.fg = style.fg
+			if !t.truecolor || !st.fg.IsRGB() {
+				st.fg = t.paletteFg(st.fg)
+			}
+		}
+		if style.bg.Valid() {
+			st.bg = style.bg
+			if !t.truecolor || !st.bg.IsRGB() {
+				st.bg = t.paletteBg(st.bg)
+			}
+		}
+	}
+	if st.attrs&AttrUnderline != 0 && t.styledUl {
+		st.ulStyle = style.ulStyle
+		if t.ti.Colors != 0 && style.ulColor.Valid() {
+			st.ulColor = style.ulColor
+			if !t.truecolor || !st.ulColor.IsRGB() {
+				st.ulColor = t.paletteColor(st.ulColor)
+			}
+		}
+	}
+	return st
+}
+
 func (t *tScreen) drawCell(x, y int) int {
 
 	ti := t.ti
 
-	mainc, combc, style, width := t.cells.GetContent(x, y)
+	mainc, combc, style, width := t.cells.getContent(x, y)
 	if !t.cells.Dirty(x, y) {
 		return width
 	}
@@ -637,6 +926,31 @@ func (t *tScreen) drawCell(x, y int) int {
 	if style == StyleDefault {
 		style = t.style
 	}
+	if style != t.curstyle && t.sgrDirect {
+		// Attributes can be turned off one at a time, so only what
+		// changed needs to be sent.
+		if t.curstyle == styleInvalid {
+			t.TPuts(ti.AttrOff)
+			t.sgr = sgrState{}
+		}
+		st := t.sgrFor(style)
+		t.writeBytes(sgrDiff(t.sgr, st))
+		t.sgr = st
+		if style.url != t.curstyle.url && t.hyperlinks {
+			t.sendURL(style.url)
+		}
+		t.curstyle = style
+	}
+	if style != t.curstyle && t.colorsOnly(style) {
+		// Only the colors changed, so change just those, rather than
+		// resetting everything and having to set bold and so forth
+		// all over again.
+		t.sendColors(style.fg, style.bg)
+		if style.url != t.curstyle.url && t.hyperlinks {
+			t.sendURL(style.url)
+		}
+		t.curstyle = style
+	}
 	if style != t.curstyle {
 		fg, bg, attrs := style.Decompose()
 
@@ -647,7 +961,7 @@ func (t *tScreen) drawCell(x, y int) int {
 			t.TPuts(ti.Bold)
 		}
 		if attrs&AttrUnderline != 0 {
-			t.TPuts(ti.Underline)
+			t.sendUnderline(style.ulStyle, style.ulColor)
 		}
 		if attrs&AttrReverse != 0 {
 			t.TPuts(ti.Reverse)
@@ -664,6 +978,17 @@ func (t *tScreen) drawCell(x, y int) int {
 		if attrs&AttrStrikeThrough != 0 {
 			t.TPuts(ti.StrikeThrough)
 		}
+		if attrs&AttrOverline != 0 && t.styledUl {
+			// There is no terminfo capability for overline, but
+			// the AttrOff above clears it just like the others.
+			t.TPuts(sgrOverline)
+		}
+		if attrs&AttrInvisible != 0 {
+			t.TPuts(ti.Invisible)
+		}
+		if style.url != t.curstyle.url && t.hyperlinks {
+			t.sendURL(style.url)
+		}
 		t.curstyle = style
 	}
 	// now emit runes - taking care to not overrun width with a
@@ -674,6 +999,15 @@ func (t *tScreen) drawCell(x, y int) int {
 		width = 1
 	}
 
+	// Printable ASCII, which is most text, can be written as it is,
+	// without any need to allocate memory for it.
+	if mainc >= ' ' && mainc < 0x7f && len(combc) == 0 && t.asciiSafe && t.buffering {
+		t.buf.WriteByte(byte(mainc))
+		t.cx++
+		t.cells.SetDirty(x, y, false)
+		return 1
+	}
+
 	var str string
 
 	buf := make([]byte, 0, 6)
@@ -705,10 +1039,327 @@ func (t *tScreen) drawCell(x, y int) int {
 	return width
 }
 
+// drawRepeats draws the dirty cells that follow (x, y), and that repeat
+// the character just drawn there in the same style, all at once.  The
+// REP sequence is used for this where the terminal has it, and is shorter
+// than writing out the characters, which avoids sending long runs of the
+// same character, such as a background, one by one.  It returns the
+// number of cells that were drawn.
+func (t *tScreen) drawRepeats(x, y int) int {
+	mainc, combc, style, width := t.cells.getContent(x, y)
+	if width != 1 || len(combc) != 0 || t.cx != x+1 || t.cy != y {
+		return 0
+	}
+	n := 0
+	for nx := x + 1; nx < t.w && t.cells.Dirty(nx, y); nx++ {
+		m, c, s, w := t.cells.getContent(nx, y)
+		if m != mainc || len(c) != 0 || s != style || w != 1 {
+			break
+		}
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+
+	// Only characters that are sent as a single graphic character can be
+	// repeated, not ones that need the alternate character set, or that
+	// are replaced with a multi-character fallback.
+	enc := t.encodeRune(mainc, nil)
+	if mainc < ' ' || mainc == 0x7f || len(enc) == 0 ||
+		bytes.IndexByte(enc, '\x1b') >= 0 || utf8.RuneCount(enc) != 1 {
+		return 0
+	}
+
+	rep := ""
+	if t.ti.RepeatChar != "" {
+		// The capability sends the character once itself, before
+		// repeating it, but that has already been done.
+		s := t.ti.TParm(t.ti.RepeatChar, int(mainc), n+1)
+		if strings.HasPrefix(s, string(enc)) {
+			rep = s[len(enc):]
+		}
+	}
+	if rep != "" && len(rep) < len(enc)*n {
+		t.TPuts(rep)
+	} else {
+		t.writeString(strings.Repeat(string(enc), n))
+	}
+	for i := 1; i <= n; i++ {
+		t.cells.SetDirty(x+i, y, false)
+	}
+	t.cx += n
+	return n
+}
+
+// deccolm returns the DECCOLM sequence for switching to n columns, which
+// may be 80 or 132.  XTerm only honors it after mode 40 is set, which
+// other terminals ignore.
+func deccolm(n int) (string, error) {
+	switch n {
+	case 80:
+		return "\x1b[?40h\x1b[?3l", nil
+	case 132:
+		return "\x1b[?40h\x1b[?3h", nil
+	}
+	return "", ErrUnsupported
+}
+
+// allowColumnsMode is the XTerm mode (40) that lets DECCOLM change the
+// number of columns.
+const allowColumnsMode = 40
+
+// resizeColumns asks the terminal to change its width to n columns, for
+// widths that DECCOLM cannot give, using XTWINOPS.
+func resizeColumns(n int) string {
+	return "\x1b[8;;" + strconv.Itoa(n) + "t"
+}
+
+// columnsTries and columnsWait say how long SetColumns waits for the
+// terminal to change its width.
+const (
+	columnsTries = 10
+	columnsWait  = 20 * time.Millisecond
+)
+
+// sgrOverline starts overlined text.  There is no terminfo capability
+// for it, but the terminals that advertise styled underlines know it.
+const sgrOverline = "\x1b[53m"
+
+// prepareSGR determines whether styles can be changed with sgrDiff,
+// which turns attributes off one at a time, and so only sends what
+// changed.  terminfo has no capabilities for most of the sequences it
+// uses, so this is only done for XTerm like terminals (see xtermLike)
+// whose own sequences for the attributes and colors they have are exactly the SGR sequences sgrDiff would send.  Attributes the
+// terminal has no sequence for are left out, as they are otherwise.
+func (t *tScreen) prepareSGR() {
+	ti := t.ti
+	t.sgrDirect = false
+	t.sgrAttrs = AttrNone
+	if !t.xtermLike() ||
+		!strings.Contains(ti.AttrOff, "\x1b[m") && !strings.Contains(ti.AttrOff, "\x1b[0m") {
+		return
+	}
+	attrs := AttrNone
+	for _, a := range []struct {
+		attr     AttrMask
+		cap, sgr string
+	}{
+		{AttrBold, ti.Bold, "\x1b[1m"},
+		{AttrDim, ti.Dim, "\x1b[2m"},
+		{AttrItalic, ti.Italic, "\x1b[3m"},
+		{AttrUnderline, ti.Underline, "\x1b[4m"},
+		{AttrBlink, ti.Blink, "\x1b[5m"},
+		{AttrReverse, ti.Reverse, "\x1b[7m"},
+		{AttrInvisible, ti.Invisible, "\x1b[8m"},
+		{AttrStrikeThrough, ti.StrikeThrough, "\x1b[9m"},
+	} {
+		switch a.cap {
+		case "":
+		case a.sgr:
+			attrs |= a.attr
+		default:
+			return
+		}
+	}
+	if t.styledUl {
+		attrs |= AttrOverline
+	}
+	for _, c := range []struct {
+		n      int
+		fg, bg string
+	}{
+		{1, "\x1b[31m", "\x1b[41m"},
+		{9, "\x1b[91m", "\x1b[101m"},
+		{100, "\x1b[38;5;100m", "\x1b[48;5;100m"},
+	} {
+		if c.n < ti.Colors &&
+			(ti.TParm(ti.SetFg, c.n) != c.fg || ti.TParm(ti.SetBg, c.n) != c.bg) {
+			return
+		}
+	}
+	if t.truecolor && (ti.TParm(ti.SetFgRGB, 1, 2, 3) != "\x1b[38;2;1;2;3m" ||
+		ti.TParm(ti.SetBgRGB, 1, 2, 3) != "\x1b[48;2;1;2;3m") {
+		return
+	}
+	t.sgrDirect = true
+	t.sgrAttrs = attrs
+}
+
+// prepareUnderlines determines whether styled (curly, dotted, dashed)
+// and colored underlines can be used.  These are advertised with the Su
+// terminfo extension, but few terminal descriptions carry it, so we also
+// accept a direct color COLORTERM from XTerm like terminals, as the
+// terminals that set it generally support these too.
+func (t *tScreen) prepareUnderlines() {
+	if t.ti.StyledUnderline {
+		t.styledUl = true
+	} else if t.xtermLike() {
+		switch os.Getenv("COLORTERM") {
+		case "truecolor", "24bit":
+			t.styledUl = true
+		}
+	}
+}
+
+// sendUnderline starts an underline of the given style and color.
+// Terminals without styled underline support just get a plain underline.
+func (t *tScreen) sendUnderline(ul int, c Color) {
+	if !t.styledUl {
+		t.TPuts(t.ti.Underline)
+		return
+	}
+	if ul == ulSolid {
+		t.TPuts(t.ti.Underline)
+	} else {
+		t.TPuts("\x1b[4:" + strconv.Itoa(ul) + "m")
+	}
+	if !c.Valid() || t.ti.Colors == 0 {
+		return
+	}
+	if t.truecolor && c.IsRGB() {
+		r, g, b := c.RGB()
+		t.TPuts("\x1b[58:2::" + strconv.Itoa(int(r)) + ":" +
+			strconv.Itoa(int(g)) + ":" + strconv.Itoa(int(b)) + "m")
+		return
+	}
+	c = t.paletteColor(c)
+	t.TPuts("\x1b[58:5:" + strconv.Itoa(int(c&^ColorValid)) + "m")
+}
+
+// prepareHyperlinks determines whether OSC 8 hyperlinks can be used.
+// There is no terminfo capability for these, so they are used with XTerm
+// like terminals.
+func (t *tScreen) prepareHyperlinks() {
+	if t.xtermLike() {
+		t.hyperlinks = true
+	}
+}
+
+// sendURL starts a hyperlink to the given URL, or ends the current
+// hyperlink if the URL is empty.
+func (t *tScreen) sendURL(url string) {
+	t.writeString("\x1b]8;;" + stripControls(url) + "\x1b\\")
+}
+
+// stripControls removes control characters from a string that is going
+// to be embedded in an escape sequence, where they could otherwise be used
+// to terminate the sequence early.
+func stripControls(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || (r >= 0x7f && r < 0xa0) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// prepareTitles determines how to set the window and icon titles, which
+// is with the status line capabilities.  Without them, the titles are
+// not set, rather than risk sending sequences the terminal will show.
+func (t *tScreen) prepareTitles() {
+	if t.ti.ToStatusLine != "" && t.ti.FromStatusLine != "" {
+		t.enterTitle = t.ti.TParm(t.ti.ToStatusLine, 0)
+		t.exitTitle = t.ti.FromStatusLine
+	}
+	if strings.HasPrefix(t.enterTitle, "\x1b]") {
+		t.enterIcon = "\x1b]1;"
+	}
+}
+
+func (t *tScreen) SetTitle(title string) {
+	t.Lock()
+	if !t.fini && t.enterTitle != "" {
+		t.TPuts(t.enterTitle)
+		t.writeString(stripControls(title))
+		t.TPuts(t.exitTitle)
+	}
+	t.Unlock()
+}
+
+func (t *tScreen) SetIconTitle(title string) {
+	t.Lock()
+	if !t.fini && t.enterIcon != "" {
+		t.TPuts(t.enterIcon)
+		t.writeString(stripControls(title))
+		t.TPuts(t.exitTitle)
+	}
+	t.Unlock()
+}
+
+func (t *tScreen) SetColumns(n int) error {
+	seq, err := deccolm(n)
+	if err != nil {
+		return err
+	}
+	// The first time, note how the terminal was, so that Fini can put
+	// it back.  Terminals that do not answer are taken to have mode 40
+	// reset, as XTerm does by default.
+	t.Lock()
+	saved := t.origCols != 0
+	t.Unlock()
+	var mode40 modeState
+	if !saved {
+		mode40, _ = t.queryMode(allowColumnsMode)
+	}
+	t.Lock()
+	defer t.Unlock()
+	if t.fini {
+		return ErrNoCapability
+	}
+	if t.origCols == 0 {
+		t.origCols = t.w
+		t.origMode40 = mode40
+	}
+	t.TPuts(seq)
+	t.cx = -1
+	t.cy = -1
+	t.clear = true
+	t.cells.Invalidate()
+	// the screen is drawn by the next Show, but in a transaction
+	// that has to be a Sync
+	t.batch.hold(true)
+
+	// The terminal resizes itself some time after it gets the request,
+	// so we look for the new width for a little while.
+	for i := 0; i < columnsTries; i++ {
+		if w, _, e := t.getWinSize(); e == nil && w == n {
+			t.resize()
+			return nil
+		}
+		t.Unlock()
+		time.Sleep(columnsWait)
+		t.Lock()
+	}
+	return ErrNoCapability
+}
+
+// restoreColumns puts the terminal back to the width it had, and the
+// state of mode 40, before SetColumns was first called.
+func (t *tScreen) restoreColumns() {
+	t.Lock()
+	defer t.Unlock()
+	if t.origCols == 0 {
+		return
+	}
+	if t.w != t.origCols {
+		if seq, err := deccolm(t.origCols); err == nil {
+			t.TPuts(seq)
+		} else {
+			t.TPuts(resizeColumns(t.origCols))
+		}
+	}
+	if t.origMode40 != modeSet && t.origMode40 != modePermanentlySet {
+		t.TPuts("\x1b[?40l")
+	}
+	t.origCols = 0
+}
+
 func (t *tScreen) ShowCursor(x, y int) {
 	t.Lock()
 	t.cursorx = x
 	t.cursory = y
+	t.softCursor.on = false
 	t.Unlock()
 }
 
@@ -716,6 +1367,29 @@ func (t *tScreen) HideCursor() {
 	t.ShowCursor(-1, -1)
 }
 
+func (t *tScreen) SetSoftCursor(x, y int, style Style, r rune) {
+	t.Lock()
+	t.cursorx, t.cursory = -1, -1
+	t.softCursor = softCursor{on: true, x: x, y: y, style: style, r: r}
+	t.Unlock()
+}
+
+func (t *tScreen) SetCursorStyle(cs CursorStyle) {
+	t.Lock()
+	t.cursorStyle = cs
+	t.Unlock()
+}
+
+// sendCursorStyle sends the cursor style to the terminal using DECSCUSR,
+// if it has changed.  There is no standard terminfo capability for this,
+// so it is only sent to XTerm like terminals.
+func (t *tScreen) sendCursorStyle(cs CursorStyle) {
+	if cs != t.curCurStyle && t.xtermLike() {
+		t.TPuts("\x1b[" + strconv.Itoa(int(cs)) + " q")
+		t.curCurStyle = cs
+	}
+}
+
 func (t *tScreen) showCursor() {
 
 	x, y := t.cursorx, t.cursory
@@ -725,11 +1399,21 @@ func (t *tScreen) showCursor() {
 		return
 	}
 	t.TPuts(t.ti.TGoto(x, y))
+	t.sendCursorStyle(t.cursorStyle)
 	t.TPuts(t.ti.ShowCursor)
 	t.cx = x
 	t.cy = y
 }
 
+// writeBytes is like writeString, for a byte slice.
+func (t *tScreen) writeBytes(b []byte) {
+	if t.buffering {
+		_, _ = t.buf.Write(b)
+	} else {
+		_ = t.send(b)
+	}
+}
+
 // writeString sends a string to the terminal. The string is sent as-is and
 // this function does not expand inline padding indications (of the form
 // $<[delay]> where [delay] is msec). In order to have these expanded, use
@@ -740,7 +1424,7 @@ func (t *tScreen) writeString(s string) {
 	if t.buffering {
 		_, _ = io.WriteString(&t.buf, s)
 	} else {
-		_, _ = io.WriteString(t.out, s)
+		_ = t.send([]byte(s))
 	}
 }
 
@@ -748,23 +1432,84 @@ func (t *tScreen) TPuts(s string) {
 	if t.buffering {
 		t.ti.TPuts(&t.buf, s)
 	} else {
-		t.ti.TPuts(t.out, s)
+		t.ti.TPuts(t.outw, s)
+		_ = t.outw.Flush()
+	}
+}
+
+// send writes b to the terminal through the write buffer, and flushes it
+// once at the end.  The buffer is fed no more than it has room for, as
+// bufio passes larger writes straight through, so that a large frame or
+// image is written in pieces of the buffer's size, not in one huge write.
+func (t *tScreen) send(b []byte) error {
+	size := t.outw.Size()
+	for len(b) > 0 {
+		n := t.outw.Available()
+		if n == 0 {
+			n = size // it flushes first
+		}
+		if n > len(b) {
+			n = len(b)
+		}
+		if _, err := t.outw.Write(b[:n]); err != nil {
+			return err
+		}
+		b = b[n:]
 	}
+	return t.outw.Flush()
 }
 
 func (t *tScreen) Show() {
 	t.Lock()
-	if !t.fini {
-		t.resize()
-		t.draw()
+	defer t.Unlock()
+	if t.batch.hold(false) || t.fini {
+		return
 	}
-	t.Unlock()
+	// Too soon after the last frame, so draw this one when the frame
+	// time is up, taking in whatever else is shown before then.
+	if wait := t.frameTime - time.Since(t.lastFrame); wait > 0 {
+		if !t.framePending {
+			t.framePending = true
+			time.AfterFunc(wait, t.showPending)
+		}
+		return
+	}
+	t.showFrame()
+}
+
+// showPending draws a frame that Show put off to keep to the frame rate,
+// unless something else has drawn it already.
+func (t *tScreen) showPending() {
+	t.Lock()
+	defer t.Unlock()
+	if !t.framePending {
+		return
+	}
+	t.framePending = false
+	if t.batch.hold(false) || t.fini {
+		return
+	}
+	t.showFrame()
+}
+
+// showFrame draws the changes to the screen.  It is called with the lock
+// held.
+func (t *tScreen) showFrame() {
+	t.lastFrame = time.Now()
+	t.framePending = false
+	t.resize()
+	t.draw()
 }
 
 func (t *tScreen) clearScreen() {
+	// The terminal clears with the current background color, so set
+	// the colors of the default style, without any attributes.  The
+	// style of the next cell drawn must then be sent in full.
 	fg, bg, _ := t.style.Decompose()
+	t.TPuts(t.ti.AttrOff)
 	t.sendFgBg(fg, bg)
 	t.TPuts(t.ti.Clear)
+	t.curstyle = styleInvalid
 	t.clear = false
 }
 
@@ -781,6 +1526,20 @@ func (t *tScreen) hideCursor() {
 }
 
 func (t *tScreen) draw() {
+	// the soft cursor is drawn as part of the cells
+	defer t.softCursor.apply(&t.cells)()
+
+	// only the part of the screen that has changed needs to be scanned
+	damage := t.cells.takeDamage()
+	if t.clear {
+		damage = image.Rect(0, 0, t.w, t.h)
+	}
+	t.drawRect(damage)
+}
+
+// drawRect sends the cells within r that need drawing to the terminal,
+// as one frame.  Cells outside of r are left to be drawn another time.
+func (t *tScreen) drawRect(damage image.Rectangle) {
 	// clobber cursor position, because we're gonna change it all
 	t.cx = -1
 	t.cy = -1
@@ -791,6 +1550,12 @@ func (t *tScreen) draw() {
 		t.buffering = false
 	}()
 
+	// let the terminal know that a frame is starting, so that it can
+	// hold off on updating the display until we are done
+	if t.syncOutput {
+		t.TPuts(syncOutputBegin)
+	}
+
 	// hide the cursor while we move stuff around
 	t.hideCursor()
 
@@ -798,9 +1563,27 @@ func (t *tScreen) draw() {
 		t.clearScreen()
 	}
 
-	for y := 0; y < t.h; y++ {
-		for x := 0; x < t.w; x++ {
+	for y := damage.Min.Y; y < damage.Max.Y; y++ {
+		// Start at the damage, unless that is the right half of a
+		// wide character, in which case start with the character.
+		x0 := damage.Min.X
+		if _, _, _, width := t.cells.getContent(x0, y); width == 0 && x0 > 0 {
+			x0--
+		}
+		for x := x0; x < damage.Max.X; x++ {
+			if x < damage.Min.X {
+				// step over the undamaged cells, without drawing,
+				// so that wide characters are treated as before
+				_, _, _, width := t.cells.getContent(x, y)
+				if width > 1 {
+					x += width - 1
+				}
+				continue
+			}
 			width := t.drawCell(x, y)
+			if width == 1 {
+				x += t.drawRepeats(x, y)
+			}
 			if width > 1 {
 				if x+1 < t.w {
 					// this is necessary so that if we ever
@@ -813,11 +1596,331 @@ func (t *tScreen) draw() {
 		}
 	}
 
+	// don't leave a hyperlink open, where it could apply to
+	// text written by somebody else
+	if t.curstyle.url != "" {
+		t.sendURL("")
+		t.curstyle.url = ""
+	}
+
 	// restore the cursor
 	t.showCursor()
 
-	_, _ = t.buf.WriteTo(t.out)
-}
+	if t.syncOutput {
+		t.TPuts(syncOutputEnd)
+	}
+
+	_ = t.send(t.buf.Bytes())
+	t.buf.Reset()
+}
+
+// modeState is the state of a private mode, as given by the terminal's
+// reply to DECRQM.
+type modeState int
+
+const (
+	modeNotRecognized    modeState = 0
+	modeSet              modeState = 1
+	modeReset            modeState = 2
+	modePermanentlySet   modeState = 3
+	modePermanentlyReset modeState = 4
+)
+
+// modeQuery returns the DECRQM query for the given private mode.
+func modeQuery(mode int) string {
+	return "\x1b[?" + strconv.Itoa(mode) + "$p"
+}
+
+// onModeReport arranges for f to be called, with the lock held, with the
+// state in each reply to DECRQM for the given mode.  There is only one
+// function for each mode, and a nil f removes it.
+func (t *tScreen) onModeReport(mode int, f func(modeState)) {
+	if f == nil {
+		delete(t.modeHandlers, mode)
+		return
+	}
+	if t.modeHandlers == nil {
+		t.modeHandlers = make(map[int]func(modeState))
+	}
+	t.modeHandlers[mode] = f
+}
+
+// modeQueryTimeout is how long we wait for the terminal to answer a
+// DECRQM query.  Terminals that do not support it never answer.
+const modeQueryTimeout = 200 * time.Millisecond
+
+// queryMode asks the terminal for the state of the given private mode,
+// using DECRQM, and waits for the reply.  This must not be called with
+// the lock held, and only while engaged, as the reply is read by the
+// input loop.  It returns ErrNoCapability if the terminal does not
+// answer.
+func (t *tScreen) queryMode(mode int) (modeState, error) {
+	t.Lock()
+	if !t.xtermLike() || t.fini || t.stopQ == nil {
+		t.Unlock()
+		return modeNotRecognized, ErrNoCapability
+	}
+	// Anything already waiting for this mode still gets the reply.
+	ch := make(chan modeState, 1)
+	prev := t.modeHandlers[mode]
+	t.onModeReport(mode, func(state modeState) {
+		if prev != nil {
+			prev(state)
+		}
+		select {
+		case ch <- state:
+		default:
+		}
+	})
+	t.writeString(modeQuery(mode))
+	t.Unlock()
+
+	defer func() {
+		t.Lock()
+		t.onModeReport(mode, prev)
+		t.Unlock()
+	}()
+	select {
+	case state := <-ch:
+		return state, nil
+	case <-t.quit:
+		return modeNotRecognized, ErrNoCapability
+	case <-time.After(modeQueryTimeout):
+		return modeNotRecognized, ErrNoCapability
+	}
+}
+
+// Synchronized output (DEC private mode 2026) lets us tell the terminal
+// where a frame begins and ends, so that partially drawn frames are never
+// displayed.  We ask the terminal whether it recognizes the mode with
+// DECRQM.
+const (
+	syncOutputMode  = 2026
+	syncOutputQuery = "\x1b[?2026$p"
+	syncOutputBegin = "\x1b[?2026h"
+	syncOutputEnd   = "\x1b[?2026l"
+)
+
+// querySyncOutput asks the terminal if it supports synchronized output.
+// This is only done once; the answer is not going to change.  As the
+// lock is held, this does not wait for the reply, and until it comes,
+// frames are drawn without synchronization.
+func (t *tScreen) querySyncOutput() {
+	if t.syncQueried || !t.xtermLike() {
+		return
+	}
+	t.syncQueried = true
+	// A mode that is recognized, and currently set or reset, can be
+	// used.  Anything else means we cannot (or should not) use it.
+	t.onModeReport(syncOutputMode, func(state modeState) {
+		t.syncOutput = state == modeSet || state == modeReset
+	})
+	t.TPuts(syncOutputQuery)
+}
+
+// Device attributes describe the terminal.  The primary (DA1) reply
+// lists the features it has, the secondary (DA2) reply identifies the
+// type of terminal and its version, and the tertiary (DA3) reply gives
+// a unit ID.  The replies are processed by parseDeviceAttrs.
+const (
+	da1Query = "\x1b[c"
+	da2Query = "\x1b[>c"
+	da3Query = "\x1b[=c"
+)
+
+// xtVersionQuery asks the terminal for its name and version, which it
+// sends as DCS > | text ST.  This is also handled by parseDeviceAttrs.
+const xtVersionQuery = "\x1b[>q"
+
+// da1Sixel is the feature in the DA1 reply that means Sixel graphics.
+const da1Sixel = 4
+
+// queryDeviceAttrs asks the terminal to describe itself.  Like
+// querySyncOutput, this is only done once.
+//
+// A multiplexer answers these itself, so we ask the terminal it is
+// running in as well, if the multiplexer lets us.  To tell the replies
+// apart, DA1 is asked of the multiplexer a second time, after the rest.
+// Once that is answered, the multiplexer has finished, and only then are
+// the queries passed through, so the replies that follow are all from the
+// terminal.  DA1 is asked last of the terminal too, to mark its end.
+func (t *tScreen) queryDeviceAttrs() {
+	if t.daQueried || !t.xtermLike() {
+		return
+	}
+	t.daQueried = true
+	t.TPuts(da1Query + da2Query + da3Query + xtVersionQuery)
+	if passthrough(t.multiplexer, da1Query) != "" {
+		t.TPuts(da1Query)
+		t.muxReplies = 2
+	}
+}
+
+// gotDA1 records a DA1 reply, and whether it is from the multiplexer or
+// the terminal that it is running in, as described for queryDeviceAttrs.
+func (t *tScreen) gotDA1(vals []int) {
+	if t.hostReplies {
+		t.hostDA1 = vals
+		t.hostReplies = false
+		return
+	}
+	if t.muxReplies > 0 {
+		if t.muxReplies--; t.muxReplies == 0 {
+			t.hostReplies = true
+			t.TPuts(passthrough(t.multiplexer, da2Query+xtVersionQuery+da1Query))
+		}
+	}
+	t.da1 = vals
+	for _, v := range vals[1:] {
+		if v == da1Sixel {
+			t.sixel = true
+			t.querySixelColors()
+		}
+	}
+}
+
+// sixelColorsQuery asks how many Sixel color registers the terminal has,
+// using XTSMGRAPHICS.  The reply, CSI ? 1 ; status ; count S, is
+// processed by parseGraphicsAttrs.
+const sixelColorsQuery = "\x1b[?1;1S"
+
+// maxSixelColors limits the color registers we use, however many the
+// terminal has, as the encoder slows down with larger palettes.
+const maxSixelColors = 1024
+
+// querySixelColors asks for the number of Sixel color registers, once
+// we know that the terminal supports Sixel graphics at all.
+func (t *tScreen) querySixelColors() {
+	if t.sixelQueried || !t.sixel || !t.xtermLike() {
+		return
+	}
+	t.sixelQueried = true
+	t.TPuts(sixelColorsQuery)
+}
+
+// parseXTVersion splits the reply to XTVERSION into the name and version
+// of the terminal.  Most terminals use the form name(version), as XTerm
+// does, but some use name version.
+func parseXTVersion(s string) (string, string) {
+	if i := strings.IndexByte(s, '('); i > 0 && strings.HasSuffix(s, ")") {
+		return s[:i], s[i+1 : len(s)-1]
+	}
+	if i := strings.IndexByte(s, ' '); i > 0 {
+		return s[:i], strings.TrimSpace(s[i+1:])
+	}
+	return s, ""
+}
+
+// parseDeviceAttrs is like parseSgrMouse, but it parses the replies to
+// the device attribute queries.  These take the form CSI ? Ps ; ... c
+// for DA1, CSI > Ps ; ... c for DA2, and DCS ! | unit ID ST for DA3.
+// The reply to XTVERSION, DCS > | text ST, is handled here too.
+func (t *tScreen) parseDeviceAttrs(buf *bytes.Buffer, evs *[]Event) (bool, bool) {
+
+	b := buf.Bytes()
+
+	var vals []int
+	var kind byte
+	val := 0
+	state := 0
+	start := 0 // of the DA3 unit ID
+
+	for i := range b {
+		switch state {
+		case 0:
+			switch b[i] {
+			case '\x1b':
+				state = 1
+			case '\x9b':
+				state = 2
+			case '\x90':
+				state = 10
+			default:
+				return false, false
+			}
+		case 1:
+			switch b[i] {
+			case '[':
+				state = 2
+			case 'P':
+				state = 10
+			default:
+				return false, false
+			}
+		case 2:
+			if b[i] != '?' && b[i] != '>' {
+				return false, false
+			}
+			kind = b[i]
+			state = 3
+		case 3:
+			switch c := b[i]; {
+			case c >= '0' && c <= '9':
+				val *= 10
+				val += int(c - '0')
+			case c == ';':
+				vals = append(vals, val)
+				val = 0
+			case c == 'c':
+				vals = append(vals, val)
+				buf.Next(i + 1)
+				switch {
+				case kind == '?':
+					t.gotDA1(vals)
+				case t.hostReplies:
+					t.hostDA2 = vals
+				default:
+					t.da2 = vals
+				}
+				return true, true
+			default:
+				return false, false
+			}
+		case 10:
+			if b[i] != '!' && b[i] != '>' {
+				return false, false
+			}
+			kind = b[i]
+			state = 11
+		case 11:
+			if b[i] != '|' {
+				return false, false
+			}
+			start = i + 1
+			state = 12
+		case 12:
+			switch c := b[i]; {
+			case c == '\x1b' || c == '\x9c':
+				end := i + 1
+				if c == '\x1b' {
+					if i+1 == len(b) {
+						return true, false
+					}
+					if b[i+1] != '\\' {
+						return false, false
+					}
+					end++
+				}
+				if kind == '!' {
+					t.da3 = string(b[start:i])
+				} else if t.hostReplies {
+					t.hostName, t.hostVersion = parseXTVersion(string(b[start:i]))
+				} else {
+					t.termName, t.termVersion = parseXTVersion(string(b[start:i]))
+				}
+				buf.Next(end)
+				return true, true
+			case kind == '>' && c >= ' ' && c < 0x7f:
+			case (c >= '0' && c <= '9') || (c >= 'A' && c <= 'F') || (c >= 'a' && c <= 'f'):
+			default:
+				return false, false
+			}
+		}
+	}
+
+	// incomplete & inconclusive at this point
+	return true, false
+}
 
 func (t *tScreen) EnableMouse(flags ...MouseFlags) {
 	var f MouseFlags
@@ -842,8 +1945,15 @@ func (t *tScreen) enableMouse(f MouseFlags) {
 	// XTerm standards (the modern ones).
 	if len(t.mouse) != 0 {
 		// start by disabling all tracking.
-		t.TPuts("\x1b[?1000l\x1b[?1002l\x1b[?1003l\x1b[?1006l")
-		if f&MouseMotionEvents != 0 {
+		t.TPuts("\x1b[?1000l\x1b[?1002l\x1b[?1003l\x1b[?1006l\x1b[?1016l")
+		// SGR-Pixels reporting uses the same records as SGR, but the
+		// coordinates are in pixels instead of cells.  Unless we know
+		// the size of the cells, we could not tell which cell that is,
+		// so then only the cell is reported.
+		t.pixelMouse = f&MousePixelMotion != 0 && t.cellW > 0 && t.cellH > 0
+		if t.pixelMouse {
+			t.TPuts("\x1b[?1003h\x1b[?1016h")
+		} else if f&(MouseMotionEvents|MousePixelMotion) != 0 {
 			t.TPuts("\x1b[?1003h\x1b[?1006h")
 		} else if f&MouseDragEvents != 0 {
 			t.TPuts("\x1b[?1002h\x1b[?1006h")
@@ -886,6 +1996,236 @@ func (t *tScreen) enablePasting(on bool) {
 	}
 }
 
+// These are the sequences used to negotiate the kitty keyboard protocol.
+// (See https://sw.kovidgoyal.net/kitty/keyboard-protocol/ for details.)
+// We only ask for the "disambiguate escape codes" enhancement, which leaves
+// ordinary text alone but reports keys that are otherwise ambiguous (such as
+// Ctrl-I versus Tab, Shift-Enter versus Enter, or a lone ESC) as CSI u
+// sequences.  Note that the main and alternate screens each have their own
+// stack of flags, so these must be sent while the alternate screen is active.
+const (
+	kittyKbdQuery = "\x1b[?u"
+	kittyKbdPush  = "\x1b[>1u"
+	kittyKbdPop   = "\x1b[<u"
+)
+
+// enableKittyKbd enables the kitty keyboard protocol if we already know
+// the terminal supports it, otherwise it asks the terminal.  The reply
+// (if any) is processed by parseKittyKbd, which finishes the job.
+func (t *tScreen) enableKittyKbd() {
+	if t.kittyKbd {
+		t.TPuts(kittyKbdPush)
+	} else if t.xtermLike() {
+		t.TPuts(kittyKbdQuery)
+	}
+}
+
+// disableKittyKbd restores the keyboard mode that was in effect before
+// we enabled the kitty keyboard protocol.
+func (t *tScreen) disableKittyKbd() {
+	if t.kittyKbd {
+		t.TPuts(kittyKbdPop)
+	}
+}
+
+func (t *tScreen) EnableFocus() {
+	t.Lock()
+	t.focusEnabled = true
+	t.enableFocusReporting(true)
+	t.Unlock()
+}
+
+func (t *tScreen) DisableFocus() {
+	t.Lock()
+	t.focusEnabled = false
+	t.enableFocusReporting(false)
+	t.Unlock()
+}
+
+func (t *tScreen) enableFocusReporting(on bool) {
+	var s string
+	if on {
+		s = t.enableFocus
+	} else {
+		s = t.disableFocus
+	}
+	if s != "" {
+		t.TPuts(s)
+	}
+}
+
+// clipboardTimeout is how long we wait for the terminal to answer a
+// request for the clipboard contents.
+const clipboardTimeout = time.Second
+
+func (t *tScreen) SetClipboard(sel string, data []byte) error {
+	if !validSelection(sel) {
+		return ErrBadSelection
+	}
+	t.Lock()
+	defer t.Unlock()
+	if !t.xtermLike() || t.fini {
+		return ErrNoClipboard
+	}
+	t.writeString("\x1b]52;" + sel + ";" + base64.StdEncoding.EncodeToString(data) + "\x1b\\")
+	return nil
+}
+
+func (t *tScreen) GetClipboard(sel string) ([]byte, error) {
+	if !validSelection(sel) {
+		return nil, ErrBadSelection
+	}
+	t.Lock()
+	if !t.xtermLike() || t.fini {
+		t.Unlock()
+		return nil, ErrNoClipboard
+	}
+	// discard any stale reply to an earlier request
+	select {
+	case <-t.clipch:
+	default:
+	}
+	t.writeString("\x1b]52;" + sel + ";?\x1b\\")
+	t.Unlock()
+
+	select {
+	case data := <-t.clipch:
+		return data, nil
+	case <-t.quit:
+		return nil, ErrNoClipboard
+	case <-time.After(clipboardTimeout):
+		return nil, ErrNoClipboard
+	}
+}
+
+// colorReport is a terminal's reply to a query for one of its dynamic
+// colors, passed from handleOSC to queryColor.
+type colorReport struct {
+	ps    string // the OSC code, e.g. "11" for the background
+	color Color
+}
+
+// colorQuery counts the queries for one of the terminal's dynamic
+// colors that have not been answered yet.
+type colorQuery struct {
+	pending int
+	expires time.Time // when to give up on the pending replies
+}
+
+// colorQueryTimeout is how long we wait for the terminal to report one
+// of its colors.  Terminals that do not support this never answer.
+const colorQueryTimeout = 200 * time.Millisecond
+
+// colorQueryExpiry is how long a reply that arrives after we stopped
+// waiting is still taken for an answer rather than a notification.
+// Queries that are never answered must not hide later notifications.
+const colorQueryExpiry = 5 * colorQueryTimeout
+
+// parseColorSpec parses a color in the X11 form rgb:r/g/b, where each
+// component is given with from one to four hex digits, as terminals use
+// when reporting their colors.
+func parseColorSpec(s string) (Color, bool) {
+	if !strings.HasPrefix(s, "rgb:") {
+		return ColorDefault, false
+	}
+	parts := strings.Split(s[4:], "/")
+	if len(parts) != 3 {
+		return ColorDefault, false
+	}
+	var rgb [3]int32
+	for i, p := range parts {
+		if len(p) < 1 || len(p) > 4 {
+			return ColorDefault, false
+		}
+		v, e := strconv.ParseUint(p, 16, 16)
+		if e != nil {
+			return ColorDefault, false
+		}
+		// scale to 8 bits, so that e.g. "f" and "ffff" are both 255
+		max := uint64(1)<<(4*uint(len(p))) - 1
+		rgb[i] = int32((v*255 + max/2) / max)
+	}
+	return NewRGBColor(rgb[0], rgb[1], rgb[2]), true
+}
+
+// queryColor asks the terminal for one of its dynamic colors, using
+// OSC ps ; ? ST, and waits for the reply.  Like queryMode, this must not
+// be called with the lock held, and only while engaged.
+func (t *tScreen) queryColor(ps string) (Color, error) {
+	t.Lock()
+	if !t.xtermLike() || t.fini || t.stopQ == nil {
+		t.Unlock()
+		return ColorDefault, ErrNoCapability
+	}
+	// discard any stale reply to an earlier query
+	select {
+	case <-t.colorch:
+	default:
+	}
+	// So that handleOSC knows the reply is not a notification, even if
+	// it comes after we have stopped waiting for it.
+	if t.colorQueries == nil {
+		t.colorQueries = make(map[string]colorQuery)
+	}
+	q := t.colorQueries[ps]
+	q.pending++
+	q.expires = time.Now().Add(colorQueryExpiry)
+	t.colorQueries[ps] = q
+	t.writeString("\x1b]" + ps + ";?\x1b\\")
+	t.Unlock()
+
+	timeout := time.After(colorQueryTimeout)
+	for {
+		select {
+		case r := <-t.colorch:
+			if r.ps == ps {
+				return r.color, nil
+			}
+		case <-t.quit:
+			return ColorDefault, ErrNoCapability
+		case <-timeout:
+			return ColorDefault, ErrNoCapability
+		}
+	}
+}
+
+// setTermColor records a foreground (OSC 10) or background (OSC 11)
+// color reported by the terminal, and forgets the colors fitted to the
+// old one.  It returns false if the color is unchanged.
+func (t *tScreen) setTermColor(ps string, c Color) bool {
+	switch {
+	case ps == "10" && c != t.fgColor:
+		t.fgColor = c
+		t.bgColors = make(map[Color]Color)
+	case ps == "11" && c != t.bgColor:
+		t.bgColor = c
+		t.fgColors = make(map[Color]Color)
+	default:
+		return false
+	}
+	return true
+}
+
+func (t *tScreen) QueryForegroundColor() (Color, error) {
+	return t.queryColor("10")
+}
+
+func (t *tScreen) ForegroundColor() Color {
+	t.Lock()
+	defer t.Unlock()
+	return t.fgColor
+}
+
+func (t *tScreen) QueryBackgroundColor() (Color, error) {
+	return t.queryColor("11")
+}
+
+func (t *tScreen) BackgroundColor() Color {
+	t.Lock()
+	defer t.Unlock()
+	return t.bgColor
+}
+
 func (t *tScreen) Size() (int, int) {
 	t.Lock()
 	w, h := t.w, t.h
@@ -909,6 +2249,28 @@ func (t *tScreen) resize() {
 	}
 }
 
+// getCellSize asks the driver for the size of a character cell in pixels.
+// Zeros are returned if the driver does not know it.
+func (t *tScreen) getCellSize() (int, int) {
+	if cs, ok := t.driver.(TermDriverCellSizer); ok {
+		return cs.CellSize()
+	}
+	return 0, 0
+}
+
+// resizeCells notes the size of the character cells, which may change
+// with the window size (or the font), for working out the cells of mouse
+// events reported in pixels.  This is only done when the window size
+// changes, or when engaging, rather than for every event.  If that means that pixels can now be used,
+// or can no longer be, mouse reporting is changed to suit.
+func (t *tScreen) resizeCells() {
+	t.cellW, t.cellH = t.getCellSize()
+	known := t.cellW > 0 && t.cellH > 0
+	if t.mouseFlags&MousePixelMotion != 0 && known != t.pixelMouse && t.stopQ != nil {
+		t.enableMouse(t.mouseFlags)
+	}
+}
+
 func (t *tScreen) Colors() int {
 	// this doesn't change, no need for lock
 	if t.truecolor {
@@ -917,6 +2279,30 @@ func (t *tScreen) Colors() int {
 	return t.ti.Colors
 }
 
+func (t *tScreen) ColorDepth() ColorDepth {
+	// this doesn't change, no need for lock
+	return t.depth
+}
+
+// detectColorDepth works out how many colors the terminal described by
+// ti can display.  A $COLORTERM of "truecolor" or "24bit" always means
+// direct color, and otherwise we go by the terminfo description, with
+// any capability overrides, which will have RGB color capabilities if
+// it supports direct color.
+func detectColorDepth(ti *terminfo.Terminfo, colorterm string) ColorDepth {
+	switch colorterm {
+	case "truecolor", "24bit", "24-bit":
+		return ColorDepthTrue
+	}
+	if ti.TrueColor || ti.SetFgRGB != "" || ti.SetBgRGB != "" {
+		return ColorDepthTrue
+	}
+	if ti.Colors >= 256 {
+		return ColorDepth256
+	}
+	return ColorDepth8
+}
+
 // nColors returns the size of the built-in palette.
 // This is distinct from Colors(), as it will generally
 // always be a small number. (<= 256)
@@ -927,12 +2313,31 @@ func (t *tScreen) nColors() int {
 func (t *tScreen) PollEvent() Event {
 	select {
 	case <-t.quit:
-		return nil
+		return pendingEvent(t.evch)
 	case ev := <-t.evch:
 		return ev
 	}
 }
 
+func (t *tScreen) PollEventContext(ctx context.Context) (Event, error) {
+	select {
+	case <-t.quit:
+		return pendingEvent(t.evch), nil
+	case ev := <-t.evch:
+		return ev, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *tScreen) PollEventFor(d time.Duration) (Event, error) {
+	return pollEventFor(t.evch, t.quit, d)
+}
+
+func (t *tScreen) HasPendingEvent() bool {
+	return len(t.evch) > 0
+}
+
 // vtACSNames is a map of bytes defined by terminfo that are used in
 // the terminals Alternate Character Set to represent other glyphs.
 // For example, the upper left corner of the box drawing set can be
@@ -997,17 +2402,224 @@ func (t *tScreen) buildAcsMap() {
 	}
 }
 
-func (t *tScreen) PostEventWait(ev Event) {
-	t.evch <- ev
+func (t *tScreen) PostEventWait(ev Event) {
+	t.evch <- ev
+}
+
+func (t *tScreen) PostEvent(ev Event) error {
+	select {
+	case t.evch <- ev:
+		return nil
+	default:
+		return ErrEventQFull
+	}
+}
+
+func (t *tScreen) TermInfo() TermCapabilities {
+	t.Lock()
+	defer t.Unlock()
+	return TermCapabilities{
+		TrueColor:      t.truecolor,
+		Colors256:      t.truecolor || t.ti.Colors >= 256,
+		Mouse:          len(t.mouse) != 0,
+		BracketedPaste: t.enablePaste != "",
+		FocusEvents:    t.enableFocus != "",
+		Hyperlinks:     t.hyperlinks,
+		SixelGraphics:  t.sixel,
+		KittyKeyboard:  t.kittyKbd,
+		KittyGraphics:  t.kittyGfx,
+		Multiplexer:    t.multiplexer,
+	}
+}
+
+func (t *tScreen) TermVersion() (string, string) {
+	t.Lock()
+	defer t.Unlock()
+	if t.hostName != "" {
+		return t.hostName, t.hostVersion
+	}
+	return t.termName, t.termVersion
+}
+
+func (t *tScreen) DebugInfo() string {
+	t.Lock()
+	defer t.Unlock()
+	var d debugInfo
+	d.str("driver", "terminfo")
+	d.str("term_driver", fmt.Sprintf("%T", t.driver))
+	d.str("term", t.ti.Name)
+	d.str("charset", t.charset)
+	d.num("color_depth", int(t.depth))
+	d.flag("truecolor", t.truecolor)
+	d.num("width", t.w)
+	d.num("height", t.h)
+	d.flag("mouse", t.mouseFlags != 0)
+	d.num("mouse_flags", int(t.mouseFlags))
+	d.flag("paste", t.pasteEnabled)
+	d.flag("focus", t.focusEnabled)
+	d.flag("kitty_keyboard", t.kittyKbd)
+	d.flag("sync_output", t.syncOutput)
+	d.str("multiplexer", t.multiplexer)
+	d.str("term_name", t.termName)
+	d.str("term_version", t.termVersion)
+	d.str("host_term_name", t.hostName)
+	d.str("host_term_version", t.hostVersion)
+	d.table("overrides", t.opts.CapabilityOverrides)
+	return d.String()
+}
+
+func (t *tScreen) DrawSixel(x, y int, img image.Image) error {
+	t.Lock()
+	defer t.Unlock()
+	if !t.sixel {
+		return ErrNoGraphics
+	}
+	if t.fini {
+		return nil
+	}
+	data := encodeSixel(img, t.sixelColors)
+	t.TPuts(t.ti.TGoto(x, y))
+	t.writeBytes(data)
+	// we can't know where the terminal left the cursor
+	t.cx = -1
+	t.cy = -1
+	return nil
+}
+
+func (t *tScreen) DrawImage(x, y, w, h int, img image.Image, id uint32) error {
+	t.Lock()
+	defer t.Unlock()
+	if !t.kittyGfx || t.fini {
+		return nil
+	}
+	data, e := encodeKittyImage(img, w, h, id)
+	if e != nil {
+		return e
+	}
+	t.TPuts(t.ti.TGoto(x, y))
+	t.writeBytes(data)
+	// as for Sixel, the cursor is no longer where we left it
+	t.cx = -1
+	t.cy = -1
+	return nil
+}
+
+func (t *tScreen) DeleteImage(id uint32) {
+	t.Lock()
+	if t.kittyGfx && !t.fini {
+		t.writeString(kittyDeleteImage(id))
+	}
+	t.Unlock()
+}
+
+func (t *tScreen) DrawPixelImage(x, y, w, h int, img image.Image, mode PixelMode) {
+	drawPixelImage(t, x, y, w, h, img, mode)
+}
+
+func (t *tScreen) DrawText(x, y int, style Style, text string) int {
+	return drawText(t, x, y, style, text)
+}
+
+func (t *tScreen) DrawBox(x, y, w, h int, style Style, border BoxStyle) {
+	drawBox(t, x, y, w, h, style, border)
+}
+
+func (t *tScreen) DrawHLine(x, y, w int, style Style, border BoxStyle) {
+	drawHLine(t, x, y, w, style, border)
+}
+
+func (t *tScreen) DrawVLine(x, y, h int, style Style, border BoxStyle) {
+	drawVLine(t, x, y, h, style, border)
+}
+
+func (t *tScreen) SetCrossing(x, y int, style Style, border BoxStyle) {
+	setCrossing(t, x, y, style, border)
+}
+
+func (t *tScreen) DrawANSI(x, y int, str string, base Style) (int, error) {
+	return drawANSI(t, x, y, str, base)
+}
+
+func (t *tScreen) SetResizeDebounce(d time.Duration) {
+	t.Lock()
+	t.resizeDelay = d
+	t.Unlock()
+}
+
+func (t *tScreen) TParm(capName string, params ...int) error {
+	t.Lock()
+	s := t.ti.Capability(capName)
+	if s != "" && !t.fini {
+		t.TPuts(t.ti.TParm(s, params...))
+	}
+	t.Unlock()
+	if s == "" {
+		return ErrNoCapability
+	}
+	return nil
+}
+
+func (t *tScreen) SetEscapeTimeout(d time.Duration) {
+	t.Lock()
+	t.escDelay = d
+	t.Unlock()
+}
+
+// escapeTimeout returns how long to wait for the rest of an escape
+// sequence, before deciding that the input so far stands on its own.
+func (t *tScreen) escapeTimeout() time.Duration {
+	t.Lock()
+	defer t.Unlock()
+	if t.escDelay > 0 {
+		return t.escDelay
+	}
+	return defaultEscapeTimeout
+}
+
+func (t *tScreen) SetComposeMap(m *ComposeMap) {
+	t.Lock()
+	evs := t.compose.setMap(m)
+	t.Unlock()
+	for _, ev := range evs {
+		_ = t.PostEvent(ev)
+	}
+}
+
+func (t *tScreen) SetScrollAccumThreshold(lines int) {
+	t.Lock()
+	t.scroll = scrollAccum{threshold: lines}
+	t.Unlock()
+}
+
+func (t *tScreen) MouseButtonState() ButtonMask {
+	t.Lock()
+	defer t.Unlock()
+	return t.buttons
+}
+
+func (t *tScreen) SetMaxFPS(fps int) {
+	t.Lock()
+	t.frameTime = frameTime(fps)
+	t.Unlock()
+}
+
+func (t *tScreen) SetTicker(d time.Duration) {
+	t.Lock()
+	t.ticker.d = d
+	if t.stopQ != nil {
+		t.ticker.start(t.PostEvent)
+	} else {
+		t.ticker.stop()
+	}
+	t.Unlock()
+}
+
+func (t *tScreen) setOptions(opts ScreenOptions) {
+	t.opts = opts
 }
 
-func (t *tScreen) PostEvent(ev Event) error {
-	select {
-	case t.evch <- ev:
-		return nil
-	default:
-		return ErrEventQFull
-	}
+func (t *tScreen) PostFunc(f func()) error {
+	return t.PostEvent(NewEventFunc(f))
 }
 
 func (t *tScreen) clip(x, y int) (int, int) {
@@ -1089,6 +2701,21 @@ func (t *tScreen) buildMouseEvent(x, y, btn int) *EventMouse {
 	return NewEventMouse(x, y, button, mod)
 }
 
+// xtermButton returns the button that an XTerm mouse report is about,
+// or ButtonNone for wheel motion and releases.  The buttons are mapped
+// as in buildMouseEvent.
+func xtermButton(btn int) ButtonMask {
+	switch btn & 0x43 {
+	case 0:
+		return Button1
+	case 1:
+		return Button3
+	case 2:
+		return Button2
+	}
+	return ButtonNone
+}
+
 // parseSgrMouse attempts to locate an SGR mouse record at the start of the
 // buffer.  It returns true, true if it found one, and the associated bytes
 // be removed from the buffer.  It returns true, false if the buffer might
@@ -1177,6 +2804,13 @@ func (t *tScreen) parseSgrMouse(buf *bytes.Buffer, evs *[]Event) (bool, bool) {
 
 			motion = (btn & 32) != 0
 			btn &^= 32
+			// SGR reports say which button was released, so we
+			// can keep track of each one.
+			if b[i] == 'm' {
+				t.buttons &^= xtermButton(btn)
+			} else if !motion {
+				t.buttons |= xtermButton(btn)
+			}
 			if b[i] == 'm' {
 				// mouse release, clear all buttons
 				btn |= 3
@@ -1202,25 +2836,573 @@ func (t *tScreen) parseSgrMouse(buf *bytes.Buffer, evs *[]Event) (bool, bool) {
 				_, _ = buf.ReadByte()
 				i--
 			}
-			*evs = append(*evs, t.buildMouseEvent(x, y, btn))
+			var ev *EventMouse
+			if t.pixelMouse {
+				ev = t.buildPixelMouseEvent(x, y, btn)
+			} else {
+				ev = t.buildMouseEvent(x, y, btn)
+			}
+			ev.motion = motion
+			*evs = append(*evs, ev)
+			return true, true
+		}
+	}
+
+	// incomplete & inconclusive at this point
+	return true, false
+}
+
+// buildPixelMouseEvent is like buildMouseEvent, but the coordinates are
+// in pixels, as reported in SGR-Pixels mode.  The cell position is derived
+// from the size of the character cells, as found when the window was last
+// resized.
+func (t *tScreen) buildPixelMouseEvent(px, py, btn int) *EventMouse {
+	if px < 0 {
+		px = 0
+	}
+	if py < 0 {
+		py = 0
+	}
+	x, y := 0, 0
+	if t.cellW > 0 && t.cellH > 0 {
+		x, y = px/t.cellW, py/t.cellH
+	}
+	ev := t.buildMouseEvent(x, y, btn)
+	ev.px, ev.py = px, py
+	return ev
+}
+
+// parseXtermMouse is like parseSgrMouse, but it parses a legacy
+// X11 mouse record.
+func (t *tScreen) parseXtermMouse(buf *bytes.Buffer, evs *[]Event) (bool, bool) {
+
+	b := buf.Bytes()
+
+	state := 0
+	btn := 0
+	x := 0
+	y := 0
+
+	for i := range b {
+		switch state {
+		case 0:
+			switch b[i] {
+			case '\x1b':
+				state = 1
+			case '\x9b':
+				state = 2
+			default:
+				return false, false
+			}
+		case 1:
+			if b[i] != '[' {
+				return false, false
+			}
+			state = 2
+		case 2:
+			if b[i] != 'M' {
+				return false, false
+			}
+			state++
+		case 3:
+			btn = int(b[i])
+			state++
+		case 4:
+			x = int(b[i]) - 32 - 1
+			state++
+		case 5:
+			y = int(b[i]) - 32 - 1
+			for i >= 0 {
+				_, _ = buf.ReadByte()
+				i--
+			}
+			// Legacy reports do not say which button was released,
+			// so a release is taken to mean all of them.
+			if btn&0x43 == 3 {
+				t.buttons = ButtonNone
+			} else {
+				t.buttons |= xtermButton(btn)
+			}
+			ev := t.buildMouseEvent(x, y, btn)
+			ev.motion = (btn-32)&32 != 0
+			*evs = append(*evs, ev)
+			return true, true
+		}
+	}
+	return true, false
+}
+
+// parseKittyKbd is like parseSgrMouse, but it parses a kitty keyboard
+// protocol record.  This is either a key report of the form
+// CSI code[:alternates] [; modifiers[:event] [; text]] u, or the reply
+// to our capability query, which is CSI ? flags u.
+func (t *tScreen) parseKittyKbd(buf *bytes.Buffer, evs *[]Event) (bool, bool) {
+
+	b := buf.Bytes()
+
+	var vals [2][2]int // code and alternates, modifiers and event type
+	state := 0
+	field := 0
+	sub := 0
+	dig := false
+	reply := false
+
+	for i := range b {
+		switch state {
+		case 0:
+			switch b[i] {
+			case '\x1b':
+				state = 1
+			case '\x9b':
+				state = 2
+			default:
+				return false, false
+			}
+		case 1:
+			if b[i] != '[' {
+				return false, false
+			}
+			state = 2
+		case 2:
+			state = 3
+			if b[i] == '?' {
+				reply = true
+				continue
+			}
+			if b[i] < '0' || b[i] > '9' {
+				return false, false
+			}
+			fallthrough
+		case 3:
+			switch c := b[i]; {
+			case c >= '0' && c <= '9':
+				if field < len(vals) && sub < len(vals[field]) {
+					vals[field][sub] *= 10
+					vals[field][sub] += int(c - '0')
+				}
+				dig = true
+			case c == ':':
+				sub++
+			case c == ';':
+				field++
+				sub = 0
+			case c == 'u':
+				if !dig {
+					return false, false
+				}
+				for i >= 0 {
+					_, _ = buf.ReadByte()
+					i--
+				}
+				if reply {
+					if !t.kittyKbd {
+						t.kittyKbd = true
+						t.TPuts(kittyKbdPush)
+					}
+				} else if ev := t.kittyKeyEvent(vals[0][0], vals[1][0], vals[1][1]); ev != nil {
+					*evs = append(*evs, ev)
+				}
+				return true, true
+			default:
+				return false, false
+			}
+		}
+	}
+
+	// incomplete & inconclusive at this point
+	return true, false
+}
+
+// parseModeReport is like parseSgrMouse, but it parses a DECRPM report,
+// which is the terminal's reply to a DECRQM query.  These take the form
+// CSI ? mode ; value $ y.
+func (t *tScreen) parseModeReport(buf *bytes.Buffer, evs *[]Event) (bool, bool) {
+
+	b := buf.Bytes()
+
+	var vals [2]int // mode, value
+	state := 0
+	field := 0
+
+	for i := range b {
+		switch state {
+		case 0:
+			switch b[i] {
+			case '\x1b':
+				state = 1
+			case '\x9b':
+				state = 2
+			default:
+				return false, false
+			}
+		case 1:
+			if b[i] != '[' {
+				return false, false
+			}
+			state = 2
+		case 2:
+			if b[i] != '?' {
+				return false, false
+			}
+			state = 3
+		case 3:
+			switch c := b[i]; {
+			case c >= '0' && c <= '9':
+				vals[field] *= 10
+				vals[field] += int(c - '0')
+			case c == ';' && field == 0:
+				field++
+			case c == '$' && field == 1:
+				state = 4
+			default:
+				return false, false
+			}
+		case 4:
+			if b[i] != 'y' {
+				return false, false
+			}
+			for i >= 0 {
+				_, _ = buf.ReadByte()
+				i--
+			}
+			if f := t.modeHandlers[vals[0]]; f != nil {
+				f(modeState(vals[1]))
+			}
 			return true, true
 		}
 	}
 
-	// incomplete & inconclusive at this point
-	return true, false
+	// incomplete & inconclusive at this point
+	return true, false
+}
+
+// parseGraphicsAttrs is like parseSgrMouse, but it parses the reply to
+// an XTSMGRAPHICS query.  These take the form CSI ? item ; status ; value
+// S, where there may be more than one value.  Only the number of Sixel
+// color registers (item 1) is used.
+func (t *tScreen) parseGraphicsAttrs(buf *bytes.Buffer, evs *[]Event) (bool, bool) {
+
+	b := buf.Bytes()
+
+	var vals []int // item, status, values
+	val := 0
+	state := 0
+
+	for i := range b {
+		switch state {
+		case 0:
+			switch b[i] {
+			case '\x1b':
+				state = 1
+			case '\x9b':
+				state = 2
+			default:
+				return false, false
+			}
+		case 1:
+			if b[i] != '[' {
+				return false, false
+			}
+			state = 2
+		case 2:
+			if b[i] != '?' {
+				return false, false
+			}
+			state = 3
+		case 3:
+			switch c := b[i]; {
+			case c >= '0' && c <= '9':
+				val *= 10
+				val += int(c - '0')
+			case c == ';':
+				vals = append(vals, val)
+				val = 0
+			case c == 'S':
+				vals = append(vals, val)
+				if len(vals) < 3 {
+					return false, false
+				}
+				buf.Next(i + 1)
+				// a status of 0 means success
+				if vals[0] == 1 && vals[1] == 0 && vals[2] > 0 {
+					t.sixelColors = vals[2]
+					if t.sixelColors > maxSixelColors {
+						t.sixelColors = maxSixelColors
+					}
+				}
+				return true, true
+			default:
+				return false, false
+			}
+		}
+	}
+
+	// incomplete & inconclusive at this point
+	return true, false
+}
+
+// parseOSC is like parseSgrMouse, but it parses an operating system
+// command, which is how terminals reply to some of our queries.  These
+// take the form OSC Ps ; Pt ST, where the terminator may also be BEL.
+func (t *tScreen) parseOSC(buf *bytes.Buffer, evs *[]Event) (bool, bool) {
+
+	b := buf.Bytes()
+
+	start := 0
+	switch {
+	case b[0] == '\x9d':
+		start = 1
+	case b[0] != '\x1b':
+		return false, false
+	case len(b) == 1:
+		return true, false
+	case b[1] == ']':
+		start = 2
+	default:
+		return false, false
+	}
+
+	for i := start; i < len(b); i++ {
+		end := 0
+		switch b[i] {
+		case '\x07', '\x9c':
+			end = i + 1
+		case '\x1b':
+			if i+1 == len(b) {
+				return true, false
+			}
+			if b[i+1] != '\\' {
+				return false, false
+			}
+			end = i + 2
+		default:
+			continue
+		}
+		t.handleOSC(string(b[start:i]), evs)
+		buf.Next(end)
+		return true, true
+	}
+
+	// incomplete & inconclusive at this point
+	return true, false
+}
+
+// handleOSC processes the content of an operating system command
+// received from the terminal, adding any resulting events to evs.
+func (t *tScreen) handleOSC(s string, evs *[]Event) {
+	ps, pt := s, ""
+	if i := strings.IndexByte(s, ';'); i >= 0 {
+		ps, pt = s[:i], s[i+1:]
+	}
+	switch ps {
+	case "10", "11":
+		// dynamic colors (foreground, background): rgb:r/g/b
+		c, ok := parseColorSpec(pt)
+		if !ok {
+			break
+		}
+		changed := t.setTermColor(ps, c)
+		q := t.colorQueries[ps]
+		if q.pending > 0 && time.Now().After(q.expires) {
+			q.pending = 0
+		}
+		if q.pending == 0 {
+			delete(t.colorQueries, ps)
+			// Nobody asked, so the terminal is telling us that the
+			// color scheme has changed.
+			if changed {
+				*evs = append(*evs, NewEventColorChange(t.fgColor, t.bgColor))
+			}
+			break
+		}
+		// This answers a query, which may have timed out already,
+		// in which case nobody reads it and the next query discards
+		// it.  Only the latest report is kept.
+		q.pending--
+		t.colorQueries[ps] = q
+		if t.colorch != nil {
+			select {
+			case <-t.colorch:
+			default:
+			}
+			t.colorch <- colorReport{ps, c}
+		}
+	case "52":
+		// clipboard contents: selection ; base64 data
+		if i := strings.IndexByte(pt, ';'); i >= 0 {
+			if data, e := base64.StdEncoding.DecodeString(pt[i+1:]); e == nil {
+				select {
+				case t.clipch <- data:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// kittyKeys maps the kitty keyboard protocol key codes for functional
+// keys that are reported with CSI u to our own key codes.  Functional keys
+// not listed here are still reported using their legacy encodings.
+var kittyKeys = map[int]Key{
+	9:     KeyTab,
+	13:    KeyEnter,
+	27:    KeyEsc,
+	127:   KeyBackspace2,
+	57361: KeyPrint,
+	57362: KeyPause,
+	57414: KeyEnter, // KP_ENTER
+	57417: KeyLeft,
+	57418: KeyRight,
+	57419: KeyUp,
+	57420: KeyDown,
+	57421: KeyPgUp,
+	57422: KeyPgDn,
+	57423: KeyHome,
+	57424: KeyEnd,
+	57425: KeyInsert,
+	57426: KeyDelete,
+	57427: KeyCenter, // KP_BEGIN
+}
+
+// kittyKeypad maps the keypad keys that produce text to their runes.
+var kittyKeypad = map[int]rune{
+	57409: '.',
+	57410: '/',
+	57411: '*',
+	57412: '-',
+	57413: '+',
+	57415: '=',
+	57416: ',',
+}
+
+// kittyKeyEvent builds a key event from a kitty keyboard protocol key
+// report.  It returns nil for events we have no way to represent, such as
+// presses of a lone modifier key.
+func (t *tScreen) kittyKeyEvent(code, mods, event int) *EventKey {
+
+	if event == 3 {
+		// Key release. We don't ask for these, but be safe.
+		return nil
+	}
+
+	mod := xtermModifiers(mods)
+	if t.escaped {
+		mod |= ModAlt
+		t.escaped = false
+	}
+
+	if k, ok := kittyKeys[code]; ok {
+		return NewEventKey(k, rune(k), mod)
+	}
+	switch {
+	case code >= 57376 && code <= 57398:
+		return NewEventKey(KeyF13+Key(code-57376), 0, mod)
+	case code >= 57399 && code <= 57408:
+		return NewEventKey(KeyRune, rune('0'+code-57399), mod)
+	case code >= 57344 && code <= 63743:
+		// Private use area; these are the remaining functional
+		// keys (modifiers, media keys, etc.)
+		if r, ok := kittyKeypad[code]; ok {
+			return NewEventKey(KeyRune, r, mod)
+		}
+		return nil
+	}
+
+	// Control keys are reported the same way as we always have, but
+	// now we can be sure that the Ctrl modifier was really present.
+	if mod&ModCtrl != 0 {
+		switch {
+		case code >= 'a' && code <= 'z':
+			return NewEventKey(KeyCtrlA+Key(code-'a'), rune(code-'a'+1), mod)
+		case code == ' ' || (code >= '@' && code <= '_'):
+			return NewEventKey(Key(code&0x1f), rune(code&0x1f), mod)
+		}
+	}
+
+	r := rune(code)
+	if mod&ModShift != 0 {
+		r = unicode.ToUpper(r)
+	}
+	return NewEventKey(KeyRune, r, mod)
 }
 
-// parseXtermMouse is like parseSgrMouse, but it parses a legacy
-// X11 mouse record.
-func (t *tScreen) parseXtermMouse(buf *bytes.Buffer, evs *[]Event) (bool, bool) {
+// xtermModifiers decodes the modifier parameter of an XTerm style key
+// report, which is also used by the kitty keyboard protocol.  This is
+// 1 plus a bit mask of the modifiers.
+func xtermModifiers(mods int) ModMask {
+	mod := ModNone
+	if mods > 0 {
+		mods--
+	}
+	if mods&1 != 0 {
+		mod |= ModShift
+	}
+	if mods&2 != 0 {
+		mod |= ModAlt
+	}
+	if mods&4 != 0 {
+		mod |= ModCtrl
+	}
+	if mods&(8|32) != 0 { // Super and Meta
+		mod |= ModMeta
+	}
+	return mod
+}
+
+// xtermTildeKeys are the keys reported as CSI number ; modifiers ~.
+var xtermTildeKeys = map[int]Key{
+	1:  KeyHome,
+	2:  KeyInsert,
+	3:  KeyDelete,
+	4:  KeyEnd,
+	5:  KeyPgUp,
+	6:  KeyPgDn,
+	7:  KeyHome,
+	8:  KeyEnd,
+	11: KeyF1,
+	12: KeyF2,
+	13: KeyF3,
+	14: KeyF4,
+	15: KeyF5,
+	17: KeyF6,
+	18: KeyF7,
+	19: KeyF8,
+	20: KeyF9,
+	21: KeyF10,
+	23: KeyF11,
+	24: KeyF12,
+}
+
+// xtermLetterKeys are the keys reported as CSI 1 ; modifiers letter.
+var xtermLetterKeys = map[byte]Key{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+	'H': KeyHome,
+	'F': KeyEnd,
+	'P': KeyF1,
+	'Q': KeyF2,
+	'R': KeyF3,
+	'S': KeyF4,
+}
+
+// parseXtermKey is like parseSgrMouse, but it parses the reports of
+// modified keys that XTerm sends, which are CSI 1 ; modifiers letter
+// for the cursor keys and F1 to F4, and CSI number ; modifiers ~ for
+// the other function keys.  With modifyOtherKeys, other keys are sent
+// as CSI 27 ; modifiers ; code ~.  Most of these are in our key table
+// already, if the terminal database says the terminal uses them, but this
+// catches them whatever the database says, including the combinations
+// with Meta.
+func (t *tScreen) parseXtermKey(buf *bytes.Buffer, evs *[]Event) (bool, bool) {
 
 	b := buf.Bytes()
 
+	var vals []int
+	val := 0
+	dig := false
 	state := 0
-	btn := 0
-	x := 0
-	y := 0
 
 	for i := range b {
 		switch state {
@@ -1233,35 +3415,66 @@ func (t *tScreen) parseXtermMouse(buf *bytes.Buffer, evs *[]Event) (bool, bool)
 			default:
 				return false, false
 			}
+			continue
 		case 1:
 			if b[i] != '[' {
 				return false, false
 			}
 			state = 2
-		case 2:
-			if b[i] != 'M' {
+			continue
+		}
+
+		switch c := b[i]; {
+		case c >= '0' && c <= '9':
+			val = val*10 + int(c-'0')
+			dig = true
+		case c == ';':
+			if !dig || len(vals) > 1 {
 				return false, false
 			}
-			state++
-		case 3:
-			btn = int(b[i])
-			state++
-		case 4:
-			x = int(b[i]) - 32 - 1
-			state++
-		case 5:
-			y = int(b[i]) - 32 - 1
-			for i >= 0 {
+			vals = append(vals, val)
+			val, dig = 0, false
+		default:
+			if !dig || len(vals) == 0 {
+				return false, false
+			}
+			vals = append(vals, val)
+			var ev *EventKey
+			if k, ok := xtermLetterKeys[c]; ok && len(vals) == 2 && vals[0] == 1 {
+				ev = t.xtermKeyEvent(k, vals[1])
+			} else if c == '~' && len(vals) == 3 && vals[0] == 27 {
+				// modifyOtherKeys uses the same codes as kitty
+				ev = t.kittyKeyEvent(vals[2], vals[1], 1)
+			} else if k, ok := xtermTildeKeys[vals[0]]; ok && c == '~' && len(vals) == 2 {
+				ev = t.xtermKeyEvent(k, vals[1])
+			} else {
+				return false, false
+			}
+			for ; i >= 0; i-- {
 				_, _ = buf.ReadByte()
-				i--
 			}
-			*evs = append(*evs, t.buildMouseEvent(x, y, btn))
+			if ev != nil {
+				*evs = append(*evs, ev)
+			}
 			return true, true
 		}
 	}
+
+	// incomplete & inconclusive at this point
 	return true, false
 }
 
+// xtermKeyEvent returns the event for a function key with XTerm style
+// modifiers.
+func (t *tScreen) xtermKeyEvent(k Key, mods int) *EventKey {
+	mod := xtermModifiers(mods)
+	if t.escaped {
+		mod |= ModAlt
+		t.escaped = false
+	}
+	return NewEventKey(k, 0, mod)
+}
+
 func (t *tScreen) parseFunctionKey(buf *bytes.Buffer, evs *[]Event) (bool, bool) {
 	b := buf.Bytes()
 	partial := false
@@ -1286,6 +3499,10 @@ func (t *tScreen) parseFunctionKey(buf *bytes.Buffer, evs *[]Event) (bool, bool)
 				*evs = append(*evs, NewEventPaste(true))
 			case keyPasteEnd:
 				*evs = append(*evs, NewEventPaste(false))
+			case keyFocusIn:
+				*evs = append(*evs, NewEventFocus(true))
+			case keyFocusOut:
+				*evs = append(*evs, NewEventFocus(false))
 			default:
 				*evs = append(*evs, NewEventKey(k.key, r, mod))
 			}
@@ -1351,7 +3568,23 @@ func (t *tScreen) parseRune(buf *bytes.Buffer, evs *[]Event) (bool, bool) {
 func (t *tScreen) scanInput(buf *bytes.Buffer, expire bool) {
 	evs := t.collectEventsFromInput(buf, expire)
 
+	t.Lock()
+	var out []Event
 	for _, ev := range evs {
+		if ev = t.scroll.filter(ev); ev == nil {
+			continue
+		}
+		// pasted text is not composed
+		if t.paste.active {
+			out = append(out, ev)
+		} else {
+			out = append(out, t.compose.filter(ev)...)
+		}
+		t.paste.filter(ev)
+	}
+	t.Unlock()
+
+	for _, ev := range out {
 		t.PostEventWait(ev)
 	}
 }
@@ -1402,6 +3635,42 @@ func (t *tScreen) collectEventsFromInput(buf *bytes.Buffer, expire bool) []Event
 			} else if part {
 				partials++
 			}
+
+			if part, comp := t.parseKittyKbd(buf, &res); comp {
+				continue
+			} else if part {
+				partials++
+			}
+
+			if part, comp := t.parseXtermKey(buf, &res); comp {
+				continue
+			} else if part {
+				partials++
+			}
+
+			if part, comp := t.parseDeviceAttrs(buf, &res); comp {
+				continue
+			} else if part {
+				partials++
+			}
+
+			if part, comp := t.parseModeReport(buf, &res); comp {
+				continue
+			} else if part {
+				partials++
+			}
+
+			if part, comp := t.parseGraphicsAttrs(buf, &res); comp {
+				continue
+			} else if part {
+				partials++
+			}
+
+			if part, comp := t.parseOSC(buf, &res); comp {
+				continue
+			} else if part {
+				partials++
+			}
 		}
 
 		if partials == 0 || expire {
@@ -1437,25 +3706,84 @@ func (t *tScreen) collectEventsFromInput(buf *bytes.Buffer, expire bool) []Event
 	return res
 }
 
+// notifyWinSize gives the driver a channel to report size changes on,
+// if it wants one.
+func (t *tScreen) notifyWinSize() {
+	if n, ok := t.driver.(TermDriverWinSizeNotifier); ok {
+		n.NotifyWinSize(t.winsizech)
+	}
+}
+
 func (t *tScreen) mainLoop(stopQ chan struct{}) {
 	defer t.wg.Done()
 	buf := &bytes.Buffer{}
+	var pingC <-chan time.Time
+	pinger, _ := t.driver.(TermDriverPinger)
+	if t.opts.PingInterval > 0 && pinger != nil {
+		ping := time.NewTicker(t.opts.PingInterval)
+		defer ping.Stop()
+		pingC = ping.C
+	}
+
+	// When debouncing, size changes are only acted on once they
+	// have stopped arriving for a while.
+	var resizeTimer *time.Timer
+	var resizeC <-chan time.Time
+	defer func() {
+		if resizeTimer != nil {
+			resizeTimer.Stop()
+		}
+	}()
+	sizeChanged := func() {
+		t.Lock()
+		d := t.resizeDelay
+		t.Unlock()
+		if d <= 0 {
+			t.winSizeChanged()
+			return
+		}
+		if resizeTimer == nil {
+			resizeTimer = time.NewTimer(d)
+		} else {
+			if !resizeTimer.Stop() {
+				select {
+				case <-resizeTimer.C:
+				default:
+				}
+			}
+			resizeTimer.Reset(d)
+		}
+		resizeC = resizeTimer.C
+	}
+
 	for {
 		select {
 		case <-stopQ:
 			return
 		case <-t.quit:
 			return
+		case <-pingC:
+			if e := pinger.Ping(); e != nil {
+				// PollEvent still returns this once the screen
+				// is finished, as it drains the queue first.
+				_ = t.PostEvent(NewEventError(e))
+				// Fini waits for this loop to exit, so it
+				// must run elsewhere.
+				go t.Fini()
+				return
+			}
 		case <-t.sigwinch:
-			t.Lock()
-			t.cx = -1
-			t.cy = -1
-			t.resize()
-			t.cells.Invalidate()
-			t.draw()
-			t.Unlock()
+			sizeChanged()
+			continue
+		case <-t.winsizech:
+			sizeChanged()
+			continue
+		case <-resizeC:
+			resizeC = nil
+			t.winSizeChanged()
 			continue
 		case <-t.keytimer.C:
+			escDelay := t.escapeTimeout()
 			// If the timer fired, and the current time
 			// is after the expiration of the escape sequence,
 			// then we assume the escape sequence reached it's
@@ -1473,11 +3801,12 @@ func (t *tScreen) mainLoop(stopQ chan struct{}) {
 					default:
 					}
 				}
-				t.keytimer.Reset(time.Millisecond * 50)
+				t.keytimer.Reset(escDelay)
 			}
 		case chunk := <-t.keychan:
+			escDelay := t.escapeTimeout()
 			buf.Write(chunk)
-			t.keyexpire = time.Now().Add(time.Millisecond * 50)
+			t.keyexpire = time.Now().Add(escDelay)
 			t.scanInput(buf, false)
 			if !t.keytimer.Stop() {
 				select {
@@ -1486,12 +3815,27 @@ func (t *tScreen) mainLoop(stopQ chan struct{}) {
 				}
 			}
 			if buf.Len() > 0 {
-				t.keytimer.Reset(time.Millisecond * 50)
+				t.keytimer.Reset(escDelay)
 			}
 		}
 	}
 }
 
+// winSizeChanged redraws the screen at its new size after we have been
+// notified, either by SIGWINCH or by the driver, that it has changed.
+func (t *tScreen) winSizeChanged() {
+	t.Lock()
+	t.cx = -1
+	t.cy = -1
+	t.resize()
+	t.resizeCells()
+	t.cells.Invalidate()
+	if !t.batch.hold(true) {
+		t.draw()
+	}
+	t.Unlock()
+}
+
 func (t *tScreen) inputLoop(stopQ chan struct{}) {
 
 	defer t.wg.Done()
@@ -1506,6 +3850,18 @@ func (t *tScreen) inputLoop(stopQ chan struct{}) {
 		switch e {
 		case nil:
 		default:
+			select {
+			case <-stopQ:
+				// we are being shut down, which interrupts
+				// the read, rather than disconnected
+				return
+			default:
+			}
+			if rc, ok := t.driver.(TermDriverReconnector); ok {
+				// this has to wait for us to exit
+				go t.reconnect(rc)
+				return
+			}
 			_ = t.PostEvent(NewEventError(e))
 			return
 		}
@@ -1515,8 +3871,184 @@ func (t *tScreen) inputLoop(stopQ chan struct{}) {
 	}
 }
 
+// reconnect tears down the session on a lost connection, waits for the
+// driver to re-establish it, and then starts over with a full repaint.
+func (t *tScreen) reconnect(rc TermDriverReconnector) {
+	t.disengage()
+	select {
+	case <-t.quit:
+		return
+	default:
+	}
+	e := rc.Reconnect()
+	if e == nil {
+		e = t.initialize()
+	}
+	if e == nil {
+		e = t.engage()
+	}
+	if e != nil {
+		_ = t.PostEvent(NewEventError(e))
+		return
+	}
+	t.Sync()
+	_ = t.PostEvent(NewEventResize(t.Size()))
+}
+
+func (t *tScreen) SetBellMode(mode BellMode) {
+	t.Lock()
+	t.bellMode = mode
+	t.Unlock()
+}
+
+func (t *tScreen) SetVisualBellDuration(d time.Duration) {
+	t.Lock()
+	t.bellTime = d
+	t.Unlock()
+}
+
+func (t *tScreen) Flash(d time.Duration) {
+	t.flash(d)
+}
+
+// DECSCNM turns reverse video for the whole screen on and off.
+const (
+	flashOn  = "\x1b[?5h"
+	flashOff = "\x1b[?5l"
+)
+
+// flash turns on reverse video for the whole screen (DECSCNM), and
+// turns it off again after d, or the default time if d is zero.  This
+// returns without waiting.  If the screen is flashed again before
+// the first one is over, it stays reversed until the last one ends.
+func (t *tScreen) flash(d time.Duration) {
+	if d == 0 {
+		d = defaultFlashTime
+	}
+	t.Lock()
+	defer t.Unlock()
+	if t.fini || t.stopQ == nil {
+		return
+	}
+	t.flashes++
+	t.flashing = true
+	n := t.flashes
+	t.writeString(flashOn)
+	time.AfterFunc(d, func() {
+		t.Lock()
+		defer t.Unlock()
+		if t.flashes == n {
+			t.endFlash()
+		}
+	})
+}
+
+// endFlash turns reverse video off again, if the screen is flashing.
+// This is also done when disengaging, so the terminal is not left
+// reversed.
+func (t *tScreen) endFlash() {
+	if t.flashing {
+		t.writeString(flashOff)
+		t.flashing = false
+	}
+}
+
+// setupTerminal puts the terminal into the state that we need: the
+// alternate screen, keypad mode, and whichever of mouse reporting,
+// bracketed paste and focus reporting are enabled.  It also asks the
+// terminal about the features it has, if that has not been done yet.
+func (t *tScreen) setupTerminal() {
+	t.enableMouse(t.mouseFlags)
+	t.enablePasting(t.pasteEnabled)
+	t.enableFocusReporting(t.focusEnabled)
+
+	ti := t.ti
+	t.TPuts(ti.EnterCA)
+	t.TPuts(ti.EnterKeypad)
+	t.TPuts(ti.HideCursor)
+	t.TPuts(ti.EnableAcs)
+	t.TPuts(ti.Clear)
+	t.enableKittyKbd()
+	t.querySyncOutput()
+	t.queryDeviceAttrs()
+	t.querySixelColors()
+}
+
+// hardReset is RIS, which returns the terminal to its power on state.
+const hardReset = "\x1bc"
+
+// hardResetDelay is how long to wait after a hard reset before sending
+// anything else, as some terminals discard what arrives while they are
+// resetting.
+const hardResetDelay = 100 * time.Millisecond
+
+func (t *tScreen) HardReset() error {
+	t.Lock()
+	if t.fini || t.stopQ == nil {
+		t.Unlock()
+		return nil
+	}
+	err := t.send([]byte(hardReset))
+	t.Unlock()
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(hardResetDelay)
+
+	t.Lock()
+	defer t.Unlock()
+	if !t.fini && t.stopQ != nil {
+		t.reinitialize()
+	}
+	return nil
+}
+
+// softReset is DECSTR, which resets things such as the scrolling margins,
+// character sets and attributes, without clearing the display.
+const softReset = "\x1b[!p"
+
+func (t *tScreen) SoftReset() error {
+	t.Lock()
+	defer t.Unlock()
+	if t.fini || t.stopQ == nil {
+		return nil
+	}
+	if t.xtermLike() {
+		if err := t.send([]byte(softReset)); err != nil {
+			return err
+		}
+	}
+	// DECSTR leaves the kitty keyboard flags alone, so pop what we
+	// pushed, as setting the terminal up again pushes it once more.
+	t.disableKittyKbd()
+	t.reinitialize()
+	return nil
+}
+
+// reinitialize sets the terminal up again after it has been reset, and
+// redraws everything, as the terminal may have forgotten what we told it.
+func (t *tScreen) reinitialize() {
+	t.curstyle = styleInvalid
+	t.curCurStyle = CursorStyleDefault
+	t.setupTerminal()
+	t.cx = -1
+	t.cy = -1
+	t.resize()
+	t.clear = true
+	t.cells.Invalidate()
+	// in a transaction, this is put off until it ends, like Sync
+	if !t.batch.hold(true) {
+		t.draw()
+	}
+}
+
 func (t *tScreen) Sync() {
 	t.Lock()
+	if t.batch.hold(true) {
+		t.Unlock()
+		return
+	}
 	t.cx = -1
 	t.cy = -1
 	if !t.fini {
@@ -1524,10 +4056,39 @@ func (t *tScreen) Sync() {
 		t.clear = true
 		t.cells.Invalidate()
 		t.draw()
+		t.lastFrame = time.Now()
+		t.framePending = false
 	}
 	t.Unlock()
 }
 
+func (t *tScreen) SyncRegion(x, y, w, h int) {
+	t.Lock()
+	defer t.Unlock()
+	// Whatever damaged the region may also have moved the cursor and
+	// left attributes set, so neither can be trusted.
+	t.curstyle = styleInvalid
+	t.cx = -1
+	t.cy = -1
+	t.cells.InvalidateRegion(x, y, w, h)
+	if t.batch.hold(false) || t.fini {
+		return
+	}
+	t.resize()
+	if t.clear {
+		// the whole screen has to be drawn anyway
+		t.draw()
+		return
+	}
+	defer t.softCursor.apply(&t.cells)()
+	// include the left half of a wide character, as InvalidateRegion does
+	t.drawRect(image.Rect(x-1, y, x+w, y+h).Intersect(image.Rect(0, 0, t.w, t.h)))
+}
+
+func (t *tScreen) Transaction(f func()) {
+	transaction(t, &t.batch, f, t.Show, t.Sync)
+}
+
 func (t *tScreen) CharacterSet() string {
 	return t.charset
 }
@@ -1590,7 +4151,42 @@ func (t *tScreen) Suspend() error {
 }
 
 func (t *tScreen) Resume() error {
-	return t.engage()
+	if err := t.engage(); err != nil {
+		return err
+	}
+	t.Show()
+	return nil
+}
+
+func (t *tScreen) SetTermDriver(driver TermDriver) error {
+	t.Lock()
+	fini, engaged, started := t.fini, t.stopQ != nil, t.in != nil
+	t.Unlock()
+	if fini {
+		return ErrNoScreen
+	}
+	t.disengage()
+	t.Lock()
+	t.driver = driver
+	t.Unlock()
+	if !started {
+		// Init will use the new driver
+		return nil
+	}
+	if e := t.initialize(); e != nil {
+		return e
+	}
+	t.notifyWinSize()
+	if !engaged {
+		// Resume will do the rest
+		return nil
+	}
+	if e := t.engage(); e != nil {
+		return e
+	}
+	t.Sync()
+	_ = t.PostEvent(NewEventResize(t.Size()))
+	return nil
 }
 
 // SetDriver is used to replace the default TermDriver.
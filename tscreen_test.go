@@ -0,0 +1,1488 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcell
+
+import (
+	"bufio"
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2/terminfo"
+)
+
+// mkTestTScreen returns a tScreen suitable for exercising the input
+// parser.  It is not attached to any terminal, and all output is
+// collected in the screen's buffer.
+func mkTestTScreen(t *testing.T) *tScreen {
+	ts := &tScreen{
+		ti: &terminfo.Terminfo{
+			Name:      "tscreen_test",
+			Columns:   80,
+			Lines:     24,
+			Mouse:     "\x1b[M",
+			SetCursor: "\x1b[%i%p1%d;%p2%dH",
+		},
+		keyexist:  make(map[Key]bool),
+		keycodes:  make(map[string]*tKeyCode),
+		buffering: true,
+	}
+	ts.prepareKeys()
+	return ts
+}
+
+func TestKittyKeyboard(t *testing.T) {
+	ts := mkTestTScreen(t)
+
+	ts.enableKittyKbd()
+	if s := ts.buf.String(); s != kittyKbdQuery {
+		t.Fatalf("Expected kitty keyboard query, got %q", s)
+	}
+	ts.buf.Reset()
+
+	evs := ts.collectEventsFromInput(bytes.NewBufferString("\x1b[?0u"), false)
+	if len(evs) != 0 {
+		t.Errorf("Query reply should not produce events: %v", evs)
+	}
+	if !ts.kittyKbd || !ts.TermInfo().KittyKeyboard {
+		t.Fatalf("Kitty keyboard protocol not detected")
+	}
+	if s := ts.buf.String(); s != kittyKbdPush {
+		t.Errorf("Expected kitty keyboard push, got %q", s)
+	}
+
+	var values = []struct {
+		input string
+		key   Key
+		ch    rune
+		mod   ModMask
+	}{
+		{"\x1b[105;5u", KeyCtrlI, 9, ModCtrl},
+		{"\x1b[13;2u", KeyEnter, 13, ModShift},
+		{"\x1b[27u", KeyEsc, 27, ModNone},
+		{"\x1b[97;4u", KeyRune, 'A', ModShift | ModAlt},
+		{"\x1b[57399u", KeyRune, '0', ModNone},
+		{"\x1b[57376;5u", KeyF13, 0, ModCtrl},
+	}
+	for _, tc := range values {
+		evs = ts.collectEventsFromInput(bytes.NewBufferString(tc.input), false)
+		if len(evs) != 1 {
+			t.Errorf("%q: expected one event, got %d", tc.input, len(evs))
+			continue
+		}
+		ev, ok := evs[0].(*EventKey)
+		if !ok {
+			t.Errorf("%q: expected key event, got %T", tc.input, evs[0])
+			continue
+		}
+		if ev.Key() != tc.key || ev.Rune() != tc.ch || ev.Modifiers() != tc.mod {
+			t.Errorf("%q: wrong event %s (%v, %v, %v)",
+				tc.input, ev.Name(), ev.Key(), ev.Rune(), ev.Modifiers())
+		}
+	}
+
+	// Lone modifier keys are not reported.
+	evs = ts.collectEventsFromInput(bytes.NewBufferString("\x1b[57441;2u"), false)
+	if len(evs) != 0 {
+		t.Errorf("Modifier key should not produce events: %v", evs)
+	}
+
+	ts.buf.Reset()
+	ts.disableKittyKbd()
+	if s := ts.buf.String(); s != kittyKbdPop {
+		t.Errorf("Expected kitty keyboard pop, got %q", s)
+	}
+}
+
+func TestFocusEvents(t *testing.T) {
+	ts := mkTestTScreen(t)
+
+	evs := ts.collectEventsFromInput(bytes.NewBufferString("\x1b[I\x1b[O"), false)
+	if len(evs) != 2 {
+		t.Fatalf("Expected two events, got %d", len(evs))
+	}
+	for i, focused := range []bool{true, false} {
+		ev, ok := evs[i].(*EventFocus)
+		if !ok {
+			t.Errorf("Expected focus event, got %T", evs[i])
+		} else if ev.Focused != focused {
+			t.Errorf("Focus should be %v", focused)
+		}
+	}
+}
+
+func TestSyncOutputReport(t *testing.T) {
+	ts := mkTestTScreen(t)
+
+	ts.querySyncOutput()
+	if s := ts.buf.String(); s != syncOutputQuery {
+		t.Fatalf("Expected synchronized output query, got %q", s)
+	}
+	evs := ts.collectEventsFromInput(bytes.NewBufferString("\x1b[?2026;2$y"), false)
+	if len(evs) != 0 {
+		t.Errorf("Mode report should not produce events: %v", evs)
+	}
+	if !ts.syncOutput {
+		t.Errorf("Synchronized output not detected")
+	}
+	ts.collectEventsFromInput(bytes.NewBufferString("\x1b[?2026;0$y"), false)
+	if ts.syncOutput {
+		t.Errorf("Synchronized output should not be used")
+	}
+}
+
+func TestClipboardReply(t *testing.T) {
+	ts := mkTestTScreen(t)
+	ts.clipch = make(chan []byte, 1)
+
+	evs := ts.collectEventsFromInput(bytes.NewBufferString("\x1b]52;c;aGVsbG8=\x1b\\"), false)
+	if len(evs) != 0 {
+		t.Errorf("Clipboard reply should not produce events: %v", evs)
+	}
+	select {
+	case data := <-ts.clipch:
+		if string(data) != "hello" {
+			t.Errorf("Wrong clipboard contents %q", data)
+		}
+	default:
+		t.Errorf("No clipboard contents delivered")
+	}
+}
+
+func TestDetectColorDepth(t *testing.T) {
+	// The terminfo lookup also consults these.
+	for _, env := range []string{"COLORTERM", "TCELL_TRUECOLOR"} {
+		if v, ok := os.LookupEnv(env); ok {
+			os.Unsetenv(env)
+			defer os.Setenv(env, v)
+		}
+	}
+
+	var values = []struct {
+		term      string
+		colorterm string
+		depth     ColorDepth
+	}{
+		{"vt100", "", ColorDepth8},
+		{"xterm", "", ColorDepth8},
+		{"xterm-256color", "", ColorDepth256},
+		{"xterm-256color", "truecolor", ColorDepthTrue},
+		{"xterm", "24bit", ColorDepthTrue},
+	}
+	for _, tc := range values {
+		ti, err := terminfo.LookupTerminfo(tc.term)
+		if err != nil {
+			t.Fatalf("No terminfo for %s: %v", tc.term, err)
+		}
+		if d := detectColorDepth(ti, tc.colorterm); d != tc.depth {
+			t.Errorf("%s (%q): expected depth %d, got %d",
+				tc.term, tc.colorterm, tc.depth, d)
+		}
+	}
+}
+
+func TestOverriddenColorDepth(t *testing.T) {
+	for _, env := range []string{"COLORTERM", "TCELL_TRUECOLOR"} {
+		if v, ok := os.LookupEnv(env); ok {
+			os.Unsetenv(env)
+			defer os.Setenv(env, v)
+		}
+	}
+	s, err := NewTerminfoScreenWithOptions(&pipeDriver{}, ScreenOptions{
+		CapabilityOverrides: map[string]string{"colors": "256"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to make screen: %v", err)
+	}
+	if err = s.Init(); err != nil {
+		t.Fatalf("Failed to initialize screen: %v", err)
+	}
+	defer s.Fini()
+	if d := s.ColorDepth(); d != ColorDepth256 {
+		t.Errorf("Expected the overridden depth of 256, got %d", d)
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	caps := parseOverrides(`colors=256, bel=,smxx=\E[9m,tsl=^[]0\,x`)
+	if len(caps) != 4 || caps["colors"] != "256" || caps["bel"] != "" ||
+		caps["smxx"] != "\x1b[9m" || caps["tsl"] != "\x1b]0,x" {
+		t.Errorf("Bad overrides %q", caps)
+	}
+
+	if v, ok := os.LookupEnv(envOverrides); ok {
+		defer os.Setenv(envOverrides, v)
+	} else {
+		defer os.Unsetenv(envOverrides)
+	}
+	os.Setenv(envOverrides, "colors=8,bel=")
+	s, err := NewTerminfoScreenWithOptions(&pipeDriver{}, ScreenOptions{
+		CapabilityOverrides: map[string]string{"colors": "256", "smxx": ""},
+	})
+	if err != nil {
+		t.Fatalf("Failed to make screen: %v", err)
+	}
+	// the environment wins over the application
+	ti := s.(*tScreen).ti
+	if ti.Colors != 8 || ti.Bell != "" || ti.StrikeThrough != "" {
+		t.Errorf("Overrides not applied: %d %q %q", ti.Colors, ti.Bell, ti.StrikeThrough)
+	}
+}
+
+func TestSixelEncode(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 5, 7))
+	for y := 0; y < 7; y++ {
+		for x := 0; x < 5; x++ {
+			img.Set(x, y, color.RGBA{R: 0xff, A: 0xff})
+		}
+	}
+	// leave the bottom right corner transparent
+	img.Set(4, 6, color.RGBA{})
+
+	expect := "\x1bP0;1q\"1;1;5;7#180;2;100;0;0#180!5~-#180!4@-\x1b\\"
+	if s := string(encodeSixel(img, 256)); s != expect {
+		t.Errorf("Bad sixel data %q", s)
+	}
+}
+
+func TestSixelPalette(t *testing.T) {
+	for _, v := range []struct {
+		n, colors int
+	}{
+		{1, 1}, {2, 2}, {4, 4}, {7, 7}, {8, 8}, {26, 8}, {27, 27}, {256, 216},
+	} {
+		if pal := sixelPalette(v.n); len(pal) != v.colors {
+			t.Errorf("%d: expected %d colors, got %d", v.n, v.colors, len(pal))
+		}
+	}
+	// too few for color, so greys
+	if pal := sixelPalette(4); pal[1] != (color.RGBA{R: 85, G: 85, B: 85, A: 0xff}) {
+		t.Errorf("Expected a grey, got %v", pal[1])
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 0xff, A: 0xff}), image.Point{}, draw.Src)
+	if s := string(encodeSixel(img, 1)); !strings.HasPrefix(s, "\x1bP0;1q") {
+		t.Errorf("Bad sixel data %q", s)
+	}
+}
+
+func TestKittyImageEncode(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	rand.New(rand.NewSource(1)).Read(img.Pix)
+	data, e := encodeKittyImage(img, 8, 4, 42)
+	if e != nil {
+		t.Fatalf("Encoding failed: %v", e)
+	}
+	chunks := strings.SplitAfter(string(data), "\x1b\\")
+	chunks = chunks[:len(chunks)-1]
+	if len(chunks) < 2 {
+		t.Fatalf("Expected the image to be sent in chunks")
+	}
+	if !strings.HasPrefix(chunks[0], "\x1b_Ga=T,f=100,q=2,C=1,i=42,c=8,r=4,m=1;") {
+		t.Errorf("Bad first chunk %q", chunks[0][:40])
+	}
+	for i, c := range chunks[1:] {
+		more := "\x1b_Gm=1;"
+		if i == len(chunks)-2 {
+			more = "\x1b_Gm=0;"
+		}
+		if !strings.HasPrefix(c, more) {
+			t.Errorf("Bad chunk %d: %q", i+1, c[:10])
+		}
+		if len(c) > len(more)+kittyChunkSize+2 {
+			t.Errorf("Chunk %d is too long", i+1)
+		}
+	}
+}
+
+func TestDrawImageCursor(t *testing.T) {
+	ts := mkTestTScreen(t)
+	ts.kittyGfx = true
+	ts.cx, ts.cy = 0, 0
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	if err := ts.DrawImage(2, 3, 1, 1, img, 1); err != nil {
+		t.Fatalf("Failed to draw image: %v", err)
+	}
+	if !strings.HasPrefix(ts.buf.String(), "\x1b[4;3H\x1b_G") {
+		t.Errorf("Expected the image at 2,3, got %q", ts.buf.String())
+	}
+	if ts.cx != -1 || ts.cy != -1 {
+		t.Errorf("Cursor position should be unknown, got %d,%d", ts.cx, ts.cy)
+	}
+}
+
+func TestCellBufferDamage(t *testing.T) {
+	cb := &CellBuffer{}
+	cb.Resize(10, 5)
+	if r := cb.takeDamage(); r != image.Rect(0, 0, 10, 5) {
+		t.Errorf("Resize should damage everything, got %v", r)
+	}
+	if r := cb.takeDamage(); !r.Empty() {
+		t.Errorf("Damage should be reset, got %v", r)
+	}
+	cb.SetContent(2, 1, 'a', nil, StyleDefault)
+	cb.SetContent(4, 3, 'b', nil, StyleDefault)
+	cb.SetContent(20, 3, 'c', nil, StyleDefault)
+	if r := cb.takeDamage(); r != image.Rect(2, 1, 6, 4) {
+		t.Errorf("Bad damage %v", r)
+	}
+	cb.FillRegion(8, 0, 5, 1, 'x', StyleDefault)
+	if r := cb.takeDamage(); r != image.Rect(8, 0, 10, 1) {
+		t.Errorf("Bad damage after fill %v", r)
+	}
+}
+
+func TestDrawRepeats(t *testing.T) {
+	ts := mkTestTScreen(t)
+	ts.encoder = GetEncoding("UTF-8").NewEncoder()
+	ts.w, ts.h = 12, 1
+	ts.cells.Resize(ts.w, ts.h)
+	for x := 0; x < 10; x++ {
+		ts.cells.SetContent(x, 0, 'x', nil, StyleDefault)
+	}
+	ts.cells.SetContent(10, 0, 'y', nil, StyleDefault)
+
+	ts.drawCell(0, 0)
+	ts.buf.Reset()
+	if n := ts.drawRepeats(0, 0); n != 9 || ts.buf.String() != "xxxxxxxxx" {
+		t.Errorf("Bad repeat without rep: %d %q", n, ts.buf.String())
+	}
+
+	ts.ti.RepeatChar = "%p1%c\x1b[%p2%{1}%-%db"
+	ts.cells.Invalidate()
+	ts.cx, ts.cy = -1, -1
+	ts.drawCell(0, 0)
+	ts.buf.Reset()
+	if n := ts.drawRepeats(0, 0); n != 9 || ts.buf.String() != "\x1b[9b" {
+		t.Errorf("Bad repeat with rep: %d %q", n, ts.buf.String())
+	}
+	if ts.cx != 10 || ts.cells.Dirty(9, 0) || !ts.cells.Dirty(10, 0) {
+		t.Errorf("Bad state after repeat")
+	}
+}
+
+func benchmarkDraw(b *testing.B, size int) {
+	r, w, e := os.Pipe()
+	if e != nil {
+		b.Fatalf("Failed to make pipe: %v", e)
+	}
+	defer r.Close()
+	defer w.Close()
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, r)
+	}()
+
+	ts := &tScreen{ti: &terminfo.Terminfo{
+		SetCursor: "\x1b[%i%p1%d;%p2%dH",
+		SetFg:     "\x1b[3%p1%dm",
+		SetBg:     "\x1b[4%p1%dm",
+		AttrOff:   "\x1b[m",
+		Colors:    8,
+	}}
+	ts.colors = make(map[Color]Color)
+	ts.fgColors = make(map[Color]Color)
+	ts.bgColors = make(map[Color]Color)
+	ts.out = w
+	ts.outw = bufio.NewWriterSize(w, size)
+	ts.w, ts.h = 200, 60
+	ts.cells.Resize(ts.w, ts.h)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for y := 0; y < ts.h; y++ {
+			for x := 0; x < ts.w; x++ {
+				fg := PaletteColor((x + y + i) % 8)
+				ts.cells.SetContent(x, y, 'a'+rune(x%26), nil, StyleDefault.Foreground(fg))
+			}
+		}
+		ts.draw()
+	}
+}
+
+// benchmarkSend sends a large frame, as already drawn, over a pipe.
+func benchmarkSend(b *testing.B, size int) {
+	r, w, e := os.Pipe()
+	if e != nil {
+		b.Fatalf("Failed to make pipe: %v", e)
+	}
+	defer r.Close()
+	defer w.Close()
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, r)
+	}()
+
+	ts := &tScreen{outw: bufio.NewWriterSize(w, size)}
+	frame := []byte(strings.Repeat("\x1b[31mab\x1b[32mcd", 1<<14))
+	b.SetBytes(int64(len(frame)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = ts.send(frame)
+	}
+}
+
+func BenchmarkSendSmallBuffer(b *testing.B) {
+	benchmarkSend(b, 512)
+}
+
+func BenchmarkSendDefaultBuffer(b *testing.B) {
+	benchmarkSend(b, ScreenOptions{}.writeBufferSize())
+}
+
+func BenchmarkDrawSmallBuffer(b *testing.B) {
+	benchmarkDraw(b, 512)
+}
+
+func BenchmarkDrawDefaultBuffer(b *testing.B) {
+	benchmarkDraw(b, ScreenOptions{}.writeBufferSize())
+}
+
+func TestFrameTime(t *testing.T) {
+	if d := frameTime(0); d != 0 {
+		t.Errorf("Expected no limit, got %v", d)
+	}
+	if d := frameTime(50); d != 20*time.Millisecond {
+		t.Errorf("Bad frame time %v", d)
+	}
+}
+
+func TestMaxFPS(t *testing.T) {
+	var out bytes.Buffer
+	ts := &tScreen{ti: &terminfo.Terminfo{
+		SetCursor: "\x1b[%i%p1%d;%p2%dH",
+		AttrOff:   "\x1b[m",
+	}}
+	ts.encoder = GetEncoding("UTF-8").NewEncoder()
+	ts.asciiSafe = true
+	ts.outw = bufio.NewWriter(&out)
+	ts.driver = &sizeDriver{w: 10, h: 3}
+	ts.w, ts.h = 10, 3
+	ts.cells.Resize(ts.w, ts.h)
+	ts.SetMaxFPS(10)
+	ts.Show()
+
+	// too soon, so Show returns without drawing or waiting
+	ts.SetContent(1, 1, 'a', nil, StyleDefault)
+	start := time.Now()
+	ts.Show()
+	ts.SetContent(2, 1, 'b', nil, StyleDefault)
+	ts.Show()
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Errorf("Show blocked for %v", d)
+	}
+	ts.Lock()
+	if s := out.String(); strings.Contains(s, "a") {
+		t.Errorf("Frame drawn too soon %q", s)
+	}
+	ts.Unlock()
+
+	// both changes are drawn when the frame time is up
+	for end := time.Now().Add(time.Second); ; {
+		ts.Lock()
+		done := strings.Contains(out.String(), "ab")
+		ts.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(end) {
+			t.Fatalf("Put off frame was never drawn")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCellBufferCombining(t *testing.T) {
+	cb := &CellBuffer{}
+	cb.Resize(4, 1)
+	cb.SetContent(0, 0, 'e', []rune{'́'}, StyleDefault)
+	cb.SetContent(1, 0, 'a', []rune{'̀', '́'}, StyleDefault)
+	cb.SetDirty(0, 0, false)
+	cb.SetContent(0, 0, 'e', []rune{'̀'}, StyleDefault)
+	if !cb.Dirty(0, 0) {
+		t.Errorf("Changed combining runes should be dirty")
+	}
+	cb.CopyRegion(0, 0, 1, 0, 3, 1)
+	if _, c, _, _ := cb.GetContent(1, 0); len(c) != 1 || c[0] != '̀' {
+		t.Errorf("Bad combining runes after copy %q", c)
+	}
+	if _, c, _, _ := cb.GetContent(2, 0); len(c) != 2 || c[1] != '́' {
+		t.Errorf("Bad combining runes after overlapping copy %q", c)
+	}
+	cb.Fill(' ', StyleDefault)
+	if _, c, _, _ := cb.GetContent(0, 0); len(c) != 0 {
+		t.Errorf("Combining runes should be cleared")
+	}
+}
+
+func BenchmarkFullScreenRedraw(b *testing.B) {
+	cb := &CellBuffer{}
+	cb.Resize(200, 60)
+	combc := [][]rune{{'́'}, {'̀'}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for y := 0; y < 60; y++ {
+			for x := 0; x < 200; x++ {
+				cb.SetContent(x, y, 'e', combc[(x+i)%2], StyleDefault)
+			}
+		}
+		for y := 0; y < 60; y++ {
+			for x := 0; x < 200; x++ {
+				cb.SetDirty(x, y, false)
+			}
+		}
+	}
+}
+
+func TestMouseButtonState(t *testing.T) {
+	ts := mkTestTScreen(t)
+	ts.w, ts.h = 80, 24
+
+	steps := []struct {
+		input string
+		state ButtonMask
+	}{
+		{"\x1b[<0;5;5M", Button1},
+		{"\x1b[<2;6;5M", Button1 | Button2},
+		{"\x1b[<34;7;5M", Button1 | Button2},
+		{"\x1b[<64;6;5M", Button1 | Button2},
+		{"\x1b[<2;6;5m", Button1},
+		{"\x1b[<0;6;5m", ButtonNone},
+	}
+	for _, step := range steps {
+		evs := ts.collectEventsFromInput(bytes.NewBufferString(step.input), false)
+		if s := ts.MouseButtonState(); s != step.state {
+			t.Errorf("%q: expected state %x, got %x", step.input, step.state, s)
+		}
+		dragging := step.input == "\x1b[<34;7;5M"
+		if len(evs) != 1 || evs[0].(*EventMouse).Dragging() != dragging {
+			t.Errorf("%q: dragging should be %v", step.input, dragging)
+		}
+	}
+}
+
+func TestEscapeTimeout(t *testing.T) {
+	ts := mkTestTScreen(t)
+	if d := ts.escapeTimeout(); d != 50*time.Millisecond {
+		t.Errorf("Bad default escape timeout %v", d)
+	}
+	ts.SetEscapeTimeout(10 * time.Millisecond)
+	if d := ts.escapeTimeout(); d != 10*time.Millisecond {
+		t.Errorf("Escape timeout not set, got %v", d)
+	}
+}
+
+func TestXtermModifiedKeys(t *testing.T) {
+	ts := mkTestTScreen(t)
+
+	cases := []struct {
+		input string
+		key   Key
+		r     rune
+		mod   ModMask
+	}{
+		{"\x1b[1;5A", KeyUp, 0, ModCtrl},
+		{"\x1b[1;2P", KeyF1, 0, ModShift},
+		{"\x1b[1;9D", KeyLeft, 0, ModMeta},
+		{"\x1b[15;3~", KeyF5, 0, ModAlt},
+		{"\x1b[3;6~", KeyDelete, 0, ModCtrl | ModShift},
+		{"\x1b[27;5;105~", KeyCtrlI, 9, ModCtrl},
+		{"\x1b[27;3;120~", KeyRune, 'x', ModAlt},
+	}
+	for _, tc := range cases {
+		evs := ts.collectEventsFromInput(bytes.NewBufferString(tc.input), false)
+		if len(evs) != 1 {
+			t.Errorf("%q: expected one event, got %d", tc.input, len(evs))
+			continue
+		}
+		ev, ok := evs[0].(*EventKey)
+		if !ok {
+			t.Errorf("%q: expected a key event, got %T", tc.input, evs[0])
+			continue
+		}
+		if ev.Key() != tc.key || ev.Rune() != tc.r || ev.Modifiers() != tc.mod {
+			t.Errorf("%q: got key %v rune %q mod %v", tc.input, ev.Key(), ev.Rune(), ev.Modifiers())
+		}
+	}
+
+	// A partial sequence waits for more input.
+	buf := bytes.NewBufferString("\x1b[1;5")
+	if evs := ts.collectEventsFromInput(buf, false); len(evs) != 0 || buf.Len() != 5 {
+		t.Errorf("Partial sequence should be kept")
+	}
+}
+
+func TestDeviceAttrs(t *testing.T) {
+	ts := mkTestTScreen(t)
+
+	ts.queryDeviceAttrs()
+	if s := ts.buf.String(); s != "\x1b[c\x1b[>c\x1b[=c\x1b[>q" {
+		t.Errorf("Bad device attribute queries %q", s)
+	}
+
+	input := "\x1b[?62;4;22c\x1b[>41;367;0c\x1bP!|7E565445\x1b\\x"
+	evs := ts.collectEventsFromInput(bytes.NewBufferString(input), false)
+	if len(evs) != 1 {
+		t.Errorf("Expected only the key event, got %d events", len(evs))
+	}
+	if len(ts.da1) != 3 || ts.da1[0] != 62 || !ts.sixel {
+		t.Errorf("Bad DA1 %v", ts.da1)
+	}
+	if len(ts.da2) != 3 || ts.da2[0] != 41 || ts.da2[1] != 367 {
+		t.Errorf("Bad DA2 %v", ts.da2)
+	}
+	if ts.da3 != "7E565445" {
+		t.Errorf("Bad DA3 %q", ts.da3)
+	}
+
+	evs = ts.collectEventsFromInput(bytes.NewBufferString("\x1bP>|kitty(0.26.5)\x1b\\"), false)
+	if name, version := ts.TermVersion(); len(evs) != 0 || name != "kitty" || version != "0.26.5" {
+		t.Errorf("Bad XTVERSION %q %q", name, version)
+	}
+	if name, version := parseXTVersion("tmux 3.3a"); name != "tmux" || version != "3.3a" {
+		t.Errorf("Bad XTVERSION %q %q", name, version)
+	}
+}
+
+func TestSixelColors(t *testing.T) {
+	ts := mkTestTScreen(t)
+	ts.sixelColors = 256
+
+	// the query is only sent once Sixel support is known
+	ts.querySixelColors()
+	if s := ts.buf.String(); s != "" {
+		t.Errorf("Unexpected query %q", s)
+	}
+	ts.collectEventsFromInput(bytes.NewBufferString("\x1b[?62;4c"), false)
+	if s := ts.buf.String(); s != sixelColorsQuery {
+		t.Errorf("Expected Sixel colors query, got %q", s)
+	}
+
+	var values = []struct {
+		input  string
+		colors int
+	}{
+		{"\x1b[?1;0;16S", 16},
+		{"\x1b[?1;3;0S", 16},       // failure
+		{"\x1b[?2;0;640;480S", 16}, // geometry, not colors
+		{"\x1b[?1;0;65536S", maxSixelColors},
+	}
+	for _, tc := range values {
+		evs := ts.collectEventsFromInput(bytes.NewBufferString(tc.input), false)
+		if len(evs) != 0 {
+			t.Errorf("%q: reply should not produce events: %v", tc.input, evs)
+		}
+		if ts.sixelColors != tc.colors {
+			t.Errorf("%q: expected %d colors, got %d", tc.input, tc.colors, ts.sixelColors)
+		}
+	}
+}
+
+func TestDebugInfo(t *testing.T) {
+	ts := mkTestTScreen(t)
+	ts.w, ts.h = 80, 24
+	ts.pasteEnabled = true
+	ts.termName = "kitty"
+	ts.opts.CapabilityOverrides = map[string]string{"colors": "256", "bel": ""}
+
+	info := ts.DebugInfo()
+	for _, line := range []string{
+		`term = "tscreen_test"`,
+		"width = 80\n",
+		"paste = true\n",
+		"mouse = false\n",
+		`term_name = "kitty"`,
+		"[overrides]\n\"bel\" = \"\"\n\"colors\" = \"256\"\n",
+	} {
+		if !strings.Contains(info, line) {
+			t.Errorf("Missing %q in debug info:\n%s", line, info)
+		}
+	}
+}
+
+func TestQueryMode(t *testing.T) {
+	ts := mkTestTScreen(t)
+	ts.stopQ = make(chan struct{})
+	ts.quit = make(chan struct{})
+
+	type result struct {
+		state modeState
+		err   error
+	}
+	done := make(chan result)
+	go func() {
+		state, err := ts.queryMode(1049)
+		done <- result{state, err}
+	}()
+	for end := time.Now().Add(time.Second); ; {
+		ts.Lock()
+		sent := ts.buf.String() == "\x1b[?1049$p"
+		ts.Unlock()
+		if sent {
+			break
+		}
+		if time.Now().After(end) {
+			t.Fatalf("Query not sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// a reply for another mode is not mistaken for ours
+	evs := ts.collectEventsFromInput(bytes.NewBufferString("\x1b[?25;1$y\x1b[?1049;2$y"), false)
+	if len(evs) != 0 {
+		t.Errorf("Mode reports should not produce events: %v", evs)
+	}
+	if r := <-done; r.err != nil || r.state != modeReset {
+		t.Errorf("Bad mode state %v %v", r.state, r.err)
+	}
+
+	if _, err := ts.queryMode(1000); err != ErrNoCapability {
+		t.Errorf("Expected timeout, got %v", err)
+	}
+}
+
+func TestParseColorSpec(t *testing.T) {
+	cases := []struct {
+		spec  string
+		color Color
+		ok    bool
+	}{
+		{"rgb:0000/0000/0000", NewRGBColor(0, 0, 0), true},
+		{"rgb:ffff/8080/0000", NewRGBColor(255, 128, 0), true},
+		{"rgb:ff/80/00", NewRGBColor(255, 128, 0), true},
+		{"rgb:f/0/f", NewRGBColor(255, 0, 255), true},
+		{"rgb:1e1e/1e1e/2e2e", NewRGBColor(30, 30, 46), true},
+		{"rgb:ff/80", ColorDefault, false},
+		{"rgb:fffff/0/0", ColorDefault, false},
+		{"rgb:gg/0/0", ColorDefault, false},
+		{"#ff8000", ColorDefault, false},
+	}
+	for _, c := range cases {
+		if color, ok := parseColorSpec(c.spec); color != c.color || ok != c.ok {
+			t.Errorf("%q: got %v %v, expected %v %v", c.spec, color, ok, c.color, c.ok)
+		}
+	}
+}
+
+func TestQueryBackgroundColor(t *testing.T) {
+	ts := mkTestTScreen(t)
+	ts.colorch = make(chan colorReport, 1)
+	ts.stopQ = make(chan struct{})
+	ts.quit = make(chan struct{})
+
+	if c := ts.BackgroundColor(); c != ColorDefault {
+		t.Errorf("Background color should be unknown, got %v", c)
+	}
+	type result struct {
+		color Color
+		err   error
+	}
+	done := make(chan result)
+	go func() {
+		c, err := ts.QueryBackgroundColor()
+		done <- result{c, err}
+	}()
+	for end := time.Now().Add(time.Second); ; {
+		ts.Lock()
+		sent := ts.buf.String() == "\x1b]11;?\x1b\\"
+		ts.Unlock()
+		if sent {
+			break
+		}
+		if time.Now().After(end) {
+			t.Fatalf("Query not sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	evs := ts.collectEventsFromInput(bytes.NewBufferString("\x1b]11;rgb:1e1e/1e1e/2e2e\x1b\\"), false)
+	if len(evs) != 0 {
+		t.Errorf("Color reports should not produce events: %v", evs)
+	}
+	expect := NewRGBColor(30, 30, 46)
+	if r := <-done; r.err != nil || r.color != expect {
+		t.Errorf("Bad background color %v %v", r.color, r.err)
+	}
+	if c := ts.BackgroundColor(); c != expect {
+		t.Errorf("Background color not cached, got %v", c)
+	}
+
+	if _, err := ts.QueryBackgroundColor(); err != ErrNoCapability {
+		t.Errorf("Expected timeout, got %v", err)
+	}
+	if c := ts.BackgroundColor(); c != expect {
+		t.Errorf("Background color lost after timeout, got %v", c)
+	}
+
+	// a late reply is still not a notification
+	late := NewRGBColor(0, 0, 0)
+	evs = ts.collectEventsFromInput(bytes.NewBufferString("\x1b]11;rgb:0/0/0\x1b\\"), false)
+	if len(evs) != 0 {
+		t.Errorf("Late color reports should not produce events: %v", evs)
+	}
+	if c := ts.BackgroundColor(); c != late {
+		t.Errorf("Background color not updated by late reply, got %v", c)
+	}
+	evs = ts.collectEventsFromInput(bytes.NewBufferString("\x1b]11;rgb:ff/ff/ff\x1b\\"), false)
+	if len(evs) != 1 {
+		t.Errorf("Expected a color change after the late reply, got %v", evs)
+	}
+}
+
+func TestQueryForegroundColor(t *testing.T) {
+	ts := mkTestTScreen(t)
+	ts.colorch = make(chan colorReport, 1)
+	ts.stopQ = make(chan struct{})
+	ts.quit = make(chan struct{})
+
+	done := make(chan Color)
+	go func() {
+		c, _ := ts.QueryForegroundColor()
+		done <- c
+	}()
+	for end := time.Now().Add(time.Second); ; {
+		ts.Lock()
+		sent := ts.buf.String() == "\x1b]10;?\x1b\\"
+		ts.Unlock()
+		if sent {
+			break
+		}
+		if time.Now().After(end) {
+			t.Fatalf("Query not sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// a report of the background is not mistaken for the foreground
+	ts.collectEventsFromInput(bytes.NewBufferString("\x1b]11;rgb:0/0/0\x1b\\\x1b]10;rgb:ff/ff/ff\x07"), false)
+	if c := <-done; c != ColorWhite.TrueColor() {
+		t.Errorf("Bad foreground color %x", c)
+	}
+	if c := ts.ForegroundColor(); c != ColorWhite.TrueColor() {
+		t.Errorf("Foreground color not cached, got %x", c)
+	}
+}
+
+func TestColorChangeNotification(t *testing.T) {
+	ts := mkTestTScreen(t)
+
+	evs := ts.collectEventsFromInput(bytes.NewBufferString("\x1b]11;rgb:ffff/ffff/ffff\x1b\\"), false)
+	if len(evs) != 1 {
+		t.Fatalf("Expected one event, got %d", len(evs))
+	}
+	ev, ok := evs[0].(*EventColorChange)
+	if !ok {
+		t.Fatalf("Expected color change event, got %T", evs[0])
+	}
+	white := NewRGBColor(255, 255, 255)
+	if ev.Background() != white || ev.Foreground() != ColorDefault {
+		t.Errorf("Bad colors %x %x", ev.Foreground(), ev.Background())
+	}
+	if c := ts.BackgroundColor(); c != white {
+		t.Errorf("Background color not updated, got %x", c)
+	}
+
+	// the same color again is not a change
+	evs = ts.collectEventsFromInput(bytes.NewBufferString("\x1b]11;rgb:ff/ff/ff\x07"), false)
+	if len(evs) != 0 {
+		t.Errorf("Unchanged color should not produce events: %v", evs)
+	}
+
+	// a reply to a query is not a notification
+	ts.colorQueries = map[string]colorQuery{"11": {1, time.Now().Add(time.Hour)}}
+	evs = ts.collectEventsFromInput(bytes.NewBufferString("\x1b]11;rgb:00/00/00\x07"), false)
+	if len(evs) != 0 {
+		t.Errorf("Reply should not produce events: %v", evs)
+	}
+
+	// but a query that was never answered does not hide changes forever
+	ts.colorQueries = map[string]colorQuery{"11": {1, time.Now().Add(-time.Second)}}
+	evs = ts.collectEventsFromInput(bytes.NewBufferString("\x1b]11;rgb:ff/ff/ff\x07"), false)
+	if len(evs) != 1 {
+		t.Errorf("Expected one event after the query expired, got %d", len(evs))
+	}
+}
+
+func TestMultiplexer(t *testing.T) {
+	ts := mkTestTScreen(t)
+	ts.buffering = false
+	var out bytes.Buffer
+	ts.outw = bufio.NewWriter(&out)
+	ts.multiplexer = "tmux"
+
+	// DA1 is asked again, to find where the multiplexer's replies end
+	ts.queryDeviceAttrs()
+	if s := out.String(); s != "\x1b[c\x1b[>c\x1b[=c\x1b[>q\x1b[c" {
+		t.Errorf("Bad device attribute queries %q", s)
+	}
+	if s := passthrough("screen", "\x1b[>q"); s != "\x1bP\x1b[>q\x1b\\" {
+		t.Errorf("Bad screen passthrough %q", s)
+	}
+	if s := passthrough("zellij", "\x1b[>q"); s != "" {
+		t.Errorf("Zellij has no passthrough, got %q", s)
+	}
+
+	// only once the multiplexer has answered is the terminal asked
+	out.Reset()
+	ts.collectEventsFromInput(bytes.NewBufferString(
+		"\x1b[?1;2c\x1b[>84;0;0c\x1bP>|tmux 3.3a\x1b\\"), false)
+	if s := out.String(); s != "" {
+		t.Errorf("Terminal asked too soon %q", s)
+	}
+	ts.collectEventsFromInput(bytes.NewBufferString("\x1b[?1;2c"), false)
+	if s := out.String(); s != "\x1bPtmux;\x1b\x1b[>c\x1b\x1b[>q\x1b\x1b[c\x1b\\" {
+		t.Errorf("Bad passthrough queries %q", s)
+	}
+
+	// the replies that follow are the terminal's, and kept apart
+	ts.collectEventsFromInput(bytes.NewBufferString(
+		"\x1b[>1;4000;29c\x1bP>|WezTerm 20230408\x1b\\\x1b[?65;4;22c"), false)
+	if len(ts.da2) != 3 || ts.da2[0] != 84 || len(ts.hostDA2) != 3 || ts.hostDA2[0] != 1 {
+		t.Errorf("Bad DA2 %v %v", ts.da2, ts.hostDA2)
+	}
+	if len(ts.da1) != 2 || len(ts.hostDA1) != 3 || ts.hostDA1[0] != 65 {
+		t.Errorf("Bad DA1 %v %v", ts.da1, ts.hostDA1)
+	}
+	if ts.termName != "tmux" || ts.hostName != "WezTerm" {
+		t.Errorf("Bad terminal names %q %q", ts.termName, ts.hostName)
+	}
+	if name, version := ts.TermVersion(); name != "WezTerm" || version != "20230408" {
+		t.Errorf("Bad terminal version %q %q", name, version)
+	}
+	if ts.hostReplies {
+		t.Errorf("Terminal replies should have ended")
+	}
+	if ts.TermInfo().Multiplexer != "tmux" {
+		t.Errorf("Multiplexer not reported")
+	}
+}
+
+func TestBellMode(t *testing.T) {
+	ts := mkTestTScreen(t)
+	ts.stopQ = make(chan struct{})
+
+	ts.SetBellMode(BellBoth)
+	ts.SetVisualBellDuration(time.Millisecond)
+	if err := ts.Beep(); err != nil {
+		t.Fatalf("Beep failed: %v", err)
+	}
+	for end := time.Now().Add(time.Second); ; {
+		ts.Lock()
+		done := !ts.flashing
+		ts.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(end) {
+			t.Fatalf("Visual bell did not end")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if s := ts.buf.String(); s != "\a"+flashOn+flashOff {
+		t.Errorf("Bad bell %q", s)
+	}
+
+	// a flash that is still going is ended when disengaging
+	ts.buf.Reset()
+	ts.SetBellMode(BellVisual)
+	ts.SetVisualBellDuration(time.Hour)
+	_ = ts.Beep()
+	ts.Lock()
+	ts.endFlash()
+	ts.Unlock()
+	if s := ts.buf.String(); s != flashOn+flashOff {
+		t.Errorf("Bad visual bell %q", s)
+	}
+}
+
+func TestFlash(t *testing.T) {
+	ts := mkTestTScreen(t)
+	ts.stopQ = make(chan struct{})
+
+	start := time.Now()
+	ts.Flash(0)
+	if time.Since(start) >= defaultFlashTime {
+		t.Errorf("Flash should not wait")
+	}
+	for end := time.Now().Add(time.Second + defaultFlashTime); ; {
+		ts.Lock()
+		done := !ts.flashing
+		ts.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(end) {
+			t.Fatalf("Flash did not end")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if time.Since(start) < defaultFlashTime {
+		t.Errorf("Flash ended too soon")
+	}
+	if s := ts.buf.String(); s != flashOn+flashOff {
+		t.Errorf("Bad flash %q", s)
+	}
+}
+
+func TestSoftResetKittyKbd(t *testing.T) {
+	var out bytes.Buffer
+	ts := mkTestTScreen(t)
+	ts.stopQ = make(chan struct{})
+	ts.outw = bufio.NewWriter(&out)
+	ts.driver = &pipeDriver{}
+	ts.buffering = false
+	ts.kittyKbd = true
+
+	if err := ts.SoftReset(); err != nil {
+		t.Fatalf("SoftReset failed: %v", err)
+	}
+	// the flags pushed when the screen started are popped first, so
+	// that the stack does not grow with each reset
+	s := out.String()
+	pop, push := strings.Index(s, kittyKbdPop), strings.Index(s, kittyKbdPush)
+	if pop < 0 || push < pop || strings.Count(s, kittyKbdPush) != 1 {
+		t.Errorf("Bad kitty keyboard flags after reset %q", s)
+	}
+}
+
+// pipeDriver is a TermDriver that uses pipes, for testing whole screens.
+type pipeDriver struct {
+	input *os.File // for writing input to the screen
+}
+
+func (d *pipeDriver) Init(chan os.Signal) (*os.File, *os.File, error) {
+	inR, inW, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, outR)
+	}()
+	d.input = inW
+	return inR, outW, nil
+}
+
+func (d *pipeDriver) WinSize() (int, int, error) { return 80, 24, nil }
+func (d *pipeDriver) GetTerm() string            { return "xterm" }
+func (d *pipeDriver) Engage()                    {}
+func (d *pipeDriver) Disengage()                 {}
+
+// deadDriver is a pipeDriver whose connection has gone.
+type deadDriver struct {
+	pipeDriver
+}
+
+func (d *deadDriver) Ping() error { return io.EOF }
+
+func TestPingFailure(t *testing.T) {
+	// The error must be seen every time, not just when PollEvent
+	// happens to pick it over the closed quit channel.
+	for i := 0; i < 10; i++ {
+		s, err := NewTerminfoScreenWithOptions(&deadDriver{},
+			ScreenOptions{PingInterval: time.Millisecond})
+		if err != nil {
+			t.Fatalf("Failed to make screen: %v", err)
+		}
+		if err = s.Init(); err != nil {
+			t.Fatalf("Failed to initialize screen: %v", err)
+		}
+		// let the screen finish before looking
+		time.Sleep(20 * time.Millisecond)
+		var errs []string
+		for ev := s.PollEvent(); ev != nil; ev = s.PollEvent() {
+			if ev, ok := ev.(*EventError); ok {
+				errs = append(errs, ev.Error())
+			}
+		}
+		if len(errs) != 1 || errs[0] != io.EOF.Error() {
+			t.Fatalf("Expected just the ping error, got %q", errs)
+		}
+	}
+}
+
+func TestSetTermDriver(t *testing.T) {
+	d1, d2 := &pipeDriver{}, &pipeDriver{}
+	s, err := NewTerminfoScreenWithOptions(d1, ScreenOptions{})
+	if err != nil {
+		t.Fatalf("Failed to make screen: %v", err)
+	}
+	if err = s.Init(); err != nil {
+		t.Fatalf("Failed to initialize screen: %v", err)
+	}
+	defer s.Fini()
+
+	if err = s.SetTermDriver(d2); err != nil {
+		t.Fatalf("Failed to change driver: %v", err)
+	}
+	_, _ = d2.input.Write([]byte("x"))
+	for {
+		ev, _ := s.PollEventFor(time.Second)
+		if ev == nil {
+			t.Fatalf("No input from the new driver")
+		}
+		if ev, ok := ev.(*EventKey); ok && ev.Rune() == 'x' {
+			break
+		}
+	}
+}
+
+func TestDrawCellASCII(t *testing.T) {
+	ts := mkTestTScreen(t)
+	ts.encoder = GetEncoding("UTF-8").NewEncoder()
+	ts.asciiSafe = encodesASCII(ts.encoder)
+	if !ts.asciiSafe {
+		t.Fatalf("UTF-8 should leave ASCII as it is")
+	}
+	ts.w, ts.h = 80, 24
+	ts.cells.Resize(80, 24)
+	style := StyleDefault.Bold(true)
+	ts.curstyle = style
+	ts.cells.SetContent(3, 2, 'a', nil, style)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		ts.buf.Reset()
+		ts.cx, ts.cy = 3, 2
+		ts.cells.SetDirty(3, 2, true)
+		ts.drawCell(3, 2)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+	if s := ts.buf.String(); s != "a" || ts.cx != 4 {
+		t.Errorf("Expected just the character, got %q", s)
+	}
+	if ts.cells.Dirty(3, 2) {
+		t.Errorf("Cell should no longer be dirty")
+	}
+}
+
+func TestDrawDamageWide(t *testing.T) {
+	var out bytes.Buffer
+	ts := &tScreen{ti: &terminfo.Terminfo{
+		SetCursor: "\x1b[%i%p1%d;%p2%dH",
+		AttrOff:   "\x1b[m",
+	}}
+	ts.encoder = GetEncoding("UTF-8").NewEncoder()
+	ts.asciiSafe = true
+	ts.outw = bufio.NewWriter(&out)
+	ts.w, ts.h = 20, 3
+	ts.cells.Resize(ts.w, ts.h)
+
+	// the damage starts in the right half of the wide character at 3
+	ts.cells.SetContent(2, 0, 'a', nil, StyleDefault)
+	ts.cells.SetContent(4, 0, '世', nil, StyleDefault)
+	ts.cells.SetContent(3, 0, '界', nil, StyleDefault)
+	ts.draw()
+	out.Reset()
+
+	ts.cells.SetContent(4, 1, 'b', nil, StyleDefault)
+	ts.cells.SetContent(5, 0, 'c', nil, StyleDefault)
+	ts.draw()
+	if s := out.String(); !strings.Contains(s, "\x1b[1;6Hc") || !strings.Contains(s, "\x1b[2;5Hb") {
+		t.Errorf("Damaged cells not drawn properly: %q", s)
+	}
+}
+
+func TestCellBufferInvalidateRegion(t *testing.T) {
+	cb := &CellBuffer{}
+	cb.Resize(10, 5)
+	cb.SetContent(2, 1, '世', nil, StyleDefault)
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 10; x++ {
+			cb.SetDirty(x, y, false)
+		}
+	}
+	cb.takeDamage()
+
+	// starting in the right half of the wide character includes it
+	cb.InvalidateRegion(3, 1, 2, 2)
+	if r := cb.takeDamage(); r != image.Rect(2, 1, 6, 3) {
+		t.Errorf("Bad damage %v", r)
+	}
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 10; x++ {
+			expect := y >= 1 && y < 3 && x >= 3 && x < 5 || x == 2 && y == 1
+			if cb.Dirty(x, y) != expect {
+				t.Errorf("Cell %d,%d: expected dirty %v", x, y, expect)
+			}
+		}
+	}
+
+	cb.InvalidateRegion(8, 4, 5, 5)
+	if r := cb.takeDamage(); r != image.Rect(7, 4, 10, 5) {
+		t.Errorf("Bad damage after clipping %v", r)
+	}
+}
+
+// sizeDriver is a pipeDriver whose size can be changed.
+type sizeDriver struct {
+	pipeDriver
+	w, h int
+}
+
+func (d *sizeDriver) WinSize() (int, int, error) { return d.w, d.h, nil }
+
+func TestDECCOLM(t *testing.T) {
+	ts := mkTestTScreen(t)
+	d := &sizeDriver{w: 132, h: 24}
+	ts.driver = d
+	ts.w, ts.h = 80, 24
+	ts.cells.Resize(80, 24)
+
+	if err := ts.SetColumns(132); err != nil {
+		t.Fatalf("Failed to change columns: %v", err)
+	}
+	if s := ts.buf.String(); s != "\x1b[?40h\x1b[?3h" {
+		t.Errorf("Expected DECCOLM, got %q", s)
+	}
+	if ts.w != 132 {
+		t.Errorf("Width not updated: %d", ts.w)
+	}
+	ts.buf.Reset()
+
+	// the terminal ignores this one
+	if err := ts.SetColumns(80); err != ErrNoCapability {
+		t.Errorf("Expected ErrNoCapability, got %v", err)
+	}
+	if s := ts.buf.String(); s != "\x1b[?40h\x1b[?3l" {
+		t.Errorf("Expected DECCOLM, got %q", s)
+	}
+	if err := ts.SetColumns(40); err != ErrUnsupported {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+
+	// the terminal is put back to 80 columns, with mode 40 reset
+	ts.buf.Reset()
+	ts.restoreColumns()
+	if s := ts.buf.String(); s != "\x1b[?40h\x1b[?3l\x1b[?40l" {
+		t.Errorf("Expected the columns to be restored, got %q", s)
+	}
+	ts.buf.Reset()
+	ts.restoreColumns()
+	if s := ts.buf.String(); s != "" {
+		t.Errorf("Expected nothing more to restore, got %q", s)
+	}
+
+	// other widths are restored with XTWINOPS, and mode 40 is left
+	// alone if it was already set
+	ts.origCols = 100
+	ts.origMode40 = modeSet
+	ts.restoreColumns()
+	if s := ts.buf.String(); s != "\x1b[8;;100t" {
+		t.Errorf("Expected a window resize, got %q", s)
+	}
+}
+
+// writeSizes records the size of each write made to it.
+type writeSizes []int
+
+func (w *writeSizes) Write(b []byte) (int, error) {
+	*w = append(*w, len(b))
+	return len(b), nil
+}
+
+func TestWriteBufferSize(t *testing.T) {
+	var sizes writeSizes
+	ts := mkTestTScreen(t)
+	ts.outw = bufio.NewWriterSize(&sizes, 100)
+	ts.buffering = false
+
+	ts.writeString(strings.Repeat("x", 250))
+	if len(sizes) != 3 || sizes[0] != 100 || sizes[1] != 100 || sizes[2] != 50 {
+		t.Errorf("Expected writes of 100, 100 and 50 bytes, got %v", sizes)
+	}
+	sizes = nil
+	ts.writeBytes(make([]byte, 250))
+	if len(sizes) != 3 || sizes[0] != 100 || sizes[1] != 100 || sizes[2] != 50 {
+		t.Errorf("Expected bytes written in pieces of 100, got %v", sizes)
+	}
+	sizes = nil
+	ts.TPuts("\x1b[m")
+	if len(sizes) != 1 || sizes[0] != 3 {
+		t.Errorf("Expected one write of 3 bytes, got %v", sizes)
+	}
+}
+
+func TestSyncRegionState(t *testing.T) {
+	var out bytes.Buffer
+	ts := &tScreen{ti: &terminfo.Terminfo{
+		SetCursor: "\x1b[%i%p1%d;%p2%dH",
+		AttrOff:   "\x1b[m",
+	}}
+	ts.encoder = GetEncoding("UTF-8").NewEncoder()
+	ts.asciiSafe = true
+	ts.outw = bufio.NewWriter(&out)
+	ts.driver = &sizeDriver{w: 10, h: 3}
+	ts.w, ts.h = 10, 3
+	ts.cells.Resize(ts.w, ts.h)
+	ts.cells.SetContent(1, 1, 'a', nil, StyleDefault)
+	ts.Show()
+	out.Reset()
+
+	// another program has moved the cursor, and left bold on
+	ts.SyncRegion(0, 1, 3, 1)
+	if s := out.String(); !strings.Contains(s, "\x1b[2;1H\x1b[m a ") {
+		t.Errorf("Expected the attributes to be reset, got %q", s)
+	}
+
+	// only the region is drawn, and other changes are left pending
+	ts.cells.SetContent(8, 2, 'b', nil, StyleDefault)
+	out.Reset()
+	ts.SyncRegion(0, 1, 3, 1)
+	if s := out.String(); strings.Contains(s, "b") {
+		t.Errorf("Change outside the region drawn %q", s)
+	}
+	out.Reset()
+	ts.Show()
+	if s := out.String(); !strings.Contains(s, "\x1b[3;9Hb") {
+		t.Errorf("Change outside the region lost %q", s)
+	}
+}
+
+func TestTransactionResize(t *testing.T) {
+	var out bytes.Buffer
+	ts := mkTestTScreen(t)
+	ts.outw = bufio.NewWriter(&out)
+	ts.driver = &sizeDriver{w: 10, h: 3}
+	ts.cells.Resize(10, 3)
+
+	// a resize during a transaction is not drawn until it ends
+	ts.Transaction(func() {
+		ts.winSizeChanged()
+		if s := out.String(); s != "" {
+			t.Errorf("Drawn during the transaction %q", s)
+		}
+	})
+	if ts.w != 10 || !strings.Contains(out.String(), "\x1b[1;1H") {
+		t.Errorf("Not drawn after the transaction %q", out.String())
+	}
+}
+
+// cellDriver is a pipeDriver that knows the size of its cells.
+type cellDriver struct {
+	pipeDriver
+	cw, ch int
+}
+
+func (d *cellDriver) CellSize() (int, int) { return d.cw, d.ch }
+
+func TestPixelMouse(t *testing.T) {
+	ts := mkTestTScreen(t)
+	d := &cellDriver{}
+	ts.driver = d
+	ts.stopQ = make(chan struct{})
+	ts.mouse = []byte(ts.ti.Mouse)
+	ts.w, ts.h = 80, 24
+	ts.cells.Resize(80, 24)
+	ts.mouseFlags = MousePixelMotion
+
+	// without the size of the cells, only cells are reported
+	ts.resizeCells()
+	ts.enableMouse(ts.mouseFlags)
+	if s := ts.buf.String(); !strings.HasSuffix(s, "\x1b[?1003h\x1b[?1006h") {
+		t.Errorf("Expected cell reporting, got %q", s)
+	}
+	evs := ts.collectEventsFromInput(bytes.NewBufferString("\x1b[<0;5;3M"), false)
+	if len(evs) != 1 {
+		t.Fatalf("Expected one event, got %v", evs)
+	}
+	ev := evs[0].(*EventMouse)
+	if x, y := ev.Position(); x != 4 || y != 2 {
+		t.Errorf("Bad position %d,%d", x, y)
+	}
+	if px, py := ev.PixelPosition(); px != 0 || py != 0 {
+		t.Errorf("Pixel position should be unknown, got %d,%d", px, py)
+	}
+
+	// once the size is known, pixels are asked for
+	ts.buf.Reset()
+	d.cw, d.ch = 8, 16
+	ts.resizeCells()
+	if s := ts.buf.String(); !strings.HasSuffix(s, "\x1b[?1003h\x1b[?1016h") {
+		t.Errorf("Expected pixel reporting, got %q", s)
+	}
+	evs = ts.collectEventsFromInput(bytes.NewBufferString("\x1b[<0;41;50M"), false)
+	if len(evs) != 1 {
+		t.Fatalf("Expected one event, got %v", evs)
+	}
+	ev = evs[0].(*EventMouse)
+	if x, y := ev.Position(); x != 5 || y != 3 {
+		t.Errorf("Bad position %d,%d", x, y)
+	}
+	if px, py := ev.PixelPosition(); px != 40 || py != 49 {
+		t.Errorf("Bad pixel position %d,%d", px, py)
+	}
+}
+
+func TestDrawColorsOnly(t *testing.T) {
+	var out bytes.Buffer
+	ts := &tScreen{ti: &terminfo.Terminfo{
+		Colors:    8,
+		SetCursor: "\x1b[%i%p1%d;%p2%dH",
+		AttrOff:   "\x1b[0;10m",
+		Bold:      "\x1b[1m",
+		SetFg:     "\x1b[3%p1%dm",
+		SetBg:     "\x1b[4%p1%dm",
+		ResetFgBg: "\x1b[39;49m",
+	}}
+	ts.encoder = GetEncoding("UTF-8").NewEncoder()
+	ts.asciiSafe = true
+	ts.outw = bufio.NewWriter(&out)
+	ts.fgColors = make(map[Color]Color)
+	ts.bgColors = make(map[Color]Color)
+	ts.curstyle = styleInvalid
+	ts.w, ts.h = 4, 1
+	ts.cells.Resize(ts.w, ts.h)
+
+	bold := StyleDefault.Bold(true)
+	ts.cells.SetContent(0, 0, 'a', nil, bold.Foreground(ColorMaroon))
+	ts.cells.SetContent(1, 0, 'b', nil, bold)
+	ts.cells.SetContent(2, 0, 'c', nil, bold.Background(ColorNavy))
+	ts.cells.SetContent(3, 0, 'd', nil, bold.Background(ColorReset))
+	ts.draw()
+
+	// bold is never turned off and on again
+	expect := "\x1b[1;1H\x1b[0;10m\x1b[31m\x1b[1ma\x1b[39;49mb\x1b[44mc\x1b[39;49md"
+	if s := out.String(); !strings.Contains(s, expect) {
+		t.Errorf("Expected only the colors to change, got %q", s)
+	}
+}
+
+func TestTitles(t *testing.T) {
+	// mouse reporting alone is not enough
+	ts := mkTestTScreen(t)
+	ts.prepareTitles()
+	ts.SetTitle("x")
+	ts.SetIconTitle("y")
+	if s := ts.buf.String(); s != "" {
+		t.Errorf("Titles set without tsl and fsl: %q", s)
+	}
+
+	ts.ti.ToStatusLine = "\x1b]2;"
+	ts.ti.FromStatusLine = "\x07"
+	ts.prepareTitles()
+	ts.SetTitle("a\x1bb")
+	ts.SetIconTitle("c")
+	if s := ts.buf.String(); s != "\x1b]2;ab\x07\x1b]1;c\x07" {
+		t.Errorf("Bad titles %q", s)
+	}
+}
@@ -17,8 +17,11 @@
 package tcell
 
 import (
+	"bufio"
 	"errors"
+	"golang.org/x/sys/unix"
 	"golang.org/x/term"
+	"os"
 	"os/signal"
 	"syscall"
 )
@@ -32,25 +35,25 @@ func (t *tScreen) engage() error {
 	if t.stopQ != nil {
 		return errors.New("already engaged")
 	}
-	if _, err := term.MakeRaw(int(t.in.Fd())); err != nil {
-		return err
-	}
-	if w, h, err := t.getWinSize(); err == nil && w != 0 && h != 0 {
-		t.cells.Resize(w, h)
+	// A TermDriver may use pipes rather than a terminal, in which case
+	// there are no terminal modes to change.
+	if t.tty {
+		if _, err := term.MakeRaw(int(t.in.Fd())); err != nil {
+			return err
+		}
 	}
+	// The terminal may have been resized while we were disengaged, and
+	// whatever was displayed then is gone, so everything must be redrawn.
+	t.resize()
+	t.resizeCells()
+	t.cells.Invalidate()
+	t.curstyle = styleInvalid
 	stopQ := make(chan struct{})
 	t.stopQ = stopQ
 	t.nonBlocking(false)
-	t.enableMouse(t.mouseFlags)
-	t.enablePasting(t.pasteEnabled)
 	t.driver.Engage()
-
-	ti := t.ti
-	t.TPuts(ti.EnterCA)
-	t.TPuts(ti.EnterKeypad)
-	t.TPuts(ti.HideCursor)
-	t.TPuts(ti.EnableAcs)
-	t.TPuts(ti.Clear)
+	t.setupTerminal()
+	t.ticker.start(t.PostEvent)
 
 	t.wg.Add(2)
 	go t.inputLoop(stopQ)
@@ -65,10 +68,17 @@ func (t *tScreen) engage() error {
 func (t *tScreen) disengage() {
 
 	t.Lock()
+	if t.stopQ == nil {
+		// already disengaged, e.g. Fini after Suspend
+		t.Unlock()
+		return
+	}
 	t.nonBlocking(true)
+	t.endFlash()
 	stopQ := t.stopQ
 	t.stopQ = nil
 	close(stopQ)
+	t.ticker.stop()
 	t.Unlock()
 
 	// wait for everything to shut down
@@ -81,17 +91,21 @@ func (t *tScreen) disengage() {
 
 	// shutdown the screen and disable special modes (e.g. mouse and bracketed paste)
 	ti := t.ti
-	t.cells.Resize(0, 0)
+	t.sendCursorStyle(CursorStyleDefault)
 	t.TPuts(ti.ShowCursor)
 	t.TPuts(ti.AttrOff)
 	t.TPuts(ti.Clear)
+	t.disableKittyKbd()
 	t.TPuts(ti.ExitCA)
 	t.TPuts(ti.ExitKeypad)
 	t.enableMouse(0)
 	t.enablePasting(false)
+	t.enableFocusReporting(false)
 
 	// restore the termios that we were started with
-	_ = term.Restore(int(t.in.Fd()), t.saved)
+	if t.saved != nil {
+		_ = term.Restore(int(t.in.Fd()), t.saved)
+	}
 
 }
 
@@ -103,7 +117,12 @@ func (t *tScreen) initialize() error {
 	if t.in, t.out, err = t.driver.Init(t.sigwinch); err != nil {
 		return err
 	}
+	t.outw = bufio.NewWriterSize(t.out, t.opts.writeBufferSize())
 
+	t.saved = nil
+	if t.tty = isTerminal(t.in); !t.tty {
+		return nil
+	}
 	t.saved, err = term.GetState(int(t.in.Fd()))
 	if err == nil {
 		return nil
@@ -111,6 +130,21 @@ func (t *tScreen) initialize() error {
 	return nil
 }
 
+// isTerminal returns true if f is a terminal.  This does not use f.Fd,
+// as that would put f into blocking mode, which would stop read deadlines
+// from working if f is a pipe.
+func isTerminal(f *os.File) bool {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return false
+	}
+	tty := false
+	_ = rc.Control(func(fd uintptr) {
+		tty = term.IsTerminal(int(fd))
+	})
+	return tty
+}
+
 // finalize is used to at application shutdown, and restores the terminal
 // to it's initial state.  It should not be called more than once.
 func (t *tScreen) finalize() {
@@ -126,12 +160,39 @@ func (t *tScreen) getWinSize() (int, int, error) {
 	return term.GetSize(int(t.in.Fd()))
 }
 
-// Beep emits a beep to the terminal.
+// Beep emits a beep to the terminal, or flashes the screen, or both,
+// depending on the bell mode.
 func (t *tScreen) Beep() error {
-	t.writeString(string(byte(7)))
+	t.Lock()
+	mode, d := t.bellMode, t.bellTime
+	t.Unlock()
+	if mode != BellVisual {
+		t.writeString(string(byte(7)))
+	}
+	if mode != BellAudio {
+		t.flash(d)
+	}
 	return nil
 }
 
+// CellSize returns the size of a character cell in pixels, as given by
+// the terminal's window size.  Zeros are returned if the terminal does
+// not report its size in pixels.
+func (d *defaultTermDriver) CellSize() (int, int) {
+	rc, err := d.out.SyscallConn()
+	if err != nil {
+		return 0, 0
+	}
+	var ws *unix.Winsize
+	_ = rc.Control(func(fd uintptr) {
+		ws, err = unix.IoctlGetWinsize(int(fd), unix.TIOCGWINSZ)
+	})
+	if err != nil || ws == nil || ws.Col == 0 || ws.Row == 0 {
+		return 0, 0
+	}
+	return int(ws.Xpixel / ws.Col), int(ws.Ypixel / ws.Row)
+}
+
 func (d *defaultTermDriver) Engage() {
 	signal.Notify(d.winch, syscall.SIGWINCH)
 }
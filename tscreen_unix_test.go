@@ -0,0 +1,54 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris zos
+
+package tcell
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIsTerminalPipe(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to make pipe: %v", err)
+	}
+	defer w.Close()
+	defer r.Close()
+
+	if isTerminal(r) {
+		t.Errorf("A pipe should not be taken for a terminal")
+	}
+
+	// Looking must leave the pipe non-blocking, so that reads still
+	// stop at the deadline.
+	_ = r.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 1))
+		done <- err
+	}()
+	select {
+	case err = <-done:
+		if !os.IsTimeout(err) {
+			t.Errorf("Expected a timeout, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Read did not stop at the deadline")
+		_, _ = w.Write([]byte{0})
+	}
+}
@@ -15,8 +15,6 @@
 package views
 
 import (
-	"github.com/mattn/go-runewidth"
-
 	"github.com/gdamore/tcell/v2"
 )
 
@@ -184,7 +182,7 @@ func (t *Text) SetText(s string) {
 	t.lengths = []int{}
 	length := 0
 	for i, r := range t.text {
-		t.widths[i] = runewidth.RuneWidth(r)
+		t.widths[i] = tcell.RuneWidth(r)
 		t.styles[i] = t.style
 		if r == '\n' {
 			t.lengths = append(t.lengths, length)
@@ -0,0 +1,207 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package wrap breaks text into lines that fit a given number of
+// terminal columns, at the spaces between words where possible.  Widths
+// are worked out by grapheme cluster, so wide characters such as CJK
+// ideographs count as two columns, and accents count as none.
+package wrap
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/uniseg"
+)
+
+// Kinds of item that text is made of, for wrapping.
+const (
+	itemText = iota
+	itemSpace
+	itemNewline
+)
+
+// item is a piece of text that is not split when wrapping, which is a
+// grapheme cluster, or an escape sequence (with a width of zero).  For
+// WrapText, pos and end give where it is in the string; for
+// WrapTextStyle, they give its runes.
+type item struct {
+	kind     int
+	width    int
+	pos, end int
+}
+
+// clusterKind returns the kind of item for a grapheme cluster.
+func clusterKind(s string) int {
+	switch s {
+	case " ", "\t":
+		return itemSpace
+	case "\n", "\r\n":
+		return itemNewline
+	}
+	return itemText
+}
+
+// addClusters adds the grapheme clusters of s to items, where s starts
+// at offset pos.  If runes is true, positions count runes rather than
+// bytes.
+func addClusters(items []item, s string, pos int, runes bool) []item {
+	g := uniseg.NewGraphemes(s)
+	for g.Next() {
+		cluster := g.Str()
+		n := len(cluster)
+		if runes {
+			n = len(g.Runes())
+		}
+		items = append(items, item{
+			kind:  clusterKind(cluster),
+			width: tcell.StringWidth(cluster),
+			pos:   pos,
+			end:   pos + n,
+		})
+		pos += n
+	}
+	return items
+}
+
+// breakLines works out where to break a sequence of items into lines of
+// at most width columns.  It returns the range of items on each line.
+// Spaces where lines are broken are left out, as are the newlines.
+func breakLines(items []item, width int) [][2]int {
+	var lines [][2]int
+	start, lineW := 0, 0
+	emit := func(end int) {
+		for end > start && items[end-1].kind == itemSpace {
+			end--
+		}
+		lines = append(lines, [2]int{start, end})
+	}
+	for i := 0; i < len(items); {
+		switch items[i].kind {
+		case itemNewline:
+			emit(i)
+			i++
+			start, lineW = i, 0
+			continue
+		case itemSpace:
+			if width > 0 && lineW+items[i].width > width {
+				emit(i)
+				start, lineW = i+1, 0
+			} else {
+				lineW += items[i].width
+			}
+			i++
+			continue
+		}
+
+		// a word, which runs until the next space or newline
+		j, ww := i, 0
+		for j < len(items) && items[j].kind == itemText {
+			ww += items[j].width
+			j++
+		}
+		if width > 0 && lineW+ww > width && !hasText(items[start:i]) {
+			// only spaces before it, which are dropped
+			start, lineW = i, 0
+		}
+		switch {
+		case width <= 0 || lineW+ww <= width:
+			lineW += ww
+		case ww <= width:
+			emit(i)
+			start, lineW = i, ww
+		default:
+			// too long for any line, so it must be split
+			if lineW > 0 {
+				emit(i)
+				start, lineW = i, 0
+			}
+			for k := i; k < j; k++ {
+				if lineW+items[k].width > width && lineW > 0 {
+					emit(k)
+					start, lineW = k, 0
+				}
+				lineW += items[k].width
+			}
+		}
+		i = j
+	}
+	emit(len(items))
+	return lines
+}
+
+// hasText reports whether items has anything other than spaces.
+func hasText(items []item) bool {
+	for _, it := range items {
+		if it.kind != itemSpace {
+			return true
+		}
+	}
+	return false
+}
+
+// WrapText breaks s into lines of at most width columns.  Lines are
+// broken at spaces, which are then left out, and at newlines.  Words too
+// long to fit on a line are broken wherever they must be.  ANSI escape
+// sequences in s are kept, but take no columns, so text colorized with
+// them can be wrapped too.  If width is less than one, s is only broken
+// at newlines.
+func WrapText(s string, width int) []string {
+	var items []item
+	for i := 0; i < len(s); {
+		j := i
+		for j < len(s) && s[j] != '\x1b' {
+			j++
+		}
+		items = addClusters(items, s[i:j], i, false)
+		if j < len(s) {
+			n := tcell.EscapeLength(s[j:])
+			if n == 0 {
+				// an incomplete sequence takes the rest of s
+				n = len(s) - j
+			}
+			items = append(items, item{kind: itemText, pos: j, end: j + n})
+			j += n
+		}
+		i = j
+	}
+	lines := breakLines(items, width)
+	res := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if l[0] >= l[1] {
+			res = append(res, "")
+			continue
+		}
+		res = append(res, s[items[l[0]].pos:items[l[1]-1].end])
+	}
+	return res
+}
+
+// WrapTextStyle is like WrapText, but wraps styled runes, such as those
+// returned by tcell.ParseANSI.
+func WrapTextStyle(spans []tcell.StyledRune, width int) [][]tcell.StyledRune {
+	rs := make([]rune, len(spans))
+	for i := range spans {
+		rs[i] = spans[i].R
+	}
+	items := addClusters(nil, string(rs), 0, true)
+	lines := breakLines(items, width)
+	res := make([][]tcell.StyledRune, 0, len(lines))
+	for _, l := range lines {
+		var line []tcell.StyledRune
+		if l[0] < l[1] {
+			line = append(line, spans[items[l[0]].pos:items[l[1]-1].end]...)
+		}
+		res = append(res, line)
+	}
+	return res
+}
@@ -0,0 +1,72 @@
+// Copyright 2021 The TCell Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrap
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestWrapText(t *testing.T) {
+	var values = []struct {
+		text  string
+		width int
+		lines []string
+	}{
+		{"the quick brown fox", 10, []string{"the quick", "brown fox"}},
+		{"the quick  brown fox", 20, []string{"the quick  brown fox"}},
+		{"one\n\ntwo three", 5, []string{"one", "", "two", "three"}},
+		{"abcdefghij kl", 4, []string{"abcd", "efgh", "ij", "kl"}},
+		{"a abcdefgh", 4, []string{"a", "abcd", "efgh"}},
+		{"  indented text", 10, []string{"  indented", "text"}},
+		{"  indented text", 9, []string{"indented", "text"}},
+		{"日本語のテキスト", 6, []string{"日本語", "のテキ", "スト"}},
+		{"ééé x", 3, []string{"ééé", "x"}},
+		{"\x1b[31mred\x1b[0m text", 4, []string{"\x1b[31mred\x1b[0m", "text"}},
+		{"\x1b(B\x1b[mab cd", 2, []string{"\x1b(B\x1b[mab", "cd"}},
+		{"ab\x1b[3", 5, []string{"ab\x1b[3"}},
+		{"no limit at all", 0, []string{"no limit at all"}},
+		{"", 5, []string{""}},
+	}
+	for _, v := range values {
+		if lines := WrapText(v.text, v.width); !reflect.DeepEqual(lines, v.lines) {
+			t.Errorf("%q at %d: expected %q, got %q", v.text, v.width, v.lines, lines)
+		}
+	}
+}
+
+func TestWrapTextStyle(t *testing.T) {
+	spans, _ := tcell.ParseANSI("hello \x1b[1mbold\x1b[0m world")
+	lines := WrapTextStyle(spans, 10)
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+	var text []string
+	for _, l := range lines {
+		s := ""
+		for _, sr := range l {
+			s += string(sr.R)
+		}
+		text = append(text, s)
+	}
+	if !reflect.DeepEqual(text, []string{"hello bold", "world"}) {
+		t.Errorf("Bad lines %q", text)
+	}
+	if lines[0][6].Style != tcell.StyleDefault.Bold(true) || lines[1][0].Style != tcell.StyleDefault {
+		t.Errorf("Styles not kept")
+	}
+}